@@ -0,0 +1,238 @@
+// Module for reading loads from a generic CSV or JSON feed, by URL or
+// local file.
+
+package boards
+
+import "bytes"
+import "encoding/csv"
+import "encoding/json"
+import "errors"
+import "flag"
+import "fmt"
+import "io/ioutil"
+import "log"
+import "net/url"
+import "strconv"
+import "strings"
+
+import "common"
+import "scraper"
+
+var feedUrl = flag.String("feed_url", "",
+	"URL or file name of a CSV or JSON load feed, for --boards=feed")
+var feedFormat = flag.String("feed_format", "",
+	"Format of --feed_url, csv or json; guessed from its suffix if empty")
+var feedName = flag.String("feed_name", "feed",
+	"Board name recorded with loads read from --feed_url")
+
+const (
+	feedCsv  = "csv"
+	feedJson = "json"
+)
+
+// feedLoad is one load in a feed.  CSV feeds have a header row naming
+// these fields; JSON feeds are an array of objects.
+type feedLoad struct {
+	PickupDate  string
+	OriginCity  string
+	OriginState string
+	DestCity    string
+	DestState   string
+	LoadType    string
+	Length      int
+	Weight      int
+	Equipment   string
+	Price       feedPrice
+	Stops       int
+	Phone       string
+}
+
+// feedPrice is rate text, which JSON feeds may give as a number.
+type feedPrice string
+
+func (p *feedPrice) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*p = feedPrice(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return err
+	}
+	*p = feedPrice(n)
+	return nil
+}
+
+type feedBoard struct {
+	name   string
+	source string
+	format string
+	loadf  LoadFunc
+	skipf  SkipFunc
+}
+
+func init() {
+	Register("feed", false, NewFeed)
+}
+
+func NewFeed(loadf LoadFunc, skipf SkipFunc) (LoadBoard, error) {
+	format := *feedFormat
+	if len(format) == 0 {
+		switch {
+		case strings.HasSuffix(*feedUrl, ".json"):
+			format = feedJson
+		default:
+			format = feedCsv
+		}
+	}
+	return &feedBoard{*feedName, *feedUrl, format, loadf, skipf}, nil
+}
+
+func (f *feedBoard) Init() error {
+	if len(f.source) == 0 {
+		return errors.New("Feed not specified, use --feed_url")
+	}
+	if f.format != feedCsv && f.format != feedJson {
+		return errors.New("Unknown feed format: " + f.format)
+	}
+	return nil
+}
+
+// Read reads the whole feed as a single unit.  The page channel is
+// not used.
+func (f *feedBoard) Read(_ chan<- scraper.Page) {
+	unit := f.String()
+	if f.skipf != nil && f.skipf(unit) {
+		log.Println("Skipping completed", unit)
+		return
+	}
+	body, err := f.fetch()
+	if err != nil {
+		log.Print("Problem reading feed ", f, ": ", err)
+		return
+	}
+	var loads []*Load
+	if f.format == feedJson {
+		loads, err = f.parseJson(body)
+	} else {
+		loads, err = f.parseCsv(body)
+	}
+	if err != nil {
+		log.Print("Problem parsing feed ", f, ": ", err)
+		return
+	}
+	if err := f.loadf(unit, loads); err != nil {
+		log.Printf("Error writing %d loads: %s: %s", len(loads), f, err)
+	} else {
+		log.Printf("Wrote %d loads: %s", len(loads), f)
+	}
+}
+
+func (f *feedBoard) fetch() ([]byte, error) {
+	u, err := url.Parse(f.source)
+	if err != nil || len(u.Host) == 0 {
+		return ioutil.ReadFile(f.source)
+	}
+	query := ""
+	if len(u.RawQuery) != 0 {
+		query = "?" + u.RawQuery
+	}
+	if u.Scheme == "https" {
+		return common.GetSecureUrl(u.Host, u.Path, query)
+	}
+	return common.GetUrl(u.Host, u.Path, query)
+}
+
+func (f *feedBoard) parseJson(body []byte) ([]*Load, error) {
+	var items []feedLoad
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, err
+	}
+	var loads []*Load
+	for i, _ := range items {
+		load, err := f.toLoad(&items[i])
+		if err != nil {
+			log.Print("Bad feed load: ", err)
+			continue
+		}
+		loads = append(loads, load)
+	}
+	return loads, nil
+}
+
+func (f *feedBoard) parseCsv(body []byte) ([]*Load, error) {
+	records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	field := func(rec []string, name string) string {
+		if i, has := columns[name]; has && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	number := func(rec []string, name string) int {
+		n, _ := strconv.Atoi(field(rec, name))
+		return n
+	}
+	var loads []*Load
+	for _, rec := range records[1:] {
+		load, err := f.toLoad(&feedLoad{
+			PickupDate:  field(rec, "PickupDate"),
+			OriginCity:  field(rec, "OriginCity"),
+			OriginState: field(rec, "OriginState"),
+			DestCity:    field(rec, "DestCity"),
+			DestState:   field(rec, "DestState"),
+			LoadType:    field(rec, "LoadType"),
+			Length:      number(rec, "Length"),
+			Weight:      number(rec, "Weight"),
+			Equipment:   field(rec, "Equipment"),
+			Price:       feedPrice(field(rec, "Price")),
+			Stops:       number(rec, "Stops"),
+			Phone:       field(rec, "Phone"),
+		})
+		if err != nil {
+			log.Print("Bad feed load: ", err)
+			continue
+		}
+		loads = append(loads, load)
+	}
+	return loads, nil
+}
+
+func (f *feedBoard) toLoad(fl *feedLoad) (*Load, error) {
+	date, err := common.ParseLoadDate(fl.PickupDate)
+	if err != nil {
+		return nil, err
+	}
+	if len(fl.OriginCity) == 0 || len(fl.DestCity) == 0 {
+		return nil, errors.New(fmt.Sprint("Missing city: ", *fl))
+	}
+	return &Load{/* ScrapeId not known yet */ 0, f.name, date,
+		common.CityState{common.ProperName(fl.OriginCity),
+			feedState(fl.OriginState)},
+		common.CityState{common.ProperName(fl.DestCity),
+			feedState(fl.DestState)},
+		fl.LoadType, fl.Length, fl.Weight, fl.Equipment,
+		ParseRate(string(fl.Price)), fl.Stops, fl.Phone}, nil
+}
+
+// feedState accepts either a state code or a state name.
+func feedState(s string) string {
+	if len(s) == 2 {
+		return strings.ToUpper(s)
+	}
+	return common.StateCode(common.ProperName(s))
+}
+
+func (f *feedBoard) String() string {
+	return fmt.Sprint("Feed-", f.name)
+}
@@ -0,0 +1,57 @@
+package boards
+
+import "testing"
+
+const testCsvFeed = `PickupDate,OriginCity,OriginState,DestCity,DestState,Equipment,Price,Weight
+2013-04-02,salem,or,BOISE,Idaho,Van,1200,40000
+bad-date,Salem,OR,Boise,ID,Van,1200,40000
+2013-04-03,Eugene,OR,,ID,Van,1200,40000
+`
+
+const testJsonFeed = `[
+{"PickupDate": "2013-04-02", "OriginCity": "salem", "OriginState": "or",
+ "DestCity": "BOISE", "DestState": "Idaho", "Equipment": "Van",
+ "Price": 1200, "Weight": 40000}
+]`
+
+func checkFeedLoads(t *testing.T, loads []*Load, err error) {
+	if err != nil {
+		t.Fatal("Feed parse error: ", err)
+	}
+	if len(loads) != 1 {
+		t.Fatalf("Expected 1 load, got %v", loads)
+	}
+	l := loads[0]
+	if l.Board != "test" || l.Origin.String() != "Salem, OR" ||
+		l.Dest.String() != "Boise, ID" || l.Rate != (Rate{RateFlat, 120000, "1200"}) ||
+		l.Weight != 40000 || l.Equipment != "Van" {
+		t.Errorf("Incorrect load: %v", l)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	f := &feedBoard{name: "test"}
+	loads, err := f.parseCsv([]byte(testCsvFeed))
+	checkFeedLoads(t, loads, err)
+	loads, err = f.parseJson([]byte(testJsonFeed))
+	checkFeedLoads(t, loads, err)
+}
+
+func TestRegistry(t *testing.T) {
+	names := Names()
+	expect := []string{"feed", "trulos", "trulos_http"}
+	if len(names) != len(expect) {
+		t.Fatalf("Incorrect boards: %v", names)
+	}
+	for i, name := range expect {
+		if names[i] != name {
+			t.Errorf("Incorrect boards: %v", names)
+		}
+	}
+	if !NeedsBrowser("trulos") || NeedsBrowser("trulos_http") {
+		t.Errorf("Incorrect browser requirements")
+	}
+	if _, err := New("nonesuch", nil, nil); err == nil {
+		t.Errorf("Created an unknown board")
+	}
+}
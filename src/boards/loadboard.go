@@ -8,6 +8,14 @@ import "time"
 import "common"
 import "scraper"
 
+// LoadFunc receives the loads read from one unit of a board, e.g., a
+// state and equipment type.  Units are named by their page Id.
+type LoadFunc func(unit string, loads []*Load) error
+
+// SkipFunc reports whether a unit was completed by an earlier,
+// interrupted scrape and need not be read again.
+type SkipFunc func(unit string) bool
+
 type LoadBoard interface {
 	Init() error
 	Read(chan<- scraper.Page)
@@ -15,6 +23,7 @@ type LoadBoard interface {
 
 type Load struct {
 	ScrapeId    int64
+	Board       string // Name of the board that produced it
 	PickupDate  time.Time
 	Origin      common.CityState
 	Dest        common.CityState
@@ -22,14 +31,14 @@ type Load struct {
 	Length      int
 	Weight      int
 	Equipment   string
-	Price       int
+	Rate        Rate
 	Stops       int
 	Phone       string
 }
 
 func (l *Load) String() string {
-	return fmt.Sprintf("[%d] %v %v -> %v %v %v %v %v %v %v %v",
-		l.ScrapeId, common.FormatLoadDate(l.PickupDate),
+	return fmt.Sprintf("[%d %s] %v %v -> %v %v %v %v %v %v %v %v",
+		l.ScrapeId, l.Board, common.FormatLoadDate(l.PickupDate),
 		l.Origin, l.Dest, l.LoadType, l.Length, l.Weight, 
-		l.Equipment, l.Price, l.Stops, l.Phone)
+		l.Equipment, l.Rate, l.Stops, l.Phone)
 }
@@ -0,0 +1,115 @@
+// Module for reading trulos.com without a browser, by performing the
+// ASP.NET WebForms postbacks that __doPostBack would make.
+
+package boards
+
+import "bytes"
+import "errors"
+import "log"
+import "net/url"
+import "regexp"
+import "code.google.com/p/go.net/html"
+import "code.google.com/p/go.net/html/atom"
+
+import "common"
+import "scraper"
+
+const (
+	eventTarget   = "__EVENTTARGET"
+	eventArgument = "__EVENTARGUMENT"
+)
+
+var postBackRe = regexp.MustCompile(`__doPostBack\('([^']*)','([^']*)'\)`)
+
+func init() {
+	Register(trulosName+"_http", false, NewTrulosHeadless)
+}
+
+type trulosHeadless struct {
+	*trulosBoard
+}
+
+// NewTrulosHeadless returns a trulos.com board that pages through
+// GridView1 over plain HTTP, carrying __VIEWSTATE, __EVENTVALIDATION
+// and the other hidden form fields from one page to the next.  It
+// needs no browser; Read ignores its page channel.
+func NewTrulosHeadless(loadf LoadFunc, skipf SkipFunc) (LoadBoard, error) {
+	board, err := NewTrulos(loadf, skipf)
+	if err != nil {
+		return nil, err
+	}
+	return &trulosHeadless{board.(*trulosBoard)}, nil
+}
+
+func (t *trulosHeadless) Read(_ chan<- scraper.Page) {
+	t.readAll(t.postBack)
+}
+
+func (t *trulosHeadless) postBack(s *trulosScrape) {
+	ch := s.Channel()
+	body := s.Body()
+	ch <- &scraper.Result{"", body, nil}
+	form := hiddenFields(body)
+	for _, action := range s.Actions() {
+		target, argument, err := parsePostBack(action)
+		if err != nil {
+			ch <- &scraper.Result{action, nil, err}
+			continue
+		}
+		form.Set(eventTarget, target)
+		form.Set(eventArgument, argument)
+		// WebForms pages post back to their own URL, query included.
+		page, err := common.PostUrl(t.host, baseUri,
+			s.state.queryForEquip(s.equip), form)
+		if err != nil {
+			log.Print("Problem posting back ", s, " ", action, ": ", err)
+		} else {
+			form = hiddenFields(page)
+		}
+		ch <- &scraper.Result{action, page, err}
+	}
+}
+
+// parsePostBack returns the event target and argument of a
+// javascript __doPostBack() call.
+func parsePostBack(action string) (string, string, error) {
+	m := postBackRe.FindStringSubmatch(action)
+	if m == nil {
+		return "", "", errors.New("Not a postback: " + action)
+	}
+	return m[1], m[2], nil
+}
+
+// hiddenFields returns the hidden <input> values of a page, which is
+// where WebForms keeps its state between postbacks.
+func hiddenFields(body []byte) url.Values {
+	form := make(url.Values)
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return form
+	}
+	var traverse func(n *html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Input {
+			var typ, name, value string
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "type":
+					typ = attr.Val
+				case "name":
+					name = attr.Val
+				case "value":
+					value = attr.Val
+				}
+			}
+			if typ == "hidden" && len(name) != 0 {
+				form.Set(name, value)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+	return form
+}
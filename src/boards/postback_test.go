@@ -0,0 +1,36 @@
+package boards
+
+import "testing"
+
+func TestParsePostBack(t *testing.T) {
+	target, arg, err := parsePostBack(
+		"__doPostBack('ctl00$ContentPlaceHolder1$GridView1','Page$3')")
+	if err != nil || target != "ctl00$ContentPlaceHolder1$GridView1" ||
+		arg != "Page$3" {
+		t.Errorf("Incorrect postback: %q %q %v", target, arg, err)
+	}
+	if _, _, err := parsePostBack("javascript:void(0)"); err == nil {
+		t.Errorf("Parsed a non-postback")
+	}
+}
+
+const hiddenPage = `<html><body><form method="post" action="">
+<input type="hidden" name="__EVENTTARGET" id="__EVENTTARGET" value="" />
+<input type="hidden" name="__VIEWSTATE" id="__VIEWSTATE" value="dDwtMTA4=" />
+<input type="hidden" name="__EVENTVALIDATION" value="/wEWAgL+" />
+<input type="text" name="search" value="ignored" />
+</form></body></html>`
+
+func TestHiddenFields(t *testing.T) {
+	form := hiddenFields([]byte(hiddenPage))
+	if len(form) != 3 {
+		t.Errorf("Expected 3 hidden fields, got %v", form)
+	}
+	if form.Get("__VIEWSTATE") != "dDwtMTA4=" ||
+		form.Get("__EVENTVALIDATION") != "/wEWAgL+" {
+		t.Errorf("Incorrect hidden fields: %v", form)
+	}
+	if _, has := form["search"]; has {
+		t.Errorf("Visible field included: %v", form)
+	}
+}
@@ -0,0 +1,130 @@
+// Load rates, as posted: a flat amount, an amount per mile, or
+// something we could not interpret.
+
+package boards
+
+import "fmt"
+import "math"
+import "regexp"
+import "strconv"
+import "strings"
+
+type RateType int
+
+const (
+	RateUnknown RateType = iota
+	RateFlat
+	RatePerMile
+)
+
+const (
+	kilometersPerMile = 1.609344
+	// Posted amounts below this many dollars are assumed to be per
+	// mile when the text does not say.
+	maxPerMileDollars = 10
+)
+
+var (
+	rateNumRe     = regexp.MustCompile(`[0-9]+(?:\.[0-9]*)?|\.[0-9]+`)
+	ratePerMileRe = regexp.MustCompile(`(?i)(/|\bper\s*)\s*(mi|mile)\b|\b(rpm|ppm)\b`)
+	rateFlatRe    = regexp.MustCompile(`(?i)\b(flat|total|all\s*in)\b`)
+)
+
+var rateTypeNames = []string{"unknown", "flat", "per_mile"}
+
+// Rate is the price offered for a load.  Cents is the flat amount or
+// the amount per mile, according to Type.  Text is the rate as it was
+// posted.
+type Rate struct {
+	Type  RateType
+	Cents int
+	Text  string
+}
+
+// Price is a rate normalized over the road distance of a load.
+type Price struct {
+	TotalCents   int
+	PerMileCents int
+}
+
+// ParseRate interprets posted rate text such as "$1,200", "2.15/mi"
+// or "Call".
+func ParseRate(text string) Rate {
+	text = strings.TrimSpace(text)
+	num := rateNumRe.FindString(strings.Replace(text, ",", "", -1))
+	if len(num) == 0 {
+		return Rate{RateUnknown, 0, text}
+	}
+	dollars, err := strconv.ParseFloat(num, 64)
+	if err != nil || dollars <= 0 {
+		return Rate{RateUnknown, 0, text}
+	}
+	cents := int(math.Floor(dollars*100 + 0.5))
+	switch {
+	case ratePerMileRe.MatchString(text):
+		return Rate{RatePerMile, cents, text}
+	case rateFlatRe.MatchString(text):
+		return Rate{RateFlat, cents, text}
+	case dollars < maxPerMileDollars:
+		return Rate{RatePerMile, cents, text}
+	}
+	return Rate{RateFlat, cents, text}
+}
+
+// Dollars returns the flat price in whole dollars, or 0 if the rate is
+// not flat.
+func (r Rate) Dollars() int {
+	if r.Type != RateFlat {
+		return 0
+	}
+	return (r.Cents + 50) / 100
+}
+
+// Normalize computes the total and per-mile price of a load over the
+// given road distance.  It returns false when the rate is unknown or
+// the distance is not positive.
+func (r Rate) Normalize(kilometers float64) (Price, bool) {
+	if r.Type == RateUnknown || kilometers <= 0 {
+		return Price{}, false
+	}
+	miles := kilometers / kilometersPerMile
+	if r.Type == RatePerMile {
+		return Price{int(math.Floor(float64(r.Cents)*miles + 0.5)),
+			r.Cents}, true
+	}
+	return Price{r.Cents, int(math.Floor(float64(r.Cents)/miles + 0.5))},
+		true
+}
+
+func (r Rate) String() string {
+	switch r.Type {
+	case RateFlat:
+		return fmt.Sprintf("$%d.%02d", r.Cents/100, r.Cents%100)
+	case RatePerMile:
+		return fmt.Sprintf("$%d.%02d/mi", r.Cents/100, r.Cents%100)
+	}
+	return fmt.Sprintf("?%q", r.Text)
+}
+
+func (t RateType) String() string {
+	if t < 0 || int(t) >= len(rateTypeNames) {
+		return rateTypeNames[RateUnknown]
+	}
+	return rateTypeNames[t]
+}
+
+// ParseRateType is the inverse of RateType.String.
+func ParseRateType(s string) RateType {
+	for i, name := range rateTypeNames {
+		if name == s {
+			return RateType(i)
+		}
+	}
+	return RateUnknown
+}
+
+func (p Price) String() string {
+	return fmt.Sprintf("$%d.%02d ($%d.%02d/mi)",
+		p.TotalCents/100, p.TotalCents%100,
+		p.PerMileCents/100, p.PerMileCents%100)
+}
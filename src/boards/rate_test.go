@@ -0,0 +1,51 @@
+package boards
+
+import "testing"
+
+func TestParseRate(t *testing.T) {
+	for _, test := range []struct {
+		text  string
+		rtype RateType
+		cents int
+	}{
+		{"$1,200", RateFlat, 120000},
+		{"1200.00", RateFlat, 120000},
+		{"2.15", RatePerMile, 215},
+		{"$2.15/mi", RatePerMile, 215},
+		{"12.50 per mile", RatePerMile, 1250},
+		{"3 RPM", RatePerMile, 300},
+		{"$5 flat", RateFlat, 500},
+		{"Call", RateUnknown, 0},
+		{"", RateUnknown, 0},
+		{"0", RateUnknown, 0},
+	} {
+		r := ParseRate(test.text)
+		if r.Type != test.rtype || r.Cents != test.cents ||
+			r.Text != test.text {
+			t.Errorf("ParseRate(%q) = %v (%v) want %v %d",
+				test.text, r, r.Type, test.rtype, test.cents)
+		}
+		if ParseRateType(r.Type.String()) != r.Type {
+			t.Errorf("RateType %v does not round trip", r.Type)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// 500 miles
+	km := 500 * kilometersPerMile
+	if p, ok := ParseRate("$1000").Normalize(km); !ok ||
+		p != (Price{100000, 200}) {
+		t.Errorf("Incorrect flat price: %v", p)
+	}
+	if p, ok := ParseRate("2.50/mi").Normalize(km); !ok ||
+		p != (Price{125000, 250}) {
+		t.Errorf("Incorrect per-mile price: %v", p)
+	}
+	if _, ok := ParseRate("Call").Normalize(km); ok {
+		t.Errorf("Unknown rate normalized")
+	}
+	if _, ok := ParseRate("$1000").Normalize(0); ok {
+		t.Errorf("Rate normalized without distance")
+	}
+}
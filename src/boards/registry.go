@@ -0,0 +1,50 @@
+// Registry of load boards, by name.
+
+package boards
+
+import "errors"
+import "sort"
+
+// Factory constructs a board that passes its loads to loadf and
+// skips the units for which skipf is true.
+type Factory func(loadf LoadFunc, skipf SkipFunc) (LoadBoard, error)
+
+type registration struct {
+	factory Factory
+	browser bool
+}
+
+var registry = make(map[string]registration)
+
+// Register makes a board available to New.  A board registered with
+// browser true reads its pages through the scraper.Page channel, which
+// must be served by a scraper.Browser.
+func Register(name string, browser bool, factory Factory) {
+	if _, has := registry[name]; has {
+		panic("Board registered twice: " + name)
+	}
+	registry[name] = registration{factory, browser}
+}
+
+func New(name string, loadf LoadFunc, skipf SkipFunc) (LoadBoard, error) {
+	reg, has := registry[name]
+	if !has {
+		return nil, errors.New("Unknown load board: " + name)
+	}
+	return reg.factory(loadf, skipf)
+}
+
+// NeedsBrowser reports whether the named board reads through a browser.
+func NeedsBrowser(name string) bool {
+	return registry[name].browser
+}
+
+// Names returns the registered board names, sorted.
+func Names() []string {
+	var names []string
+	for name, _ := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -20,8 +20,9 @@ import "scraper"
 var stateRe = flag.String("trulos_state_regexp", ".*", "")
 
 const (
-	baseUri   = "/Trulos/Post-Truck-Loads/Truck-Load-Board.aspx"
-	contentId = "ContentPlaceHolder1_GridView1"
+	trulosName = "trulos"
+	baseUri    = "/Trulos/Post-Truck-Loads/Truck-Load-Board.aspx"
+	contentId  = "ContentPlaceHolder1_GridView1"
 	// Regexp for finding actions in HTML-escaped javascript
 	escapedRegexp = `[a-zA-Z0-9$&#;,]+`
 	pageRegexp    = `__doPostBack\(` + escapedRegexp +
@@ -34,7 +35,8 @@ type trulosBoard struct {
 	equipRe     *regexp.Regexp
 	pageRe      *regexp.Regexp
 	stateProcRe *regexp.Regexp
-	loadf       func([]*Load) error
+	loadf       LoadFunc
+	skipf       SkipFunc
 	states      []*trulosState
 }
 
@@ -55,14 +57,18 @@ type trulosScrape struct {
 	loads   []*Load
 }
 
-func NewTrulos(loadf func([]*Load) error) (LoadBoard, error) {
+func init() {
+	Register(trulosName, true, NewTrulos)
+}
+
+func NewTrulos(loadf LoadFunc, skipf SkipFunc) (LoadBoard, error) {
 	stateUriRe := regexp.MustCompile(regexp.QuoteMeta(baseUri+"?STATE=") + `(\w+)`)
 	equipRe := regexp.MustCompile(`\?STATE=(?:\w+)&amp;Equipment=([ /\w]+)`)
 	pageRe := regexp.MustCompile(pageRegexp)
 	stateProcRe := regexp.MustCompile(*stateRe)
 	board := &trulosBoard{"www.trulos.com",
 		stateUriRe, equipRe, pageRe, stateProcRe,
-		loadf, nil}
+		loadf, skipf, nil}
 	return board, nil
 }
 
@@ -98,6 +104,15 @@ func (s *trulosState) queryForEquip(equip string) string {
 // Read asynchronously reads pages from the board and passes them to the
 // scrape-evaluator.
 func (t *trulosBoard) Read(pages chan<- scraper.Page) {
+	t.readAll(func(scrape *trulosScrape) {
+		pages <- scrape
+	})
+}
+
+// readAll reads each state and equipment type, handing the first page
+// of each to fulfill, which must deliver a result for the page and for
+// each of its actions.
+func (t *trulosBoard) readAll(fulfill func(*trulosScrape)) {
 	compCh := make(chan int)
 	for _, state := range t.states {
 		if len(t.stateProcRe.FindString(state.name)) == 0 {
@@ -107,6 +122,10 @@ func (t *trulosBoard) Read(pages chan<- scraper.Page) {
 		for _, equip := range state.equipmentTypes {
 			//log.Println("Reading Trulos state",
 			//            state.name, equip)
+			if unit := trulosUnit(state, equip); t.skipf != nil && t.skipf(unit) {
+				log.Println("Skipping completed", unit)
+				continue
+			}
 			query := state.queryForEquip(equip)
 			body, err := common.GetUrl(t.host, baseUri, query)
 			if err != nil {
@@ -125,7 +144,7 @@ func (t *trulosBoard) Read(pages chan<- scraper.Page) {
 			// Process len(actions) + 1 pages, block until
 			// completion.
 			go scrape.ProcessScrapes()
-			pages <- scrape
+			fulfill(scrape)
 			<-compCh
 		}
 	}
@@ -135,7 +154,7 @@ func (s *trulosScrape) ProcessScrapes() {
 	for i := 0; i <= len(s.actions); i++ {
 		s.Process(<-s.respCh)
 	}
-	if err := s.state.board.loadf(s.loads); err != nil {
+	if err := s.state.board.loadf(s.Id(), s.loads); err != nil {
 		log.Printf("Error writing %d loads: %s: %s",
 			len(s.loads), s, err)
 	} else {
@@ -144,8 +163,12 @@ func (s *trulosScrape) ProcessScrapes() {
 	s.compCh <- 1
 }
 
+func trulosUnit(state *trulosState, equip string) string {
+	return fmt.Sprint("Trulos-", state.name, "-", equip)
+}
+
 func (s *trulosScrape) Id() string {
-	return fmt.Sprint("Trulos-", s.state.name, "-", s.equip)
+	return trulosUnit(s.state, s.equip)
 }
 
 func (s *trulosScrape) Body() []byte {
@@ -258,20 +281,17 @@ func (s *trulosScrape) ProcessRowData(row []string) {
 			trimmed[10], s, trimmed)
 		return
 	}
-	price := ParseLeadingInt(trimmed[11])
+	rate := ParseRate(trimmed[11])
 	weight := ParseLeadingInt(trimmed[12])
 	stops := ParseLeadingInt(trimmed[13])
-	if price < 10 {
-		price = 0 // TODO(jmacd): Assume per mile, fix.
-	}
 	if weight < 100 {
 		weight *= 1000 // Assume per thousand pounds
 	}
 	phone := trimmed[15]
-	load := &Load{/* ScrapeId not known yet */ 0, 
+	load := &Load{/* ScrapeId not known yet */ 0, trulosName,
 		date, common.CityState{common.ProperName(origin), s.state.name},
 		common.CityState{common.ProperName(destCity), destState},
-		loadType, llen, weight, s.equip, price, stops, phone}
+		loadType, llen, weight, s.equip, rate, stops, phone}
 	s.loads = append(s.loads, load)
 }
 
@@ -0,0 +1,153 @@
+// Files of fixed-size arrays that are memory-mapped for reading, so
+// that large tables load without parsing.
+
+package common
+
+import "encoding/binary"
+import "errors"
+import "hash/crc32"
+import "io"
+import "os"
+import "unsafe"
+
+const (
+	magicSize   = 16
+	headerSize  = magicSize + 16
+	arrayAlign  = 8
+	byteOrderId = 0x01020304
+)
+
+// ArrayFile is an open file of arrays.  Its arrays are only valid
+// until Close.
+type ArrayFile struct {
+	data   []byte
+	arrays [][]byte
+}
+
+func alignUp(n int) int {
+	return (n + arrayAlign - 1) &^ (arrayAlign - 1)
+}
+
+// WriteArrays writes arrays, each aligned to 8 bytes, after a header
+// with the magic string (at most 16 bytes), the version, the machine
+// byte order and a checksum of the contents.  The arrays are written
+// in machine order, to be mapped by the same kind of machine.
+func WriteArrays(w io.Writer, magic string, version uint32, arrays [][]byte) error {
+	if len(magic) > magicSize {
+		return errors.New("Magic string too long: " + magic)
+	}
+	index := make([]byte, 16*len(arrays))
+	pos := headerSize + len(index)
+	crc := crc32.NewIEEE()
+	for i, a := range arrays {
+		pos = alignUp(pos)
+		binary.LittleEndian.PutUint64(index[16*i:], uint64(pos))
+		binary.LittleEndian.PutUint64(index[16*i+8:], uint64(len(a)))
+		crc.Write(a)
+		pos += len(a)
+	}
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.LittleEndian.PutUint32(header[magicSize:], version)
+	*(*uint32)(unsafe.Pointer(&header[magicSize+4])) = byteOrderId
+	binary.LittleEndian.PutUint32(header[magicSize+8:], uint32(len(arrays)))
+	binary.LittleEndian.PutUint32(header[magicSize+12:], crc.Sum32())
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(index); err != nil {
+		return err
+	}
+	pos = headerSize + len(index)
+	pad := make([]byte, arrayAlign)
+	for _, a := range arrays {
+		if _, err := w.Write(pad[:alignUp(pos)-pos]); err != nil {
+			return err
+		}
+		if _, err := w.Write(a); err != nil {
+			return err
+		}
+		pos = alignUp(pos) + len(a)
+	}
+	return nil
+}
+
+// OpenArrays maps a file written by WriteArrays, verifying its
+// header and checksum.
+func OpenArrays(name, magic string, version uint32) (*ArrayFile, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := mapFile(f)
+	if err != nil {
+		return nil, err
+	}
+	af := &ArrayFile{data, nil}
+	if err := af.parse(magic, version); err != nil {
+		af.Close()
+		return nil, errors.New(name + ": " + err.Error())
+	}
+	return af, nil
+}
+
+func (af *ArrayFile) parse(magic string, version uint32) error {
+	data := af.data
+	if len(data) < headerSize {
+		return errors.New("Truncated header")
+	}
+	expect := make([]byte, magicSize)
+	copy(expect, magic)
+	if string(data[:magicSize]) != string(expect) {
+		return errors.New("Incorrect file type")
+	}
+	if v := binary.LittleEndian.Uint32(data[magicSize:]); v != version {
+		return errors.New("Unsupported version")
+	}
+	if *(*uint32)(unsafe.Pointer(&data[magicSize+4])) != byteOrderId {
+		return errors.New("Written with a different byte order")
+	}
+	count := int(binary.LittleEndian.Uint32(data[magicSize+8:]))
+	if len(data) < headerSize+16*count {
+		return errors.New("Truncated index")
+	}
+	crc := crc32.NewIEEE()
+	for i := 0; i < count; i++ {
+		entry := data[headerSize+16*i:]
+		pos := binary.LittleEndian.Uint64(entry)
+		size := binary.LittleEndian.Uint64(entry[8:])
+		if pos > uint64(len(data)) || size > uint64(len(data))-pos {
+			return errors.New("Truncated array")
+		}
+		a := data[pos : pos+size : pos+size]
+		crc.Write(a)
+		af.arrays = append(af.arrays, a)
+	}
+	if crc.Sum32() != binary.LittleEndian.Uint32(data[magicSize+12:]) {
+		return errors.New("Checksum mismatch")
+	}
+	return nil
+}
+
+// Count returns the number of arrays.
+func (af *ArrayFile) Count() int {
+	return len(af.arrays)
+}
+
+// Array returns the bytes of the i'th array.
+func (af *ArrayFile) Array(i int) []byte {
+	return af.arrays[i]
+}
+
+// Checksum returns the CRC-32 (IEEE) of the arrays, in order.
+func (af *ArrayFile) Checksum() uint32 {
+	return binary.LittleEndian.Uint32(af.data[magicSize+12:])
+}
+
+func (af *ArrayFile) Close() error {
+	af.arrays = nil
+	data := af.data
+	af.data = nil
+	return unmapFile(data)
+}
@@ -0,0 +1,63 @@
+package common
+
+import "bytes"
+import "hash/crc32"
+import "io/ioutil"
+import "os"
+import "testing"
+import "unsafe"
+
+func writeTestArrays(t *testing.T, arrays [][]byte) string {
+	f, err := ioutil.TempFile("", "arrays")
+	if err != nil {
+		t.Fatal("TempFile: ", err)
+	}
+	defer f.Close()
+	if err := WriteArrays(f, "test-arrays", 2, arrays); err != nil {
+		t.Fatal("WriteArrays: ", err)
+	}
+	return f.Name()
+}
+
+func TestArrayFile(t *testing.T) {
+	arrays := [][]byte{[]byte("abc"), nil, []byte("0123456789")}
+	name := writeTestArrays(t, arrays)
+	defer os.Remove(name)
+	af, err := OpenArrays(name, "test-arrays", 2)
+	if err != nil {
+		t.Fatal("OpenArrays: ", err)
+	}
+	if af.Count() != len(arrays) {
+		t.Errorf("Expected %d arrays, got %d", len(arrays), af.Count())
+	}
+	for i, a := range arrays {
+		if !bytes.Equal(af.Array(i), a) {
+			t.Errorf("Array %d got %q want %q", i, af.Array(i), a)
+		}
+		if len(af.Array(i)) != 0 && uintptrAligned(af.Array(i)) != 0 {
+			t.Errorf("Array %d is not aligned", i)
+		}
+	}
+	if crc := crc32.ChecksumIEEE(bytes.Join(arrays, nil)); af.Checksum() != crc {
+		t.Errorf("Checksum got %x want %x", af.Checksum(), crc)
+	}
+	if err := af.Close(); err != nil {
+		t.Error("Close: ", err)
+	}
+	if _, err := OpenArrays(name, "test-arrays", 3); err == nil {
+		t.Error("Opened the wrong version")
+	}
+	if _, err := OpenArrays(name, "other", 2); err == nil {
+		t.Error("Opened the wrong file type")
+	}
+	data, _ := ioutil.ReadFile(name)
+	data[len(data)-1] ^= 1
+	ioutil.WriteFile(name, data, 0644)
+	if _, err := OpenArrays(name, "test-arrays", 2); err == nil {
+		t.Error("Opened a corrupt file")
+	}
+}
+
+func uintptrAligned(b []byte) uintptr {
+	return uintptr(unsafe.Pointer(&b[0])) % arrayAlign
+}
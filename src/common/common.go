@@ -6,6 +6,9 @@ import "fmt"
 import "io/ioutil"
 import "log"
 import "net/http"
+import "net/http/cookiejar"
+import "net/url"
+import "strings"
 import "os"
 import "runtime"
 import "runtime/pprof"
@@ -35,19 +38,25 @@ func init() {
 	// Seems to be a problem _only_ when a proxy is involved.
 	// { DisableCompression: true }
 
+	// The jar keeps ASP.NET session cookies across postbacks.
+	jar, _ := cookiejar.New(nil)
 	client = &http.Client{
-		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment,
+		Transport: ReplayTransport(&http.Transport{Proxy: http.ProxyFromEnvironment,
 			DisableCompression: true,
-		},
+		}),
+		Jar: jar,
 	}
 	secure = &http.Client{
-		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment,
+		Transport: ReplayTransport(&http.Transport{Proxy: http.ProxyFromEnvironment,
 			TLSClientConfig: &tls.Config{RootCAs: nil},
-		},
+		}),
 	}
 }
 
 func SleepAWhile(url, query string) {
+	if Replaying() {
+		return
+	}
 	time.Sleep(time.Second * 2)
 }
 
@@ -67,6 +76,19 @@ func GetSecureUrl(host, uri, query string) ([]byte, error) {
 	return getUrlInternal("https", host, uri, query, secure, true)
 }
 
+// PostUrl submits form to the URI and query the way a browser does.
+func PostUrl(host, uri, query string, form url.Values) ([]byte, error) {
+	SleepAWhile(uri, query)
+	req, err := http.NewRequest("POST", "http://"+host+uri+query,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Origin", "http://"+host)
+	return doRequest(req, client)
+}
+
 func getUrlInternal(
 	protocol, host, uri, query string, c *http.Client, addSleep bool) ([]byte, error) {
 	if addSleep {
@@ -77,6 +99,10 @@ func getUrlInternal(
 	if err != nil {
 		return nil, err
 	}
+	return doRequest(req, c)
+}
+
+func doRequest(req *http.Request, c *http.Client) ([]byte, error) {
 	req.Header.Add("User-Agent", UserAgent)
 	resp, err := c.Do(req)
 	if err != nil {
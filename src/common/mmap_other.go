@@ -0,0 +1,16 @@
+//go:build !darwin && !freebsd && !linux
+// +build !darwin,!freebsd,!linux
+
+package common
+
+import "io/ioutil"
+import "os"
+
+// mapFile reads the file where mmap is unavailable.
+func mapFile(f *os.File) ([]byte, error) {
+	return ioutil.ReadAll(f)
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
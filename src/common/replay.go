@@ -0,0 +1,134 @@
+package common
+
+import "bufio"
+import "bytes"
+import "crypto/sha1"
+import "errors"
+import "flag"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "net/http"
+import "net/http/httputil"
+import "os"
+import "path"
+import "sync"
+
+var recordDir = flag.String("record_dir", "",
+	"Directory for saving every HTTP response, for --replay_dir")
+var replayDir = flag.String("replay_dir", "",
+	"Directory of recorded HTTP responses to serve instead of the network")
+
+const replayIndex = "index.txt"
+
+// replayTransport records responses to --record_dir or serves them
+// back from --replay_dir.  A response is named by a hash of its
+// request's method, URL and body, plus the number of times the same
+// request was made before, so that a replay sees the same sequence of
+// responses as the recording.
+type replayTransport struct {
+	transport http.RoundTripper
+	mutex     sync.Mutex
+	counts    map[string]int
+}
+
+// ReplayTransport wraps t to support --record_dir and --replay_dir.
+// The flags are consulted per request, so this may be called before
+// flag.Parse().
+func ReplayTransport(t http.RoundTripper) http.RoundTripper {
+	return &replayTransport{transport: t, counts: make(map[string]int)}
+}
+
+// Replaying reports whether responses come from --replay_dir.
+func Replaying() bool {
+	return len(*replayDir) != 0
+}
+
+func (r *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !Replaying() && len(*recordDir) == 0 {
+		return r.transport.RoundTrip(req)
+	}
+	hash, err := requestHash(req)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	seq := r.counts[hash]
+	r.counts[hash] = seq + 1
+	r.mutex.Unlock()
+
+	if Replaying() {
+		return r.replay(hash, seq, req)
+	}
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	// A failed recording may have consumed the body, so the response
+	// is unusable.
+	if err := r.record(hash, seq, req, resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func requestHash(req *http.Request) (string, error) {
+	h := sha1.New()
+	io.WriteString(h, req.Method+" "+req.URL.String()+"\n")
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func responseFile(dir, hash string, seq int) string {
+	return path.Join(dir, fmt.Sprint(hash, "-", seq))
+}
+
+func (r *replayTransport) record(hash string, seq int,
+	req *http.Request, resp *http.Response) error {
+	// DumpResponse replaces resp.Body with an unread copy.
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return err
+	}
+	name := responseFile(*recordDir, hash, seq)
+	if err := ioutil.WriteFile(name, dump, 0644); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	index, err := os.OpenFile(path.Join(*recordDir, replayIndex),
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+	_, err = fmt.Fprintln(index, path.Base(name), req.Method, req.URL)
+	return err
+}
+
+// replay returns the seq'th recording of a request, or the last one
+// if the request was recorded fewer times.
+func (r *replayTransport) replay(hash string, seq int,
+	req *http.Request) (*http.Response, error) {
+	for ; seq >= 0; seq-- {
+		dump, err := ioutil.ReadFile(responseFile(*replayDir, hash, seq))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+	}
+	return nil, errors.New(fmt.Sprint("No recorded response for ",
+		req.Method, " ", req.URL))
+}
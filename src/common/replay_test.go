@@ -0,0 +1,67 @@
+package common
+
+import "fmt"
+import "io/ioutil"
+import "net/http"
+import "net/http/httptest"
+import "net/url"
+import "os"
+import "strings"
+import "testing"
+
+func TestRecordReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "convoy_replay")
+	if err != nil {
+		t.Fatal("Can't make temp dir: ", err)
+	}
+	defer os.RemoveAll(dir)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			r.ParseForm()
+			fmt.Fprint(w, r.Method, " ", r.URL.Path, " ",
+				r.Form.Get("page"), " ", calls)
+		}))
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	fetch := func() []string {
+		var bodies []string
+		for _, page := range []string{"1", "2"} {
+			body, err := GetUrlFast(host, "/board", "")
+			if err != nil {
+				t.Fatal("GetUrlFast: ", err)
+			}
+			bodies = append(bodies, string(body))
+			body, err = PostUrl(host, "/board", "", url.Values{"page": {page}})
+			if err != nil {
+				t.Fatal("PostUrl: ", err)
+			}
+			bodies = append(bodies, string(body))
+		}
+		return bodies
+	}
+
+	*recordDir = dir
+	recorded := fetch()
+	*recordDir = ""
+	server.Close()
+
+	*replayDir = dir
+	client.Transport.(*replayTransport).counts = make(map[string]int)
+	replayed := fetch()
+	_, missingErr := GetUrlFast(host, "/missing", "")
+	*replayDir = ""
+
+	expect := []string{"GET /board  1", "POST /board 1 2",
+		"GET /board  3", "POST /board 2 4"}
+	for i, e := range expect {
+		if recorded[i] != e || replayed[i] != e {
+			t.Errorf("Response %d got %q, %q want %q",
+				i, recorded[i], replayed[i], e)
+		}
+	}
+	if missingErr == nil {
+		t.Errorf("Replayed a request that was never recorded")
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import "bytes"
-import "database/sql"
 import "flag"
 import "fmt"
 import "io/ioutil"
@@ -37,10 +36,16 @@ type cachedContent struct {
 }
 
 type proxyTransport struct {
-	transport   *http.Transport
+	transport   http.RoundTripper
 	scriptCache map[string]*cachedContent
 }
 
+var resume = flag.Bool("resume", false,
+	"Continue the last unfinished scrape, skipping its completed units")
+
+var boardNames = flag.String("boards", "trulos",
+	"Comma-separated load boards to read, e.g. trulos, trulos_http, feed")
+
 var (
 	reverseProxy *httputil.ReverseProxy
 	validPathRe  *regexp.Regexp
@@ -60,9 +65,9 @@ func init() {
 
 	reverseProxy = &httputil.ReverseProxy{
 		proxyRequest,
-		&proxyTransport{&http.Transport{
+		&proxyTransport{common.ReplayTransport(&http.Transport{
 			Proxy:              proxyUrl,
-			DisableCompression: true},
+			DisableCompression: true}),
 			make(map[string]*cachedContent),
 		},
 		time.Duration(0)}
@@ -213,9 +218,31 @@ func response(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// selectedBoards returns the names given by --boards.
+func selectedBoards() []string {
+	var names []string
+	for _, name := range strings.Split(*boardNames, ",") {
+		if name = strings.TrimSpace(name); len(name) != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// needsBrowser reports whether any selected board reads pages
+// through the browser.
+func needsBrowser() bool {
+	for _, name := range selectedBoards() {
+		if boards.NeedsBrowser(name) {
+			return true
+		}
+	}
+	return false
+}
+
 // loadBoard produces items for scraping on the channel.
-func loadBoard(loadf func([]*boards.Load) error) (boards.LoadBoard, error) {
-	tt, err := boards.NewTrulos(loadf)
+func loadBoard(name string, loadf boards.LoadFunc, skipf boards.SkipFunc) (boards.LoadBoard, error) {
+	tt, err := boards.New(name, loadf, skipf)
 	if err != nil {
 		return nil, err
 	}
@@ -225,32 +252,34 @@ func loadBoard(loadf func([]*boards.Load) error) (boards.LoadBoard, error) {
 	return tt, nil
 }
 
-func saveLoad(stmt *sql.Stmt, scrapeId int64, load *boards.Load) error {
-	_, err := stmt.Exec(
-		scrapeId,
-		load.PickupDate,
-		load.Origin.State,
-		load.Origin.City,
-		load.Dest.State,
-		load.Dest.City,
-		load.LoadType,
-		load.Length,
-		load.Weight,
-		load.Equipment,
-		load.Price,
-		load.Stops,
-		load.Phone)
-	return err
-}
-
-func processLoads(stmt *sql.Stmt, scrapeId int64,
-	loads []*boards.Load) error {
-	for _, load := range loads {
-		if err := saveLoad(stmt, scrapeId, load); err != nil {
-			return err
+// chooseScrape starts a new scrape, or with --resume continues the
+// last unfinished one.  Returns the ScrapeId and its completed units.
+func chooseScrape(cd *data.ConvoyData) (int64, map[string]bool, error) {
+	done := make(map[string]bool)
+	if *resume {
+		scrapeId, has, err := cd.LastUnfinishedScrape()
+		if err != nil {
+			return 0, nil, err
 		}
+		if has {
+			if err := cd.ForAllScrapeUnits(scrapeId, func(unit string) error {
+				done[unit] = true
+				return nil
+			}); err != nil {
+				return 0, nil, err
+			}
+			log.Print("Resuming ScrapeId = ", scrapeId,
+				" with ", len(done), " completed units")
+			return scrapeId, done, nil
+		}
+		log.Print("No unfinished scrape to resume")
+	}
+	scrapeId, err := cd.StartScrape()
+	if err != nil {
+		return 0, nil, err
 	}
-	return nil
+	log.Print("Starting ScrapeId = ", scrapeId)
+	return scrapeId, done, nil
 }
 
 func startScrape(pageCh chan<- scraper.Page, quitCh chan<- int) {
@@ -258,35 +287,32 @@ func startScrape(pageCh chan<- scraper.Page, quitCh chan<- int) {
 	if err != nil {
 		log.Fatal("Couldn't connect to database: ", err)
 	}
-	result, err := conn.Exec("INSERT INTO " + data.Table("Scrapes") +
-		" (StartTime) VALUES (NOW())")
-	if err != nil {
-		log.Fatal("Could not insert new Scrape: ", err)
+	if err := data.Migrate(conn); err != nil {
+		log.Fatal("Could not migrate database: ", err)
 	}
-	scrapeId, err := result.LastInsertId()
+	cd, err := data.NewConvoyData(conn)
 	if err != nil {
-		log.Fatal("Insert did not yield a ScrapeId: ", err)
+		log.Fatal("Could not prepare statements: ", err)
 	}
-	stmt, err := conn.Prepare(
-		"INSERT INTO " + data.Table("TruckLoads") +
-			" (ScrapeId, PickupDate, OriginState, OriginCity, " +
-			"DestState, DestCity, LoadType, Length, " +
-			"Weight, Equipment, Price, Stops, Phone) " +
-			" VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	scrapeId, done, err := chooseScrape(cd)
 	if err != nil {
-		log.Fatal("Could not prepare INSERT statement")
+		log.Fatal("Could not insert new Scrape: ", err)
 	}
-	board, err := loadBoard(func(loads []*boards.Load) error {
-		return processLoads(stmt, scrapeId, loads)
-	})
-	if err != nil {
-		log.Fatal("Couldn't initialize load board: ", err)
+	for _, name := range selectedBoards() {
+		board, err := loadBoard(name,
+			func(unit string, loads []*boards.Load) error {
+				return cd.AddUnitLoads(scrapeId, unit, loads)
+			}, func(unit string) bool {
+				return done[unit]
+			})
+		if err != nil {
+			log.Fatal("Couldn't initialize load board ", name, ": ", err)
+		}
+		board.Read(pageCh)
+	}
+	if err := cd.FinishScrape(scrapeId); err != nil {
+		log.Print("Could not finish Scrape: ", err)
 	}
-	log.Print("Starting ", data.Table("ScrapeId"), " = ", scrapeId)
-	board.Read(pageCh)
-	_, err = conn.Exec("UPDATE "+data.Table("Scrapes")+
-		" SET FinishTime = NOW() "+
-		"WHERE ScrapeId = ?", scrapeId)
 	conn.Close()
 	quitCh <- 1
 }
@@ -304,11 +330,13 @@ func main() {
 	pageCh := make(chan scraper.Page)
 	quitCh := make(chan int)
 
-	browser, err := startServer(pageCh)
-	if err != nil {
-		log.Fatalln("Failed to start HTTP server", err)
+	if needsBrowser() {
+		browser, err := startServer(pageCh)
+		if err != nil {
+			log.Fatalln("Failed to start HTTP server", err)
+		}
+		defer browser.Cleanup()
 	}
-	defer browser.Cleanup()
 
 	go startScrape(pageCh, quitCh)
 
@@ -1,39 +1,99 @@
 package data
 
 import "database/sql"
+import "errors"
 import "flag"
 import "log"
 import "strings"
 import "runtime"
-import _ "github.com/Go-SQL-Driver/MySQL"
 
 import "common"
 
 type TableName string
 
+// Storage is a database connection plus the SQL dialect needed to
+// talk to it.  Statements are prepared through Storage so that
+// ConvoyData does not depend on a particular database server.
+type Storage interface {
+	Prepare(query string) (*sql.Stmt, error)
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Begin() (*sql.Tx, error)
+	Close() error
+
+	// Driver returns the name of the database/sql driver.
+	Driver() string
+
+	// Table returns the name used to refer to a table in queries.
+	Table(t TableName) string
+	// Now returns an expression for the current date and time.
+	Now() string
+	// BinaryEquals returns a case-sensitive comparison of column
+	// against a single placeholder.
+	BinaryEquals(column string) string
+	// BinaryCollate returns a case-sensitive collation clause.
+	BinaryCollate() string
+}
+
+type storageOpener func(dsn string) (Storage, error)
+
 var dbName = flag.String("db_name", "", "Name of the DB")
+var dbDriver = flag.String("db_driver", "",
+	"Database driver, mysql or sqlite3; guessed from --db_dsn if empty")
+var dbDsn = flag.String("db_dsn", "",
+	"Data source name; overrides --db_name")
+
+var storageDrivers = make(map[string]storageOpener)
+
+func registerStorage(driver string, open storageOpener) {
+	storageDrivers[driver] = open
+}
+
+// guessDriver picks a driver for a DSN that looks like a file.
+func guessDriver(dsn string) string {
+	switch {
+	case dsn == ":memory:",
+		strings.HasPrefix(dsn, "file:"),
+		strings.HasSuffix(dsn, ".db"),
+		strings.HasSuffix(dsn, ".sqlite"),
+		strings.HasSuffix(dsn, ".sqlite3"):
+		return sqliteDriver
+	}
+	return mysqlDriver
+}
+
+// OpenDb opens and tests the database connection selected by
+// --db_driver, --db_dsn and --db_name.
+func OpenDb() (Storage, error) {
+	if len(*dbDsn) == 0 && len(*dbName) == 0 {
+		log.Fatal("Database not specified, use --db_name or --db_dsn")
+	}
+	driver := *dbDriver
+	if len(driver) == 0 {
+		driver = guessDriver(*dbDsn)
+	}
+	return OpenStorage(driver, *dbDsn)
+}
 
-// OpenDb opens and tests the database connection.
-func OpenDb() (*sql.DB, error) {
-	if len(*dbName) == 0 {
-		log.Fatal("Database not specified, use --db_name")
+// OpenStorage opens and tests a connection using the named driver.
+// An empty dsn uses the driver's default for --db_name.
+func OpenStorage(driver, dsn string) (Storage, error) {
+	open, has := storageDrivers[driver]
+	if !has {
+		return nil, errors.New("Unknown database driver: " + driver)
 	}
-	conn, err := sql.Open("mysql",
-		"test:@/"+*dbName+"?charset=utf8")
+	conn, err := open(dsn)
 	if err != nil {
-		return conn, err
+		return nil, err
 	}
 	// Test that the connection is good; because the driver call
 	// to open the database is defered until the first request.
-	_, err = conn.Exec("SELECT 1;")
-	if err != nil {
-		log.Fatal("Database not opened!", err)
+	if _, err = conn.Exec("SELECT 1;"); err != nil {
+		conn.Close()
+		return nil, err
 	}
-	return conn, err
-}
-
-func Table(s TableName) string {
-	return *dbName + "." + string(s)
+	return conn, nil
 }
 
 func insertPlaceHolders(columns []string) string {
@@ -52,27 +112,27 @@ func wherePlaceHolders(columns []string) string {
 	return strings.Join(parts, " AND ")
 }
 
-func InsertQuery(db *sql.DB, table TableName, columns ...string) (*sql.Stmt, error) {
+func InsertQuery(db Storage, table TableName, columns ...string) (*sql.Stmt, error) {
 	return db.Prepare("INSERT INTO " +
-		Table(table) + " (" + strings.Join(columns, ", ") + ") VALUES (" +
+		db.Table(table) + " (" + strings.Join(columns, ", ") + ") VALUES (" +
 		insertPlaceHolders(columns) + ")")
 }
 
-func SelectWhereQuery(db *sql.DB, table TableName, columns ...string) (*sql.Stmt, error) {
-	return db.Prepare("SELECT * FROM " + Table(table) +
+func SelectWhereQuery(db Storage, table TableName, columns ...string) (*sql.Stmt, error) {
+	return db.Prepare("SELECT * FROM " + db.Table(table) +
 		" WHERE " + wherePlaceHolders(columns))
 }
 
-func SelectGroupQuery(db *sql.DB, table TableName, columns ...string) (*sql.Stmt, error) {
+func SelectGroupQuery(db Storage, table TableName, columns ...string) (*sql.Stmt, error) {
 	cols := strings.Join(columns, ", ")
 	return db.Prepare("SELECT " + cols +
-		" FROM " + Table(table) +
+		" FROM " + db.Table(table) +
 		" GROUP BY " + cols)
 }
 
-func SelectAllQuery(db *sql.DB, table TableName, columns ...string) (*sql.Stmt, error) {
+func SelectAllQuery(db Storage, table TableName, columns ...string) (*sql.Stmt, error) {
 	cols := strings.Join(columns, ", ")
-	return db.Prepare("SELECT " + cols + " FROM " + Table(table))
+	return db.Prepare("SELECT " + cols + " FROM " + db.Table(table))
 }
 
 func HasRows(s *sql.Stmt, a ...interface{}) (bool, error) {
@@ -91,7 +151,13 @@ func HasRows(s *sql.Stmt, a ...interface{}) (bool, error) {
 }
 
 func ForAll(stmt *sql.Stmt, afunc func() error, a ...interface{}) error {
-	rows, err := stmt.Query()
+	return ForAllWith(stmt, nil, afunc, a...)
+}
+
+// ForAllWith is ForAll for a statement that takes arguments.
+func ForAllWith(stmt *sql.Stmt, args []interface{},
+	afunc func() error, a ...interface{}) error {
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		return err
 	}
@@ -111,7 +177,7 @@ func ForAll(stmt *sql.Stmt, afunc func() error, a ...interface{}) error {
 	return nil
 }
 
-func Main(body func (*sql.DB) error) {
+func Main(body func(Storage) error) {
 	flag.Parse()
 	argv := flag.Args()
 	runtime.GOMAXPROCS(common.NumCPU())
@@ -123,7 +189,10 @@ func Main(body func (*sql.DB) error) {
 		log.Fatal("Could not open database", err)
 	}
 	defer db.Close()
-	
+	if err := Migrate(db); err != nil {
+		log.Fatal("Could not migrate database", err)
+	}
+
 	if err := body(db); err != nil {
 		log.Fatal("Program error", err)
 	}
@@ -1,12 +1,12 @@
 package data
 
-import "database/sql"
 import "log"
 
 import "common"
 
-func FixCityNames(db *sql.DB, table, column string) error {
-	query := "SELECT DISTINCT (" + column + ") COLLATE utf8_bin FROM " + table
+func FixCityNames(db Storage, table, column string) error {
+	query := "SELECT DISTINCT (" + column + ") " + db.BinaryCollate() +
+		" FROM " + table
 	rows, err := db.Query(query)
 	if err != nil {
 		return err
@@ -32,7 +32,7 @@ func FixCityNames(db *sql.DB, table, column string) error {
 		return err
 	}
 	stmt, err := db.Prepare("UPDATE " + table + " SET " +
-		column + " = ? WHERE " + column + " LIKE BINARY ?")
+		column + " = ? WHERE " + db.BinaryEquals(column))
 	if err != nil {
 		log.Fatal("Could not prepare UPDATE statement")
 	}
@@ -0,0 +1,75 @@
+package data
+
+import "database/sql"
+import "errors"
+import "fmt"
+import "log"
+
+const (
+	SchemaVersion TableName = "SchemaVersion"
+)
+
+// LatestSchemaVersion is the schema version this program expects.
+func LatestSchemaVersion() int {
+	return schema[len(schema)-1].version
+}
+
+// CurrentSchemaVersion returns the highest applied migration, or 0
+// for a database that has never been migrated.
+func CurrentSchemaVersion(db Storage) (int, error) {
+	var version sql.NullInt64
+	row := db.QueryRow("SELECT MAX(Version) FROM " + db.Table(SchemaVersion))
+	if err := row.Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Migrate applies, in order, each migration newer than the current
+// schema version.  It refuses to touch a database whose schema is
+// newer than this program.
+func Migrate(db Storage) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " +
+		db.Table(SchemaVersion) + " (" +
+		"Version INTEGER NOT NULL PRIMARY KEY, " +
+		"Description VARCHAR(128) NOT NULL, " +
+		"AppliedTime VARCHAR(32) NOT NULL)"); err != nil {
+		return err
+	}
+	current, err := CurrentSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return errors.New(fmt.Sprint("Database schema version ", current,
+			" is newer than supported version ", latest))
+	}
+	record, err := db.Prepare("INSERT INTO " + db.Table(SchemaVersion) +
+		" (Version, Description, AppliedTime) VALUES (?, ?, " + db.Now() + ")")
+	if err != nil {
+		return err
+	}
+	defer record.Close()
+	for _, m := range schema {
+		if m.version <= current {
+			continue
+		}
+		stmts, has := m.statements[db.Driver()]
+		if !has {
+			return errors.New(fmt.Sprint("Schema version ", m.version,
+				" has no migration for ", db.Driver()))
+		}
+		log.Printf("Applying schema version %d: %s", m.version, m.description)
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return errors.New(fmt.Sprint("Schema version ",
+					m.version, " failed: ", err))
+			}
+		}
+		if _, err := record.Exec(m.version, m.description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
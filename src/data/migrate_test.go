@@ -0,0 +1,34 @@
+package data
+
+import "testing"
+
+func TestMigrate(t *testing.T) {
+	db, done := openTestStorage(t)
+	defer done()
+	latest := LatestSchemaVersion()
+	if v, err := CurrentSchemaVersion(db); err != nil || v != latest {
+		t.Errorf("Schema version got %v %v want %v", v, err, latest)
+	}
+	// A second migration is a no-op.
+	if err := Migrate(db); err != nil {
+		t.Error("Repeat migration failed: ", err)
+	}
+	for i, m := range schema {
+		if m.version != i+1 {
+			t.Errorf("Schema version %d out of order", m.version)
+		}
+		for _, driver := range []string{mysqlDriver, sqliteDriver} {
+			if len(m.statements[driver]) == 0 {
+				t.Errorf("Schema version %d missing %s", m.version, driver)
+			}
+		}
+	}
+	if _, err := db.Exec("INSERT INTO SchemaVersion "+
+		"(Version, Description, AppliedTime) VALUES (?, ?, ?)",
+		latest+1, "From the future", ""); err != nil {
+		t.Fatal("Insert failed: ", err)
+	}
+	if err := Migrate(db); err == nil {
+		t.Error("Migrated a newer schema")
+	}
+}
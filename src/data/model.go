@@ -9,6 +9,7 @@ import "geo"
 import "scraper"
 
 type ConvoyData struct {
+	db                     Storage
 	getAllMissingPlaces    *sql.Stmt
 	addCorrection          *sql.Stmt
 	hasCorrection          *sql.Stmt
@@ -20,6 +21,7 @@ type ConvoyData struct {
 	hasWikiUnknown         *sql.Stmt
 	addRoadDistance        *sql.Stmt
 	hasRoadDistance        *sql.Stmt
+	getRoadDistance        *sql.Stmt
 	getAllLocationPlaces   *sql.Stmt
 	getAllCorrectionPlaces *sql.Stmt
 	getAllLoadPlaces       *sql.Stmt
@@ -28,6 +30,12 @@ type ConvoyData struct {
 	getAllLocations        *sql.Stmt
 	getAllLoads            *sql.Stmt
 	getAllScrapes          *sql.Stmt
+	addScrape              *sql.Stmt
+	finishScrape           *sql.Stmt
+	addLoad                *sql.Stmt
+	addScrapeUnit          *sql.Stmt
+	getScrapeUnits         *sql.Stmt
+	getUnfinishedScrape    *sql.Stmt
 }
 
 const (
@@ -40,6 +48,7 @@ const (
 	UnknownCityStates TableName = "UnknownCityStates"
 	RoadDistance      TableName = "RoadDistance"
 	Scrapes           TableName = "Scrapes"
+	ScrapeUnits       TableName = "ScrapeUnits"
 )
 
 type CityFunc func(common.CityState) error
@@ -49,9 +58,9 @@ type CityPairLocFunc func(from, to geo.CityStateLoc) error
 type LoadFunc func(load boards.Load) error
 type ScrapeFunc func(scrape scraper.Scrape) error
 
-func NewConvoyData(db *sql.DB) (*ConvoyData, error) {
+func NewConvoyData(db Storage) (*ConvoyData, error) {
 	var err error
-	cd := &ConvoyData{}
+	cd := &ConvoyData{db: db}
 	if cd.addCorrection, err = InsertQuery(db, Corrections,
 		"InCity", "InState", "OutCity", "OutState", "Determined"); err != nil {
 		return nil, err
@@ -94,6 +103,12 @@ func NewConvoyData(db *sql.DB) (*ConvoyData, error) {
 		"DestCity", "DestState"); err != nil {
 		return nil, err
 	}
+	if cd.getRoadDistance, err = db.Prepare("SELECT Kilometers FROM " +
+		db.Table(RoadDistance) + " WHERE " +
+		wherePlaceHolders([]string{"SourceCity", "SourceState",
+			"DestCity", "DestState"})); err != nil {
+		return nil, err
+	}
 	if cd.getAllMissingPlaces, err = SelectGroupQuery(db, UnknownCityStates,
 		"C", "S"); err != nil {
 		return nil, err
@@ -126,13 +141,42 @@ func NewConvoyData(db *sql.DB) (*ConvoyData, error) {
 	if cd.getAllLoads, err = SelectAllQuery(db, TruckLoads,
 		"ScrapeId", "PickupDate", "OriginState", "OriginCity",
 		"DestState", "DestCity", "LoadType", "Length", "Weight",
-		"Equipment", "Price", "Stops", "Phone"); err != nil {
+		"Equipment", "Price", "Stops", "Phone", "Board",
+		"RateType", "RateCents", "RateText"); err != nil {
 		return nil, err
 	}
 	if cd.getAllScrapes, err = SelectAllQuery(db, Scrapes,
 		"ScrapeId", "StartTime", "FinishTime"); err != nil {
 		return nil, err
 	}
+	if cd.addScrape, err = db.Prepare("INSERT INTO " + db.Table(Scrapes) +
+		" (StartTime) VALUES (" + db.Now() + ")"); err != nil {
+		return nil, err
+	}
+	if cd.finishScrape, err = db.Prepare("UPDATE " + db.Table(Scrapes) +
+		" SET FinishTime = " + db.Now() + " WHERE ScrapeId = ?"); err != nil {
+		return nil, err
+	}
+	if cd.addLoad, err = InsertQuery(db, TruckLoads,
+		"ScrapeId", "PickupDate", "OriginState", "OriginCity",
+		"DestState", "DestCity", "LoadType", "Length", "Weight",
+		"Equipment", "Price", "Stops", "Phone", "Board",
+		"RateType", "RateCents", "RateText"); err != nil {
+		return nil, err
+	}
+	if cd.addScrapeUnit, err = db.Prepare("INSERT INTO " +
+		db.Table(ScrapeUnits) + " (ScrapeId, Unit, LoadCount, FinishTime)" +
+		" VALUES (?, ?, ?, " + db.Now() + ")"); err != nil {
+		return nil, err
+	}
+	if cd.getScrapeUnits, err = db.Prepare("SELECT Unit FROM " +
+		db.Table(ScrapeUnits) + " WHERE ScrapeId = ?"); err != nil {
+		return nil, err
+	}
+	if cd.getUnfinishedScrape, err = db.Prepare("SELECT MAX(ScrapeId) FROM " +
+		db.Table(Scrapes) + " WHERE FinishTime IS NULL"); err != nil {
+		return nil, err
+	}
 	return cd, nil
 }
 
@@ -156,6 +200,36 @@ func (cd *ConvoyData) HasRoadDistance(src, dest common.CityState) (bool, error)
 	return HasRows(cd.hasRoadDistance, src.City, src.State, dest.City, dest.State)
 }
 
+// GetRoadDistance returns the road distance between two places, if
+// it is known.
+func (cd *ConvoyData) GetRoadDistance(src, dest common.CityState) (int, bool, error) {
+	var kilometers int
+	err := cd.getRoadDistance.QueryRow(src.City, src.State,
+		dest.City, dest.State).Scan(&kilometers)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return kilometers, true, nil
+}
+
+// LoadPrice normalizes the rate of a load over its road distance.  It
+// returns false when the rate is unknown or the distance has not been
+// computed.
+func (cd *ConvoyData) LoadPrice(load *boards.Load) (boards.Price, bool, error) {
+	if load.Rate.Type == boards.RateUnknown {
+		return boards.Price{}, false, nil
+	}
+	kilometers, has, err := cd.GetRoadDistance(load.Origin, load.Dest)
+	if err != nil || !has {
+		return boards.Price{}, false, err
+	}
+	price, ok := load.Rate.Normalize(float64(kilometers))
+	return price, ok, nil
+}
+
 func (cd *ConvoyData) AddWikipediaUnknown(uri string) error {
 	_, err := cd.addWikiUnknown.Exec(uri)
 	return err
@@ -203,6 +277,99 @@ func (cd *ConvoyData) AddRoadDistance(src common.CityState,
 	return err
 }
 
+// StartScrape records the start of a new scrape and returns its id.
+func (cd *ConvoyData) StartScrape() (int64, error) {
+	result, err := cd.addScrape.Exec()
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (cd *ConvoyData) FinishScrape(scrapeId int64) error {
+	_, err := cd.finishScrape.Exec(scrapeId)
+	return err
+}
+
+func (cd *ConvoyData) AddLoad(scrapeId int64, load *boards.Load) error {
+	return execAddLoad(cd.addLoad, scrapeId, load)
+}
+
+func execAddLoad(stmt *sql.Stmt, scrapeId int64,
+	load *boards.Load) error {
+	_, err := stmt.Exec(
+		scrapeId,
+		common.FormatLoadDate(load.PickupDate),
+		load.Origin.State,
+		load.Origin.City,
+		load.Dest.State,
+		load.Dest.City,
+		load.LoadType,
+		load.Length,
+		load.Weight,
+		load.Equipment,
+		load.Rate.Dollars(),
+		load.Stops,
+		load.Phone,
+		load.Board,
+		load.Rate.Type.String(),
+		load.Rate.Cents,
+		load.Rate.Text)
+	return err
+}
+
+func (cd *ConvoyData) AddLoads(scrapeId int64, loads []*boards.Load) error {
+	for _, load := range loads {
+		if err := cd.AddLoad(scrapeId, load); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddUnitLoads saves the loads of one completed scrape unit and
+// records the unit as done, atomically, so that a resumed scrape
+// neither skips nor repeats it.
+func (cd *ConvoyData) AddUnitLoads(scrapeId int64, unit string,
+	loads []*boards.Load) error {
+	tx, err := cd.db.Begin()
+	if err != nil {
+		return err
+	}
+	addLoad := tx.Stmt(cd.addLoad)
+	for _, load := range loads {
+		if err := execAddLoad(addLoad, scrapeId, load); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Stmt(cd.addScrapeUnit).Exec(
+		scrapeId, unit, len(loads)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// LastUnfinishedScrape returns the newest scrape without a
+// FinishTime, if any.
+func (cd *ConvoyData) LastUnfinishedScrape() (int64, bool, error) {
+	var scrapeId sql.NullInt64
+	if err := cd.getUnfinishedScrape.QueryRow().Scan(&scrapeId); err != nil {
+		return 0, false, err
+	}
+	return scrapeId.Int64, scrapeId.Valid, nil
+}
+
+func (cd *ConvoyData) ForAllScrapeUnits(scrapeId int64,
+	ufunc func(unit string) error) error {
+	var unit []byte
+	return ForAllWith(cd.getScrapeUnits, []interface{}{scrapeId},
+		func() error {
+			return ufunc(string(unit))
+		}, &unit)
+}
+
 func (cd *ConvoyData) ForAllLoadPlaces(csfunc CityFunc) error {
 	return forAllCities(cd.getAllLoadPlaces, csfunc)
 }
@@ -314,23 +481,26 @@ func (cd *ConvoyData) ForAllLoads(loadFunc LoadFunc) error {
 	// The following is somewhat convoluted, avoids
 	// "closure needs too many variables; runtime will reject it"
 	var scrapeId int64
-	var ints [4]int
-	var strings [7][]byte
+	var ints [5]int
+	var strings [10][]byte
 	var loadTime []byte
 	return ForAll(cd.getAllLoads, func() error {
 		tm, err := common.ParseLoadDate(string(loadTime))
 		if err != nil {
 			return err
 		}
-		return loadFunc(boards.Load{scrapeId, tm,
+		return loadFunc(boards.Load{scrapeId, string(strings[7]), tm,
 			common.CityState{string(strings[1]), string(strings[0])},
 			common.CityState{string(strings[3]), string(strings[2])},
 			string(strings[4]), ints[0], ints[1],
-			string(strings[5]), ints[2], ints[3],
-			string(strings[6])})
+			string(strings[5]),
+			boards.Rate{boards.ParseRateType(string(strings[8])),
+				ints[4], string(strings[9])},
+			ints[3], string(strings[6])})
 	}, &scrapeId, &loadTime, &strings[0], &strings[1], &strings[2], &strings[3],
 		&strings[4], &ints[0], &ints[1], &strings[5],
-		&ints[2], &ints[3], &strings[6])
+		&ints[2], &ints[3], &strings[6], &strings[7],
+		&strings[8], &ints[4], &strings[9])
 }
 
 func (cd *ConvoyData) ForAllScrapes(sfunc ScrapeFunc) error {
@@ -0,0 +1,51 @@
+package data
+
+import "database/sql"
+import _ "github.com/Go-SQL-Driver/MySQL"
+
+const mysqlDriver = "mysql"
+
+type mysqlStorage struct {
+	*sql.DB
+	name string
+}
+
+func init() {
+	registerStorage(mysqlDriver, openMysql)
+}
+
+func openMysql(dsn string) (Storage, error) {
+	name := ""
+	if len(dsn) == 0 {
+		name = *dbName
+		dsn = "test:@/" + name + "?charset=utf8"
+	}
+	conn, err := sql.Open(mysqlDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &mysqlStorage{conn, name}, nil
+}
+
+func (m *mysqlStorage) Driver() string {
+	return mysqlDriver
+}
+
+func (m *mysqlStorage) Table(t TableName) string {
+	if len(m.name) == 0 {
+		return string(t)
+	}
+	return m.name + "." + string(t)
+}
+
+func (m *mysqlStorage) Now() string {
+	return "NOW()"
+}
+
+func (m *mysqlStorage) BinaryEquals(column string) string {
+	return column + " LIKE BINARY ?"
+}
+
+func (m *mysqlStorage) BinaryCollate() string {
+	return "COLLATE utf8_bin"
+}
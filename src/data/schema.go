@@ -0,0 +1,230 @@
+package data
+
+// The schema, as an ordered list of up-migrations.  Migrations are
+// never edited once released; add a new version instead.  Each
+// statement is executed separately because the MySQL driver does not
+// accept multiple statements per Exec.
+
+type migration struct {
+	version     int
+	description string
+	statements  map[string][]string // Keyed by driver name
+}
+
+var schema = []migration{
+	{1, "Initial schema", map[string][]string{
+		mysqlDriver: []string{`
+CREATE TABLE IF NOT EXISTS Scrapes (
+       ScrapeId		BIGINT		NOT NULL AUTO_INCREMENT,
+       StartTime	DATETIME	NOT NULL,
+       FinishTime	DATETIME,
+       PRIMARY KEY (ScrapeId))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`, `
+CREATE TABLE IF NOT EXISTS TruckLoads (
+       ScrapeId		BIGINT		NOT NULL,
+       PickupDate	DATE		NOT NULL,
+       OriginState	CHAR(2) 	NOT NULL,
+       OriginCity	VARCHAR(64) 	NOT NULL,
+       DestState	CHAR(2) 	NOT NULL,
+       DestCity		VARCHAR(64) 	NOT NULL,
+       LoadType		VARCHAR(16) 	NOT NULL,
+       Length		INTEGER 	NOT NULL,
+       Weight		INTEGER 	NOT NULL,
+       Equipment	VARCHAR(64) 	NOT NULL,
+       Price		INTEGER 	NOT NULL,
+       Stops		INTEGER 	NOT NULL,
+       Phone		VARCHAR(16) 	NOT NULL,
+
+       INDEX OCityState	 (OriginCity, OriginState) USING HASH,
+       INDEX DCityState	 (DestCity, DestState) USING HASH,
+       FOREIGN KEY (ScrapeId) REFERENCES Scrapes(ScrapeId))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`, `
+CREATE TABLE IF NOT EXISTS Corrections (
+       InCity 		 VARCHAR(64)	NOT NULL,
+       InState		 CHAR(2)	NOT NULL,
+       OutCity 		 VARCHAR(64)	NOT NULL,
+       OutState		 CHAR(2)	NOT NULL,
+       Determined        VARCHAR(64)	NOT NULL,
+
+       INDEX ICityState	 (InCity, InState) USING HASH,
+       INDEX OCityState	 (OutCity, OutState) USING HASH,
+       PRIMARY KEY (InCity, InState))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`, `
+CREATE TABLE IF NOT EXISTS Locations (
+       Id    	    	 BIGINT		NOT NULL AUTO_INCREMENT,
+       LocCity 		 VARCHAR(64)	NOT NULL,
+       LocState		 CHAR(2)	NOT NULL,
+       Latitude		 DOUBLE		NOT NULL,
+       Longitude	 DOUBLE		NOT NULL,
+       Determined        VARCHAR(64)	NOT NULL,
+
+       INDEX LCityState	 (LocCity, LocState) USING HASH,
+       PRIMARY KEY (Id))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`, `
+CREATE TABLE IF NOT EXISTS GoogleUnknown (
+       UnknownCity   	   VARCHAR(64)	NOT NULL,
+       UnknownState	   CHAR(2)	NOT NULL,
+
+       INDEX GUCityState   (UnknownCity, UnknownState) USING HASH,
+       PRIMARY KEY (UnknownCity, UnknownState))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`, `
+CREATE TABLE IF NOT EXISTS WikipediaUnknown (
+       UnknownUri   	   VARCHAR(128)	NOT NULL,
+
+       INDEX UUri  (UnknownUri) USING HASH,
+       PRIMARY KEY (UnknownUri))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`, `
+CREATE TABLE IF NOT EXISTS RoadDistance (
+       SourceCity	   VARCHAR(64)	NOT NULL,
+       SourceState	   CHAR(2)	NOT NULL,
+       DestCity		   VARCHAR(64)	NOT NULL,
+       DestState	   CHAR(2)	NOT NULL,
+       Kilometers	   INTEGER	NOT NULL,
+
+       PRIMARY KEY (SourceCity, SourceState, DestCity, DestState))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`, `
+CREATE OR REPLACE VIEW LoadCityStates (C, S) AS
+       SELECT OriginCity C, OriginState S FROM TruckLoads
+       UNION ALL SELECT DestCity C, DestState S FROM TruckLoads`, `
+CREATE OR REPLACE VIEW GeoCityStates (C, S) AS
+       SELECT InCity C, InState S FROM Corrections
+       UNION ALL SELECT LocCity C, LocState S FROM Locations`, `
+CREATE OR REPLACE VIEW LoadCityStatesGrouped (C, S) AS
+       SELECT C, S FROM LoadCityStates GROUP BY C, S`, `
+CREATE OR REPLACE VIEW GeoCityStatesGrouped (C, S) AS
+       SELECT C, S FROM GeoCityStates GROUP BY C, S`, `
+CREATE OR REPLACE VIEW UnknownCityStates (C, S) AS
+       SELECT L.C, L.S FROM LoadCityStatesGrouped AS L
+       WHERE NOT EXISTS (SELECT 1 FROM GeoCityStatesGrouped AS G
+                         WHERE G.C = L.C AND G.S = L.S)`,
+		},
+		sqliteDriver: []string{`
+CREATE TABLE IF NOT EXISTS Scrapes (
+       ScrapeId		INTEGER		PRIMARY KEY AUTOINCREMENT,
+       StartTime	TEXT		NOT NULL,
+       FinishTime	TEXT)`, `
+CREATE TABLE IF NOT EXISTS TruckLoads (
+       ScrapeId		INTEGER		NOT NULL REFERENCES Scrapes(ScrapeId),
+       PickupDate	TEXT		NOT NULL,
+       OriginState	TEXT	 	NOT NULL,
+       OriginCity	TEXT	 	NOT NULL,
+       DestState	TEXT	 	NOT NULL,
+       DestCity		TEXT	 	NOT NULL,
+       LoadType		TEXT	 	NOT NULL,
+       Length		INTEGER 	NOT NULL,
+       Weight		INTEGER 	NOT NULL,
+       Equipment	TEXT	 	NOT NULL,
+       Price		INTEGER 	NOT NULL,
+       Stops		INTEGER 	NOT NULL,
+       Phone		TEXT	 	NOT NULL)`, `
+CREATE INDEX IF NOT EXISTS OCityState ON TruckLoads (OriginCity, OriginState)`, `
+CREATE INDEX IF NOT EXISTS DCityState ON TruckLoads (DestCity, DestState)`, `
+CREATE TABLE IF NOT EXISTS Corrections (
+       InCity 		 TEXT		NOT NULL,
+       InState		 TEXT		NOT NULL,
+       OutCity 		 TEXT		NOT NULL,
+       OutState		 TEXT		NOT NULL,
+       Determined        TEXT		NOT NULL,
+       PRIMARY KEY (InCity, InState))`, `
+CREATE INDEX IF NOT EXISTS CorrOCityState ON Corrections (OutCity, OutState)`, `
+CREATE TABLE IF NOT EXISTS Locations (
+       Id    	    	 INTEGER	PRIMARY KEY AUTOINCREMENT,
+       LocCity 		 TEXT		NOT NULL,
+       LocState		 TEXT		NOT NULL,
+       Latitude		 REAL		NOT NULL,
+       Longitude	 REAL		NOT NULL,
+       Determined        TEXT		NOT NULL)`, `
+CREATE INDEX IF NOT EXISTS LCityState ON Locations (LocCity, LocState)`, `
+CREATE TABLE IF NOT EXISTS GoogleUnknown (
+       UnknownCity   	   TEXT		NOT NULL,
+       UnknownState	   TEXT		NOT NULL,
+       PRIMARY KEY (UnknownCity, UnknownState))`, `
+CREATE TABLE IF NOT EXISTS WikipediaUnknown (
+       UnknownUri   	   TEXT		NOT NULL PRIMARY KEY)`, `
+CREATE TABLE IF NOT EXISTS RoadDistance (
+       SourceCity	   TEXT		NOT NULL,
+       SourceState	   TEXT		NOT NULL,
+       DestCity		   TEXT		NOT NULL,
+       DestState	   TEXT		NOT NULL,
+       Kilometers	   INTEGER	NOT NULL,
+       PRIMARY KEY (SourceCity, SourceState, DestCity, DestState))`, `
+CREATE VIEW IF NOT EXISTS LoadCityStates (C, S) AS
+       SELECT OriginCity C, OriginState S FROM TruckLoads
+       UNION ALL SELECT DestCity C, DestState S FROM TruckLoads`, `
+CREATE VIEW IF NOT EXISTS GeoCityStates (C, S) AS
+       SELECT InCity C, InState S FROM Corrections
+       UNION ALL SELECT LocCity C, LocState S FROM Locations`, `
+CREATE VIEW IF NOT EXISTS LoadCityStatesGrouped (C, S) AS
+       SELECT C, S FROM LoadCityStates GROUP BY C, S`, `
+CREATE VIEW IF NOT EXISTS GeoCityStatesGrouped (C, S) AS
+       SELECT C, S FROM GeoCityStates GROUP BY C, S`, `
+CREATE VIEW IF NOT EXISTS UnknownCityStates (C, S) AS
+       SELECT L.C, L.S FROM LoadCityStatesGrouped AS L
+       WHERE NOT EXISTS (SELECT 1 FROM GeoCityStatesGrouped AS G
+                         WHERE G.C = L.C AND G.S = L.S)`,
+		},
+	}},
+	{2, "Scrape units for resuming scrapes", map[string][]string{
+		mysqlDriver: []string{`
+CREATE TABLE IF NOT EXISTS ScrapeUnits (
+       ScrapeId		BIGINT		NOT NULL,
+       Unit		VARCHAR(128)	NOT NULL,
+       LoadCount	INTEGER		NOT NULL,
+       FinishTime	DATETIME	NOT NULL,
+
+       PRIMARY KEY (ScrapeId, Unit),
+       FOREIGN KEY (ScrapeId) REFERENCES Scrapes(ScrapeId))
+       CHARACTER SET = utf8,
+       COLLATE = utf8_bin`,
+		},
+		sqliteDriver: []string{`
+CREATE TABLE IF NOT EXISTS ScrapeUnits (
+       ScrapeId		INTEGER		NOT NULL REFERENCES Scrapes(ScrapeId),
+       Unit		TEXT		NOT NULL,
+       LoadCount	INTEGER		NOT NULL,
+       FinishTime	TEXT		NOT NULL,
+       PRIMARY KEY (ScrapeId, Unit))`,
+		},
+	}},
+	{3, "Load board recorded with each load", map[string][]string{
+		mysqlDriver: []string{`
+ALTER TABLE TruckLoads
+      ADD COLUMN Board VARCHAR(32) NOT NULL DEFAULT 'trulos'`,
+		},
+		sqliteDriver: []string{`
+ALTER TABLE TruckLoads
+      ADD COLUMN Board TEXT NOT NULL DEFAULT 'trulos'`,
+		},
+	}},
+	{4, "Typed load rates, flat or per mile", map[string][]string{
+		mysqlDriver: []string{`
+ALTER TABLE TruckLoads
+      ADD COLUMN RateType VARCHAR(16) NOT NULL DEFAULT 'unknown',
+      ADD COLUMN RateCents INTEGER NOT NULL DEFAULT 0,
+      ADD COLUMN RateText VARCHAR(64) NOT NULL DEFAULT ''`, `
+UPDATE TruckLoads
+       SET RateType = 'flat', RateCents = Price * 100,
+           RateText = CAST(Price AS CHAR)
+       WHERE Price > 0`,
+		},
+		sqliteDriver: []string{`
+ALTER TABLE TruckLoads
+      ADD COLUMN RateType TEXT NOT NULL DEFAULT 'unknown'`, `
+ALTER TABLE TruckLoads
+      ADD COLUMN RateCents INTEGER NOT NULL DEFAULT 0`, `
+ALTER TABLE TruckLoads
+      ADD COLUMN RateText TEXT NOT NULL DEFAULT ''`, `
+UPDATE TruckLoads
+       SET RateType = 'flat', RateCents = Price * 100,
+           RateText = CAST(Price AS TEXT)
+       WHERE Price > 0`,
+		},
+	}},
+}
@@ -0,0 +1,71 @@
+package data
+
+import "database/sql"
+import "fmt"
+import "strings"
+import "sync/atomic"
+import _ "github.com/mattn/go-sqlite3"
+
+const sqliteDriver = "sqlite3"
+
+// sqliteOptions make concurrent writers, such as the scrapers saving
+// loads, wait for each other instead of failing with "database is
+// locked": a transaction takes the write lock when it begins, and
+// waits up to 10s for it.
+const sqliteOptions = "_busy_timeout=10000&_txlock=immediate"
+
+// memoryStores counts the stores opened on ":memory:".  Each gets its
+// own database, named so that all connections of its pool share it;
+// a plain ":memory:" connection sees only its own empty database.
+var memoryStores int32
+
+// sqliteStorage is an embedded database in a single file, for use
+// without a database server.  SQLite compares TEXT with the BINARY
+// collation by default, so plain equality is case-sensitive.
+type sqliteStorage struct {
+	*sql.DB
+}
+
+func init() {
+	registerStorage(sqliteDriver, openSqlite)
+}
+
+func openSqlite(dsn string) (Storage, error) {
+	if len(dsn) == 0 {
+		dsn = *dbName + ".db"
+	}
+	if dsn == ":memory:" {
+		dsn = fmt.Sprintf("file:convoy-memory-%d?mode=memory&cache=shared",
+			atomic.AddInt32(&memoryStores, 1))
+	}
+	if strings.Contains(dsn, "?") {
+		dsn += "&" + sqliteOptions
+	} else {
+		dsn += "?" + sqliteOptions
+	}
+	conn, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStorage{conn}, nil
+}
+
+func (s *sqliteStorage) Driver() string {
+	return sqliteDriver
+}
+
+func (s *sqliteStorage) Table(t TableName) string {
+	return string(t)
+}
+
+func (s *sqliteStorage) Now() string {
+	return "datetime('now')"
+}
+
+func (s *sqliteStorage) BinaryEquals(column string) string {
+	return column + " = ?"
+}
+
+func (s *sqliteStorage) BinaryCollate() string {
+	return "COLLATE BINARY"
+}
@@ -0,0 +1,234 @@
+package data
+
+import "io/ioutil"
+import "os"
+import "path"
+import "testing"
+import "time"
+
+import "boards"
+import "common"
+import "geo"
+import "scraper"
+
+func openTestStorage(t *testing.T) (Storage, func()) {
+	dir, err := ioutil.TempDir("", "convoy_data")
+	if err != nil {
+		t.Fatal("Can't make temp dir: ", err)
+	}
+	db, err := OpenStorage(sqliteDriver, path.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal("Can't open sqlite: ", err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal("Can't create schema: ", err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGuessDriver(t *testing.T) {
+	for dsn, driver := range map[string]string{
+		"":                    mysqlDriver,
+		"test:@/Convoy":       mysqlDriver,
+		"convoy.db":           sqliteDriver,
+		"file:convoy.sqlite3": sqliteDriver,
+		":memory:":            sqliteDriver,
+	} {
+		if d := guessDriver(dsn); d != driver {
+			t.Errorf("Driver for %q got %v want %v", dsn, d, driver)
+		}
+	}
+}
+
+func TestSqliteMemory(t *testing.T) {
+	db, err := OpenStorage(sqliteDriver, ":memory:")
+	if err != nil {
+		t.Fatal("Can't open sqlite: ", err)
+	}
+	defer db.Close()
+	if err := Migrate(db); err != nil {
+		t.Fatal("Can't create schema: ", err)
+	}
+	// The open rows hold one connection, so the count takes another.
+	rows, err := db.Query("SELECT Version FROM SchemaVersion")
+	if err != nil {
+		t.Fatal("Query: ", err)
+	}
+	defer rows.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM SchemaVersion").
+		Scan(&count); err != nil || count != LatestSchemaVersion() {
+		t.Errorf("Schema versions got %v %v want %v",
+			count, err, LatestSchemaVersion())
+	}
+	// Another store starts empty.
+	other, err := OpenStorage(sqliteDriver, ":memory:")
+	if err != nil {
+		t.Fatal("Can't open sqlite: ", err)
+	}
+	defer other.Close()
+	if v, err := CurrentSchemaVersion(other); err == nil {
+		t.Errorf("New store has schema version %v", v)
+	}
+}
+
+func TestSqliteLocations(t *testing.T) {
+	db, done := openTestStorage(t)
+	defer done()
+	cd, err := NewConvoyData(db)
+	if err != nil {
+		t.Fatal("NewConvoyData: ", err)
+	}
+	cs := common.CityState{"Portland", "OR"}
+	if has, err := cd.HasLocation(cs); err != nil || has {
+		t.Errorf("Unexpected location: %v %v", has, err)
+	}
+	if err := cd.AddLocation(cs, geo.SphereCoords{45.5, -122.7},
+		"/wiki/Portland,_Oregon"); err != nil {
+		t.Fatal("AddLocation: ", err)
+	}
+	if has, err := cd.HasLocation(cs); err != nil || !has {
+		t.Errorf("Missing location: %v %v", has, err)
+	}
+	if has, _ := cd.HasLocation(common.CityState{"portland", "OR"}); has {
+		t.Errorf("Location lookup is not case-sensitive")
+	}
+	count := 0
+	if err := cd.ForAllLocations(func(id int64, csl geo.CityStateLoc) error {
+		count++
+		if csl.CityState != cs || csl.Lat != 45.5 {
+			t.Errorf("Incorrect location: %v", csl)
+		}
+		return nil
+	}); err != nil {
+		t.Error("ForAllLocations: ", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 location, got %d", count)
+	}
+}
+
+func TestSqliteLoads(t *testing.T) {
+	db, done := openTestStorage(t)
+	defer done()
+	cd, err := NewConvoyData(db)
+	if err != nil {
+		t.Fatal("NewConvoyData: ", err)
+	}
+	scrapeId, err := cd.StartScrape()
+	if err != nil {
+		t.Fatal("StartScrape: ", err)
+	}
+	pickup := time.Date(2013, time.April, 2, 0, 0, 0, 0, time.UTC)
+	load := &boards.Load{0, "feed", pickup,
+		common.CityState{"Salem", "OR"}, common.CityState{"Boise", "ID"},
+		"Full", 48, 40000, "Flatbed", boards.ParseRate("$2.15/mi"), 0,
+		"555-1212"}
+	if err := cd.AddLoads(scrapeId, []*boards.Load{load}); err != nil {
+		t.Fatal("AddLoads: ", err)
+	}
+	if err := cd.FinishScrape(scrapeId); err != nil {
+		t.Fatal("FinishScrape: ", err)
+	}
+	var loads []boards.Load
+	if err := cd.ForAllLoads(func(l boards.Load) error {
+		loads = append(loads, l)
+		return nil
+	}); err != nil {
+		t.Fatal("ForAllLoads: ", err)
+	}
+	expect := *load
+	expect.ScrapeId = scrapeId
+	if len(loads) != 1 || loads[0] != expect {
+		t.Errorf("Incorrect loads: %v want %v", loads, expect)
+	}
+	if _, has, err := cd.LoadPrice(load); has || err != nil {
+		t.Errorf("Priced a load without distance: %v", err)
+	}
+	if err := cd.AddRoadDistance(load.Origin, load.Dest, 644); err != nil {
+		t.Fatal("AddRoadDistance: ", err)
+	}
+	if price, has, err := cd.LoadPrice(load); !has || err != nil ||
+		price.PerMileCents != 215 || price.TotalCents != 86035 {
+		t.Errorf("Incorrect price: %v %v %v", price, has, err)
+	}
+	if err := cd.ForAllScrapes(func(s scraper.Scrape) error {
+		if s.ScrapeId != scrapeId || s.StartTime.IsZero() ||
+			s.FinishTime.IsZero() {
+			t.Errorf("Incomplete scrape: %v", s)
+		}
+		return nil
+	}); err != nil {
+		t.Error("ForAllScrapes: ", err)
+	}
+	var missing []common.CityState
+	if err := cd.ForAllMissingCities(func(cs common.CityState) error {
+		missing = append(missing, cs)
+		return nil
+	}); err != nil {
+		t.Error("ForAllMissingCities: ", err)
+	}
+	if len(missing) != 2 {
+		t.Errorf("Expected 2 missing cities, got %v", missing)
+	}
+}
+
+func TestSqliteScrapeUnits(t *testing.T) {
+	db, done := openTestStorage(t)
+	defer done()
+	cd, err := NewConvoyData(db)
+	if err != nil {
+		t.Fatal("NewConvoyData: ", err)
+	}
+	if _, has, err := cd.LastUnfinishedScrape(); err != nil || has {
+		t.Errorf("Unexpected unfinished scrape: %v %v", has, err)
+	}
+	scrapeId, err := cd.StartScrape()
+	if err != nil {
+		t.Fatal("StartScrape: ", err)
+	}
+	load := &boards.Load{0, "trulos", time.Now(),
+		common.CityState{"Salem", "OR"}, common.CityState{"Boise", "ID"},
+		"Full", 48, 40000, "Van", boards.ParseRate("1200"), 0, "555-1212"}
+	if err := cd.AddUnitLoads(scrapeId, "Trulos-OR-Van",
+		[]*boards.Load{load, load}); err != nil {
+		t.Fatal("AddUnitLoads: ", err)
+	}
+	if id, has, err := cd.LastUnfinishedScrape(); err != nil || !has ||
+		id != scrapeId {
+		t.Errorf("Unfinished scrape got %v %v %v want %v",
+			id, has, err, scrapeId)
+	}
+	var units []string
+	if err := cd.ForAllScrapeUnits(scrapeId, func(unit string) error {
+		units = append(units, unit)
+		return nil
+	}); err != nil {
+		t.Error("ForAllScrapeUnits: ", err)
+	}
+	if len(units) != 1 || units[0] != "Trulos-OR-Van" {
+		t.Errorf("Incorrect units: %v", units)
+	}
+	// A unit is recorded at most once, with none of its loads.
+	if err := cd.AddUnitLoads(scrapeId, "Trulos-OR-Van",
+		[]*boards.Load{load}); err == nil {
+		t.Error("Repeated unit was accepted")
+	}
+	count := 0
+	cd.ForAllLoads(func(l boards.Load) error {
+		count++
+		return nil
+	})
+	if count != 2 {
+		t.Errorf("Expected 2 loads, got %d", count)
+	}
+	if err := cd.FinishScrape(scrapeId); err != nil {
+		t.Fatal("FinishScrape: ", err)
+	}
+	if _, has, err := cd.LastUnfinishedScrape(); err != nil || has {
+		t.Errorf("Unexpected unfinished scrape: %v %v", has, err)
+	}
+}
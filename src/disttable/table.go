@@ -0,0 +1,88 @@
+// Package disttable reads and writes the distance tables that osrmtool
+// computes, one file per origin city.
+
+package disttable
+
+import "bufio"
+import "bytes"
+import "compress/zlib"
+import "encoding/binary"
+import "io"
+import "io/ioutil"
+import "os"
+import "path"
+
+import "common"
+
+// IdPair is a pair of Locations Ids.
+type IdPair struct {
+	From, To int64
+}
+
+type PairStat struct {
+	Meters, Seconds int32
+}
+
+type PairMap map[IdPair]PairStat
+
+// TableEntry is the route to one destination.  A zero To marks a
+// destination without a route.
+type TableEntry struct {
+	To int64
+	PairStat
+}
+
+func TablePath(dir string, from common.CityState) string {
+	return path.Join(dir, from.String())
+}
+
+// ReadTable reads the routes from the city with Locations Id from.
+func ReadTable(dir string, from common.CityState, fromId int64) (PairMap, error) {
+	m := make(PairMap)
+	table, err := ioutil.ReadFile(TablePath(dir, from))
+	if err != nil {
+		return nil, err
+	}
+	r, err := zlib.NewReader(bytes.NewBuffer(table))
+	if err != nil {
+		return nil, err
+	}
+	rb := bufio.NewReader(r)
+	for {
+		i1, err1 := binary.ReadVarint(rb)
+		if err1 == io.EOF {
+			return m, nil
+		}
+		if err1 != nil {
+			return nil, err1
+		}
+		i2, err2 := binary.ReadVarint(rb)
+		if err2 != nil {
+			return nil, err2
+		}
+		i3, err3 := binary.ReadVarint(rb)
+		if err3 != nil {
+			return nil, err3
+		}
+		if i1 == 0 {
+			continue
+		}
+		m[IdPair{fromId, i1}] = PairStat{int32(i2), int32(i3)}
+	}
+}
+
+// WriteTable writes the routes from a city.
+func WriteTable(dir string, from common.CityState, entries []TableEntry) error {
+	buf := make([]byte, 3*binary.MaxVarintLen32*len(entries))
+	pos := 0
+	for _, e := range entries {
+		pos += binary.PutVarint(buf[pos:], e.To)
+		pos += binary.PutVarint(buf[pos:], int64(e.Meters))
+		pos += binary.PutVarint(buf[pos:], int64(e.Seconds))
+	}
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(buf[0:pos])
+	w.Close()
+	return ioutil.WriteFile(TablePath(dir, from), b.Bytes(), os.ModePerm)
+}
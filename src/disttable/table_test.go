@@ -0,0 +1,32 @@
+package disttable
+
+import "io/ioutil"
+import "os"
+import "testing"
+
+import "common"
+
+func TestTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disttable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	from := common.CityState{City: "Salem", State: "OR"}
+	entries := []TableEntry{{2, PairStat{74000, 3600}},
+		{0, PairStat{0, 0}}, {9, PairStat{700000, 25000}}}
+	if err := WriteTable(dir, from, entries); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadTable(dir, from, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m[IdPair{1, 2}] != entries[0].PairStat ||
+		m[IdPair{1, 9}] != entries[2].PairStat {
+		t.Errorf("Incorrect table: %v", m)
+	}
+	if _, err := ReadTable(dir, common.CityState{City: "Boise", State: "ID"}, 2); err == nil {
+		t.Errorf("Read a missing table")
+	}
+}
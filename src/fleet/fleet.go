@@ -0,0 +1,196 @@
+// Package fleet assigns loads to a fleet of trucks: a savings
+// heuristic builds chains of loads, the chains are assigned to trucks,
+// and a local search relocates loads between trucks.
+
+package fleet
+
+import "math"
+import "strings"
+import "time"
+
+import "boards"
+import "common"
+import "trips"
+
+const kilometersPerMile = 1.609344
+
+type Truck struct {
+	Name      string
+	Home      common.CityState
+	Equipment []string
+	MaxWeight int // Pounds, 0 if unlimited
+	MaxLength int // Feet, 0 if unlimited
+}
+
+type Options struct {
+	Start   time.Time
+	Horizon time.Duration
+	// Cost in cents of each empty kilometer.
+	DeadheadCost float64
+	// Hours a day that a truck can drive.
+	DrivingHours float64
+	// Time at each pickup and delivery.
+	Handling time.Duration
+	// Whether trucks drive home empty after their last delivery.
+	ReturnHome bool
+	// Rounds of local search.
+	Iterations int
+}
+
+// Stop is a load hauled by a truck, preceded by an empty drive.
+type Stop struct {
+	Load     *boards.Load
+	EmptyKm  float64
+	LoadedKm float64
+	Pickup   time.Time
+	Deliver  time.Time
+	Revenue  int // Cents
+}
+
+type Route struct {
+	Truck    *Truck
+	Stops    []*Stop
+	Revenue  int // Cents
+	EmptyKm  float64
+	LoadedKm float64
+	Profit   int // Cents
+}
+
+type Solution struct {
+	Routes     []*Route
+	Unassigned []*boards.Load
+	Revenue    int // Cents
+	EmptyKm    float64
+	LoadedKm   float64
+	Profit     int // Cents
+}
+
+func (s *Solution) EmptyMiles() float64 {
+	return s.EmptyKm / kilometersPerMile
+}
+
+// Carries reports whether the truck is equipped for a load.
+func (t *Truck) Carries(load *boards.Load) bool {
+	if t.MaxWeight != 0 && load.Weight > t.MaxWeight {
+		return false
+	}
+	if t.MaxLength != 0 && load.Length > t.MaxLength {
+		return false
+	}
+	for _, e := range t.Equipment {
+		if strings.EqualFold(e, load.Equipment) {
+			return true
+		}
+	}
+	return false
+}
+
+type solver struct {
+	opts   Options
+	travel trips.TravelFunc
+}
+
+func (s *solver) elapsed(seconds int) time.Duration {
+	d := time.Duration(seconds) * time.Second
+	if s.opts.DrivingHours > 0 && s.opts.DrivingHours < 24 {
+		d = time.Duration(float64(d) * 24 / s.opts.DrivingHours)
+	}
+	return d
+}
+
+// evaluate schedules loads in order on a truck starting from its home,
+// or from the first origin if truck is nil.  It returns nil if the
+// route is infeasible.
+func (s *solver) evaluate(truck *Truck, loads []*boards.Load) (*Route, error) {
+	r := &Route{Truck: truck}
+	if len(loads) == 0 {
+		return r, nil
+	}
+	at := loads[0].Origin
+	if truck != nil {
+		at = truck.Home
+	}
+	free := s.opts.Start
+	end := s.opts.Start.Add(s.opts.Horizon)
+	for _, load := range loads {
+		if truck != nil && !truck.Carries(load) {
+			return nil, nil
+		}
+		dm, ds, ok, err := s.travel(at, load.Origin)
+		if err != nil || !ok {
+			return nil, err
+		}
+		lm, ls, ok, err := s.travel(load.Origin, load.Dest)
+		if err != nil || !ok {
+			return nil, err
+		}
+		y, m, d := load.PickupDate.Date()
+		open := time.Date(y, m, d, 0, 0, 0, 0, load.PickupDate.Location())
+		arrive := free.Add(s.elapsed(ds))
+		if !arrive.Before(open.AddDate(0, 0, 1)) {
+			return nil, nil
+		}
+		pickup := arrive
+		if pickup.Before(open) {
+			pickup = open
+		}
+		deliver := pickup.Add(s.opts.Handling).Add(s.elapsed(ls))
+		if deliver.After(end) {
+			return nil, nil
+		}
+		revenue := 0
+		if price, ok := load.Rate.Normalize(float64(lm) / 1000); ok {
+			revenue = price.TotalCents
+		}
+		stop := &Stop{load, float64(dm) / 1000, float64(lm) / 1000,
+			pickup, deliver, revenue}
+		r.Stops = append(r.Stops, stop)
+		r.Revenue += revenue
+		r.EmptyKm += stop.EmptyKm
+		r.LoadedKm += stop.LoadedKm
+		at, free = load.Dest, deliver.Add(s.opts.Handling)
+	}
+	if truck != nil && s.opts.ReturnHome {
+		dm, _, ok, err := s.travel(at, truck.Home)
+		if err != nil || !ok {
+			return nil, err
+		}
+		r.EmptyKm += float64(dm) / 1000
+	}
+	r.Profit = r.Revenue - int(math.Floor(s.opts.DeadheadCost*r.EmptyKm+0.5))
+	return r, nil
+}
+
+func routeLoads(r *Route) []*boards.Load {
+	loads := make([]*boards.Load, len(r.Stops))
+	for i, stop := range r.Stops {
+		loads[i] = stop.Load
+	}
+	return loads
+}
+
+// Solve assigns loads to trucks, maximizing revenue less the cost of
+// empty kilometers.
+func Solve(trucks []*Truck, loads []*boards.Load, travel trips.TravelFunc,
+	opts Options) (*Solution, error) {
+	s := &solver{opts, travel}
+	chains, err := s.savings(trucks, loads)
+	if err != nil {
+		return nil, err
+	}
+	routes, unassigned, err := s.assign(trucks, chains)
+	if err != nil {
+		return nil, err
+	}
+	if routes, unassigned, err = s.improve(routes, unassigned); err != nil {
+		return nil, err
+	}
+	sol := &Solution{Routes: routes, Unassigned: unassigned}
+	for _, r := range routes {
+		sol.Revenue += r.Revenue
+		sol.EmptyKm += r.EmptyKm
+		sol.LoadedKm += r.LoadedKm
+		sol.Profit += r.Profit
+	}
+	return sol, nil
+}
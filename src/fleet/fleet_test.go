@@ -0,0 +1,109 @@
+package fleet
+
+import "bytes"
+import "strings"
+import "testing"
+import "time"
+
+import "boards"
+import "common"
+
+var (
+	boise = common.CityState{"Boise", "ID"}
+	salem = common.CityState{"Salem", "OR"}
+	reno  = common.CityState{"Reno", "NV"}
+)
+
+var day0 = time.Date(2013, time.April, 8, 0, 0, 0, 0, time.UTC)
+
+func testTravel(src, dest common.CityState) (int, int, bool, error) {
+	if src == dest {
+		return 0, 0, true, nil
+	}
+	return 700000, 7 * 3600, true, nil
+}
+
+func testLoad(from, to common.CityState, day int, equip string,
+	weight int, rate string) *boards.Load {
+	return &boards.Load{0, "test", day0.AddDate(0, 0, day), from, to,
+		"Full", 48, weight, equip, boards.ParseRate(rate), 0, ""}
+}
+
+const testFleet = `Name,HomeCity,HomeState,Equipment,MaxWeight,MaxLength
+van,boise,ID,Van,,53
+flat,Salem,or,Flatbed|Step Deck,45000,48
+`
+
+func TestReadTrucks(t *testing.T) {
+	trucks, err := ReadTrucks(strings.NewReader(testFleet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trucks) != 2 || trucks[0].Home != boise ||
+		trucks[1].Home != salem || len(trucks[1].Equipment) != 2 ||
+		trucks[1].MaxWeight != 45000 || trucks[0].MaxLength != 53 {
+		t.Errorf("Incorrect trucks: %v %v", trucks[0], trucks[1])
+	}
+	if _, err := ReadTrucks(strings.NewReader("Name\nx\n")); err == nil {
+		t.Errorf("Read a fleet without homes")
+	}
+}
+
+func TestSolve(t *testing.T) {
+	trucks, err := ReadTrucks(strings.NewReader(testFleet))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l1 := testLoad(boise, salem, 0, "Van", 40000, "$1400")
+	l2 := testLoad(salem, reno, 1, "Van", 40000, "$1000")
+	l3 := testLoad(salem, boise, 0, "flatbed", 40000, "$1200")
+	l4 := testLoad(salem, reno, 0, "Flatbed", 50000, "$3000")
+	l5 := testLoad(reno, boise, 0, "Reefer", 40000, "$2000")
+	sol, err := Solve(trucks, []*boards.Load{l5, l4, l3, l2, l1},
+		testTravel, Options{day0, 3 * 24 * time.Hour, 100, 24,
+			time.Hour, false, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sol.Revenue != 360000 || sol.EmptyKm != 0 || sol.Profit != 360000 {
+		t.Errorf("Incorrect totals: %+v", sol)
+	}
+	van, flat := sol.Routes[0], sol.Routes[1]
+	if len(van.Stops) != 2 || van.Stops[0].Load != l1 ||
+		van.Stops[1].Load != l2 {
+		t.Errorf("Incorrect van route: %+v", van)
+	}
+	if len(flat.Stops) != 1 || flat.Stops[0].Load != l3 {
+		t.Errorf("Incorrect flatbed route: %+v", flat)
+	}
+	if len(sol.Unassigned) != 2 {
+		t.Errorf("Incorrect unassigned loads: %v", sol.Unassigned)
+	}
+	for _, format := range []string{"text", "csv", "json"} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, sol); err != nil || buf.Len() == 0 {
+			t.Errorf("Write %s: %v", format, err)
+		}
+	}
+}
+
+func TestImprove(t *testing.T) {
+	// One van at Boise; the Salem load is only worth hauling after
+	// the Boise load, and the cheap Reno load is not worth the
+	// empty drive.
+	trucks := []*Truck{{"van", boise, []string{"Van"}, 0, 0}}
+	l1 := testLoad(boise, salem, 0, "Van", 40000, "$1400")
+	l2 := testLoad(salem, boise, 1, "Van", 40000, "$900")
+	l3 := testLoad(reno, boise, 2, "Van", 40000, "$100")
+	sol, err := Solve(trucks, []*boards.Load{l3, l2, l1}, testTravel,
+		Options{day0, 4 * 24 * time.Hour, 100, 24, time.Hour, true, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := sol.Routes[0]; len(r.Stops) != 2 || r.Profit != 230000 {
+		t.Errorf("Incorrect route: %+v", r)
+	}
+	if len(sol.Unassigned) != 1 || sol.Unassigned[0] != l3 {
+		t.Errorf("Incorrect unassigned loads: %v", sol.Unassigned)
+	}
+}
@@ -0,0 +1,202 @@
+package fleet
+
+import "encoding/csv"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "strconv"
+import "strings"
+import "time"
+
+import "common"
+
+const timeFormat = "2006-01-02 15:04"
+
+// ReadTrucks reads a CSV fleet with the header Name, HomeCity,
+// HomeState, Equipment, MaxWeight, MaxLength.  Equipment types are
+// separated by "|".
+func ReadTrucks(r io.Reader) ([]*Truck, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("Empty fleet")
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"Name", "HomeCity", "HomeState", "Equipment"} {
+		if _, has := columns[name]; !has {
+			return nil, errors.New("Fleet is missing column " + name)
+		}
+	}
+	field := func(rec []string, name string) string {
+		if i, has := columns[name]; has && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	number := func(rec []string, name string) (int, error) {
+		if f := field(rec, name); len(f) != 0 {
+			return strconv.Atoi(f)
+		}
+		return 0, nil
+	}
+	var trucks []*Truck
+	for _, rec := range records[1:] {
+		t := &Truck{Name: field(rec, "Name"),
+			Home: common.CityState{common.ProperName(field(rec, "HomeCity")),
+				common.StateCode(strings.ToUpper(field(rec, "HomeState")))}}
+		for _, e := range strings.Split(field(rec, "Equipment"), "|") {
+			if e = strings.TrimSpace(e); len(e) != 0 {
+				t.Equipment = append(t.Equipment, e)
+			}
+		}
+		if t.MaxWeight, err = number(rec, "MaxWeight"); err != nil {
+			return nil, err
+		}
+		if t.MaxLength, err = number(rec, "MaxLength"); err != nil {
+			return nil, err
+		}
+		trucks = append(trucks, t)
+	}
+	return trucks, nil
+}
+
+func dollars(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func WriteText(w io.Writer, s *Solution) error {
+	if _, err := fmt.Fprintf(w,
+		"Revenue $%s, profit $%s, %.0f km loaded, %.0f km (%.0f mi) empty, %d loads unassigned\n",
+		dollars(s.Revenue), dollars(s.Profit), s.LoadedKm, s.EmptyKm,
+		s.EmptyMiles(), len(s.Unassigned)); err != nil {
+		return err
+	}
+	for _, r := range s.Routes {
+		if _, err := fmt.Fprintf(w, "%s (%v): %d loads, $%s revenue, $%s profit, %.0f km empty\n",
+			r.Truck.Name, r.Truck.Home, len(r.Stops), dollars(r.Revenue),
+			dollars(r.Profit), r.EmptyKm); err != nil {
+			return err
+		}
+		for _, st := range r.Stops {
+			if _, err := fmt.Fprintf(w,
+				"  %.0f km empty, pick up %s %v -> %v %s (%s), deliver %s, $%s\n",
+				st.EmptyKm, st.Pickup.Format(timeFormat),
+				st.Load.Origin, st.Load.Dest, st.Load.Equipment,
+				st.Load.Rate, st.Deliver.Format(timeFormat),
+				dollars(st.Revenue)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, l := range s.Unassigned {
+		if _, err := fmt.Fprintf(w, "Unassigned: %v\n", l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteCSV(w io.Writer, s *Solution) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Truck", "Pickup", "Origin", "Dest", "Equipment",
+		"Rate", "EmptyKm", "LoadedKm", "Deliver", "Revenue", "Phone"})
+	for _, r := range s.Routes {
+		for _, st := range r.Stops {
+			cw.Write([]string{r.Truck.Name, st.Pickup.Format(timeFormat),
+				st.Load.Origin.String(), st.Load.Dest.String(),
+				st.Load.Equipment, st.Load.Rate.String(),
+				strconv.FormatFloat(st.EmptyKm, 'f', 0, 64),
+				strconv.FormatFloat(st.LoadedKm, 'f', 0, 64),
+				st.Deliver.Format(timeFormat), dollars(st.Revenue),
+				st.Load.Phone})
+		}
+	}
+	for _, l := range s.Unassigned {
+		cw.Write([]string{"", common.FormatLoadDate(l.PickupDate),
+			l.Origin.String(), l.Dest.String(), l.Equipment,
+			l.Rate.String(), "", "", "", "", l.Phone})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonStop struct {
+	Origin    string
+	Dest      string
+	Equipment string
+	Rate      string
+	EmptyKm   float64
+	LoadedKm  float64
+	Pickup    time.Time
+	Deliver   time.Time
+	Revenue   float64
+	Phone     string
+}
+
+type jsonRoute struct {
+	Truck   string
+	Home    string
+	Revenue float64
+	Profit  float64
+	EmptyKm float64
+	Stops   []jsonStop
+}
+
+type jsonSolution struct {
+	Revenue    float64
+	Profit     float64
+	LoadedKm   float64
+	EmptyKm    float64
+	EmptyMiles float64
+	Routes     []jsonRoute
+	Unassigned []string
+}
+
+func WriteJSON(w io.Writer, s *Solution) error {
+	js := jsonSolution{float64(s.Revenue) / 100, float64(s.Profit) / 100,
+		s.LoadedKm, s.EmptyKm, s.EmptyMiles(), nil, nil}
+	for _, r := range s.Routes {
+		jr := jsonRoute{r.Truck.Name, r.Truck.Home.String(),
+			float64(r.Revenue) / 100, float64(r.Profit) / 100, r.EmptyKm, nil}
+		for _, st := range r.Stops {
+			jr.Stops = append(jr.Stops, jsonStop{st.Load.Origin.String(),
+				st.Load.Dest.String(), st.Load.Equipment,
+				st.Load.Rate.String(), st.EmptyKm, st.LoadedKm,
+				st.Pickup, st.Deliver, float64(st.Revenue) / 100,
+				st.Load.Phone})
+		}
+		js.Routes = append(js.Routes, jr)
+	}
+	for _, l := range s.Unassigned {
+		js.Unassigned = append(js.Unassigned, l.String())
+	}
+	data, err := json.MarshalIndent(js, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Write writes a solution as "text", "csv" or "json".
+func Write(w io.Writer, format string, s *Solution) error {
+	switch format {
+	case "text":
+		return WriteText(w, s)
+	case "csv":
+		return WriteCSV(w, s)
+	case "json":
+		return WriteJSON(w, s)
+	}
+	return errors.New("Unknown report format: " + format)
+}
@@ -0,0 +1,277 @@
+package fleet
+
+import "sort"
+
+import "boards"
+import "common"
+
+type saving struct {
+	from, to *boards.Load
+	value    float64
+}
+
+type bySaving []saving
+
+func (b bySaving) Len() int           { return len(b) }
+func (b bySaving) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b bySaving) Less(i, j int) bool { return b[i].value > b[j].value }
+
+// homeMeters returns the distance between a place and the nearest
+// home base, in either direction.
+func (s *solver) homeMeters(trucks []*Truck, cs common.CityState,
+	toHome bool) (float64, error) {
+	best, found := 0.0, false
+	for _, t := range trucks {
+		src, dest := t.Home, cs
+		if toHome {
+			src, dest = cs, t.Home
+		}
+		m, _, ok, err := s.travel(src, dest)
+		if err != nil {
+			return 0, err
+		}
+		if ok && (!found || float64(m) < best) {
+			best, found = float64(m), true
+		}
+	}
+	return best, nil
+}
+
+// carrier reports whether some truck can haul every load.
+func carrier(trucks []*Truck, loads []*boards.Load) bool {
+	for _, t := range trucks {
+		all := true
+		for _, l := range loads {
+			if !t.Carries(l) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// savings chains loads in the manner of Clarke and Wright: hauling j
+// after i saves the trip from i's destination to the nearest home and
+// from there to j's origin, less the empty drive between them.
+func (s *solver) savings(trucks []*Truck, loads []*boards.Load) ([][]*boards.Load, error) {
+	toHome := make(map[*boards.Load]float64)
+	fromHome := make(map[*boards.Load]float64)
+	chainOf := make(map[*boards.Load]int)
+	var chains [][]*boards.Load
+	for _, l := range loads {
+		var err error
+		if toHome[l], err = s.homeMeters(trucks, l.Dest, true); err != nil {
+			return nil, err
+		}
+		if fromHome[l], err = s.homeMeters(trucks, l.Origin, false); err != nil {
+			return nil, err
+		}
+		chainOf[l] = len(chains)
+		chains = append(chains, []*boards.Load{l})
+	}
+	var savings []saving
+	for _, i := range loads {
+		for _, j := range loads {
+			if i == j || j.PickupDate.Before(i.PickupDate) {
+				continue
+			}
+			m, _, ok, err := s.travel(i.Dest, j.Origin)
+			if err != nil {
+				return nil, err
+			}
+			if v := toHome[i] + fromHome[j] - float64(m); ok && v > 0 {
+				savings = append(savings, saving{i, j, v})
+			}
+		}
+	}
+	sort.Stable(bySaving(savings))
+	for _, sv := range savings {
+		ci, cj := chainOf[sv.from], chainOf[sv.to]
+		a, b := chains[ci], chains[cj]
+		if ci == cj || a[len(a)-1] != sv.from || b[0] != sv.to {
+			continue
+		}
+		merged := append(append([]*boards.Load{}, a...), b...)
+		if !carrier(trucks, merged) {
+			continue
+		}
+		r, err := s.evaluate(nil, merged)
+		if err != nil {
+			return nil, err
+		}
+		if r == nil {
+			continue
+		}
+		chains[ci], chains[cj] = merged, nil
+		for _, l := range b {
+			chainOf[l] = ci
+		}
+	}
+	var result [][]*boards.Load
+	for _, c := range chains {
+		if c != nil {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+type candidate struct {
+	truck int
+	chain int
+	route *Route
+}
+
+type byRouteProfit []candidate
+
+func (b byRouteProfit) Len() int      { return len(b) }
+func (b byRouteProfit) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byRouteProfit) Less(i, j int) bool {
+	return b[i].route.Profit > b[j].route.Profit
+}
+
+// assign gives each truck at most one chain, most profitable pairs
+// first.
+func (s *solver) assign(trucks []*Truck, chains [][]*boards.Load) ([]*Route, []*boards.Load, error) {
+	var cands []candidate
+	for ti, t := range trucks {
+		for ci, c := range chains {
+			r, err := s.evaluate(t, c)
+			if err != nil {
+				return nil, nil, err
+			}
+			if r != nil && r.Profit > 0 {
+				cands = append(cands, candidate{ti, ci, r})
+			}
+		}
+	}
+	sort.Stable(byRouteProfit(cands))
+	routes := make([]*Route, len(trucks))
+	used := make([]bool, len(chains))
+	for _, c := range cands {
+		if routes[c.truck] == nil && !used[c.chain] {
+			routes[c.truck] = c.route
+			used[c.chain] = true
+		}
+	}
+	for ti, t := range trucks {
+		if routes[ti] == nil {
+			routes[ti] = &Route{Truck: t}
+		}
+	}
+	var unassigned []*boards.Load
+	for ci, c := range chains {
+		if !used[ci] {
+			unassigned = append(unassigned, c...)
+		}
+	}
+	return routes, unassigned, nil
+}
+
+func insert(loads []*boards.Load, pos int, l *boards.Load) []*boards.Load {
+	out := make([]*boards.Load, 0, len(loads)+1)
+	out = append(out, loads[:pos]...)
+	out = append(out, l)
+	return append(out, loads[pos:]...)
+}
+
+func remove(loads []*boards.Load, pos int) []*boards.Load {
+	out := make([]*boards.Load, 0, len(loads)-1)
+	out = append(out, loads[:pos]...)
+	return append(out, loads[pos+1:]...)
+}
+
+// bestInsertion finds the most profitable position for a load in a
+// route, returning nil if no position improves profit by more than
+// min.
+func (s *solver) bestInsertion(r *Route, l *boards.Load, min int) (*Route, error) {
+	loads := routeLoads(r)
+	var best *Route
+	for pos := 0; pos <= len(loads); pos++ {
+		nr, err := s.evaluate(r.Truck, insert(loads, pos, l))
+		if err != nil {
+			return nil, err
+		}
+		if nr != nil && nr.Profit-r.Profit > min &&
+			(best == nil || nr.Profit > best.Profit) {
+			best = nr
+		}
+	}
+	return best, nil
+}
+
+// improve inserts unassigned loads, relocates loads between trucks and
+// drops unprofitable loads until no move helps.
+func (s *solver) improve(routes []*Route, unassigned []*boards.Load) ([]*Route, []*boards.Load, error) {
+	for iter := 0; iter < s.opts.Iterations; iter++ {
+		improved := false
+
+		var still []*boards.Load
+		for _, l := range unassigned {
+			bi, best := -1, (*Route)(nil)
+			for ri, r := range routes {
+				nr, err := s.bestInsertion(r, l, 0)
+				if err != nil {
+					return nil, nil, err
+				}
+				if nr != nil && (best == nil ||
+					nr.Profit-r.Profit > best.Profit-routes[bi].Profit) {
+					bi, best = ri, nr
+				}
+			}
+			if best != nil {
+				routes[bi] = best
+				improved = true
+			} else {
+				still = append(still, l)
+			}
+		}
+		unassigned = still
+
+		for ai := 0; ai < len(routes); ai++ {
+			for pos := 0; pos < len(routes[ai].Stops); pos++ {
+				a := routes[ai]
+				loads := routeLoads(a)
+				l := loads[pos]
+				na, err := s.evaluate(a.Truck, remove(loads, pos))
+				if err != nil {
+					return nil, nil, err
+				}
+				if na == nil {
+					continue
+				}
+				if na.Profit > a.Profit {
+					// Better off without it.
+					routes[ai] = na
+					unassigned = append(unassigned, l)
+					improved = true
+					pos--
+					continue
+				}
+				for bi, b := range routes {
+					if bi == ai {
+						continue
+					}
+					nb, err := s.bestInsertion(b, l, a.Profit-na.Profit)
+					if err != nil {
+						return nil, nil, err
+					}
+					if nb != nil {
+						routes[ai], routes[bi] = na, nb
+						improved = true
+						pos--
+						break
+					}
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+	return routes, unassigned, nil
+}
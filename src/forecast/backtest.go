@@ -0,0 +1,55 @@
+package forecast
+
+import "errors"
+import "math"
+
+// Backtest compares a model fit without the last Holdout days to
+// those days.  NaiveMAE is the error of repeating the count from one
+// week earlier, for comparison.
+type Backtest struct {
+	Holdout  int
+	Tested   int
+	MAE      float64
+	NaiveMAE float64
+	Coverage float64 // Fraction of tested days inside the interval
+}
+
+func RunBacktest(s Series, holdout int, z float64) (*Backtest, error) {
+	if holdout <= 0 || holdout >= len(s.Values) {
+		return nil, errors.New("Holdout out of range")
+	}
+	train := len(s.Values) - holdout
+	m, err := Fit(s.Head(train))
+	if err != nil {
+		return nil, err
+	}
+	b := &Backtest{Holdout: holdout}
+	var naive, covered float64
+	naiveTested := 0
+	for t := train; t < len(s.Values); t++ {
+		y := s.Values[t]
+		if math.IsNaN(y) {
+			continue
+		}
+		p := m.Interval(t, z)
+		b.Tested++
+		b.MAE += math.Abs(y - p.Value)
+		if y >= p.Low && y <= p.High {
+			covered++
+		}
+		if t >= daysPerWeek && !math.IsNaN(s.Values[t-daysPerWeek]) {
+			naive += math.Abs(y - s.Values[t-daysPerWeek])
+			naiveTested++
+		}
+	}
+	if b.Tested == 0 {
+		return nil, errors.New("No observations held out")
+	}
+	b.MAE /= float64(b.Tested)
+	b.Coverage = covered / float64(b.Tested)
+	b.NaiveMAE = math.NaN()
+	if naiveTested != 0 {
+		b.NaiveMAE = naive / float64(naiveTested)
+	}
+	return b, nil
+}
@@ -0,0 +1,129 @@
+package forecast
+
+import "bytes"
+import "math"
+import "testing"
+import "time"
+
+import "boards"
+import "common"
+import "geo"
+
+// A Sunday
+var testStart = time.Date(2013, time.April, 7, 0, 0, 0, 0, time.UTC)
+
+var testWeek = [7]float64{-20, 5, 10, 10, 5, 0, -10}
+
+func testSeries(days int, noise float64) Series {
+	s := NewSeries(testStart, days)
+	for t, _ := range s.Values {
+		s.Values[t] = 100 + 2*float64(t) + testWeek[t%7] +
+			noise*math.Sin(float64(t)*1.7)
+	}
+	return s
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestFit(t *testing.T) {
+	s := testSeries(28, 0)
+	// A missing day does not disturb the fit.
+	s.Values[10] = math.NaN()
+	m, err := Fit(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(m.Slope, 2) || !near(m.Intercept, 100) || !near(m.Sigma, 0) {
+		t.Errorf("Incorrect trend: %+v", m)
+	}
+	for d, v := range testWeek {
+		if !near(m.Seasonal[d], v) {
+			t.Errorf("Incorrect seasonal %v: %v want %v",
+				time.Weekday(d), m.Seasonal[d], v)
+		}
+	}
+	f := m.Forecast(7, ZScore(0.9))
+	if len(f) != 7 || !f[0].Date.Equal(testStart.AddDate(0, 0, 28)) ||
+		!near(f[0].Value, 100+56-20) {
+		t.Errorf("Incorrect forecast: %v", f)
+	}
+	if _, err := Fit(testSeries(MinObservations-1, 0)); err == nil {
+		t.Errorf("Fit too few observations")
+	}
+}
+
+func TestInterval(t *testing.T) {
+	m, err := Fit(testSeries(42, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	near, far := m.Interval(42, 1.645), m.Interval(70, 1.645)
+	if near.Low >= near.Value || near.High <= near.Value ||
+		far.High-far.Low <= near.High-near.Low {
+		t.Errorf("Incorrect intervals: %v %v", near, far)
+	}
+	if z := ZScore(0.95); math.Abs(z-1.96) > 0.01 {
+		t.Errorf("Incorrect z: %v", z)
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	b, err := RunBacktest(testSeries(35, 0), 7, 1.645)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The naive forecast misses the two-per-day trend for a week.
+	if b.Tested != 7 || !near(b.MAE, 0) || !near(b.NaiveMAE, 14) {
+		t.Errorf("Incorrect backtest: %+v", b)
+	}
+	if _, err := RunBacktest(testSeries(20, 0), 7, 1.645); err == nil {
+		t.Errorf("Backtest with too little training")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	portland := geo.CityStateLoc{common.CityState{"Portland", "OR"},
+		geo.SphereCoords{45.52, -122.68}}
+	salem := geo.CityStateLoc{common.CityState{"Salem", "OR"},
+		geo.SphereCoords{44.94, -123.04}}
+	vancouver := geo.CityStateLoc{common.CityState{"Vancouver", "WA"},
+		geo.SphereCoords{45.64, -122.66}}
+	clusters := ClusterCities([]geo.CityStateLoc{portland, salem, vancouver},
+		50000)
+	if clusters[vancouver.CityState] != "Portland, OR" ||
+		clusters[salem.CityState] != "Salem, OR" {
+		t.Errorf("Incorrect clusters: %v", clusters)
+	}
+
+	observed := make([]bool, 28)
+	for d, _ := range observed {
+		observed[d] = d != 5
+	}
+	g := NewGroups(testStart, observed, clusters)
+	for d := 0; d < 28; d++ {
+		load := boards.Load{Origin: vancouver.CityState, Equipment: "Van"}
+		g.Add(d, load, 10+d%7)
+	}
+	results := g.Forecast("", 7, 7, ZScore(0.9))
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 groups: %v", results)
+	}
+	for _, r := range results {
+		if r.Err != nil || r.Observed != 27 || len(r.Forecast) != 7 ||
+			!near(r.Forecast[0].Value, 10) {
+			t.Errorf("Incorrect result: %+v %v", r, r.Forecast)
+		}
+	}
+	if r := g.Forecast(ByCluster, 7, 0, 1); len(r) != 1 ||
+		r[0].Name != "Portland, OR" || r[0].Backtest != nil {
+		t.Errorf("Incorrect cluster forecast: %v", r)
+	}
+	for _, format := range []string{"text", "csv", "json"} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, results); err != nil || buf.Len() == 0 {
+			t.Errorf("Write %s: %v", format, err)
+		}
+	}
+}
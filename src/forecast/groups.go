@@ -0,0 +1,290 @@
+package forecast
+
+import "encoding/csv"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "math"
+import "sort"
+import "strconv"
+import "time"
+
+import "boards"
+import "common"
+import "geo"
+
+// Kinds of groups that loads are counted in.
+const (
+	ByState     = "state"
+	ByCluster   = "cluster"
+	ByEquipment = "equipment"
+)
+
+type Key struct {
+	Kind string
+	Name string
+}
+
+// Groups counts loads per day in each origin state, origin city
+// cluster and equipment type.
+type Groups struct {
+	start    time.Time
+	days     int
+	observed []bool
+	clusters map[common.CityState]string
+	series   map[Key]Series
+}
+
+// Result is the forecast for one group.  Model is nil, and Err set,
+// if the group could not be fit.
+type Result struct {
+	Key
+	Observed int
+	Model    *Model
+	Forecast []Point
+	Backtest *Backtest
+	Err      error
+}
+
+// NewGroups counts days from start; observed tells which days have
+// data, the others are treated as missing rather than zero.
+func NewGroups(start time.Time, observed []bool,
+	clusters map[common.CityState]string) *Groups {
+	return &Groups{start, len(observed), observed, clusters,
+		make(map[Key]Series)}
+}
+
+func (g *Groups) add(key Key, day, count int) {
+	s, has := g.series[key]
+	if !has {
+		s = NewSeries(g.start, g.days)
+		for d, obs := range g.observed {
+			if obs {
+				s.Values[d] = 0
+			}
+		}
+		g.series[key] = s
+	}
+	s.Values[day] += float64(count)
+}
+
+// Add counts a load posted count times on day.
+func (g *Groups) Add(day int, load boards.Load, count int) {
+	if day < 0 || day >= g.days || !g.observed[day] {
+		return
+	}
+	g.add(Key{ByState, load.Origin.State}, day, count)
+	if name, has := g.clusters[load.Origin]; has {
+		g.add(Key{ByCluster, name}, day, count)
+	}
+	g.add(Key{ByEquipment, load.Equipment}, day, count)
+}
+
+// Forecast fits each group of the given kind, or all kinds if kind is
+// empty, and predicts the next days.  With holdout > 0 each group is
+// also backtested against its last holdout days.
+func (g *Groups) Forecast(kind string, days, holdout int, z float64) []*Result {
+	var results []*Result
+	for key, s := range g.series {
+		if len(kind) != 0 && key.Kind != kind {
+			continue
+		}
+		r := &Result{Key: key, Observed: s.Observed()}
+		if r.Model, r.Err = Fit(s); r.Err == nil {
+			r.Forecast = r.Model.Forecast(days, z)
+			if holdout > 0 {
+				r.Backtest, r.Err = RunBacktest(s, holdout, z)
+			}
+		}
+		results = append(results, r)
+	}
+	sort.Sort(byVolume(results))
+	return results
+}
+
+// Total is the predicted volume over the forecast.
+func (r *Result) Total() float64 {
+	total := 0.0
+	for _, p := range r.Forecast {
+		total += p.Value
+	}
+	return total
+}
+
+type byVolume []*Result
+
+func (r byVolume) Len() int      { return len(r) }
+func (r byVolume) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byVolume) Less(i, j int) bool {
+	if ti, tj := r[i].Total(), r[j].Total(); ti != tj {
+		return ti > tj
+	}
+	if r[i].Kind != r[j].Kind {
+		return r[i].Kind < r[j].Kind
+	}
+	return r[i].Name < r[j].Name
+}
+
+// ClusterCities groups cities within radius meters of a leading city,
+// named after it.  Cities lead in the order given, so callers should
+// list the busiest first.
+func ClusterCities(cities []geo.CityStateLoc, radius float64) map[common.CityState]string {
+	clusters := make(map[common.CityState]string)
+	var leaders []geo.Coords
+	var names []string
+	for _, city := range cities {
+		if !city.Defined() {
+			continue
+		}
+		c := make(geo.Coords, 3)
+		city.ToCoords(c)
+		name := ""
+		for i, leader := range leaders {
+			if geo.GreatCircleDistance(c, leader) <= radius {
+				name = names[i]
+				break
+			}
+		}
+		if len(name) == 0 {
+			name = city.CityState.String()
+			leaders = append(leaders, c)
+			names = append(names, name)
+		}
+		clusters[city.CityState] = name
+	}
+	return clusters
+}
+
+func formatFloat(f float64) string {
+	if math.IsNaN(f) {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
+
+func WriteText(w io.Writer, results []*Result) error {
+	for _, r := range results {
+		if r.Err != nil && r.Model == nil {
+			if _, err := fmt.Fprintf(w, "%s %s: %d days: %v\n",
+				r.Kind, r.Name, r.Observed, r.Err); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w,
+			"%s %s: %d days, %.1f loads/week trend, sigma %.1f, next %d days %.0f loads\n",
+			r.Kind, r.Name, r.Observed, r.Model.SlopePerWeek(),
+			r.Model.Sigma, len(r.Forecast), r.Total()); err != nil {
+			return err
+		}
+		for _, p := range r.Forecast {
+			if _, err := fmt.Fprintf(w, "  %s %s %.1f [%.1f, %.1f]\n",
+				common.FormatLoadDate(p.Date), p.Date.Weekday(),
+				p.Value, p.Low, p.High); err != nil {
+				return err
+			}
+		}
+		if b := r.Backtest; b != nil {
+			if _, err := fmt.Fprintf(w,
+				"  backtest %d days: MAE %.1f, naive MAE %s, coverage %.0f%%\n",
+				b.Tested, b.MAE, formatFloat(b.NaiveMAE),
+				b.Coverage*100); err != nil {
+				return err
+			}
+		} else if r.Err != nil {
+			if _, err := fmt.Fprintf(w, "  backtest: %v\n", r.Err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func WriteCSV(w io.Writer, results []*Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Kind", "Name", "Date", "Predicted", "Low", "High",
+		"BacktestMAE", "NaiveMAE", "Coverage"})
+	for _, r := range results {
+		var bt []string
+		if b := r.Backtest; b != nil {
+			bt = []string{formatFloat(b.MAE), formatFloat(b.NaiveMAE),
+				strconv.FormatFloat(b.Coverage, 'f', 2, 64)}
+		} else {
+			bt = []string{"", "", ""}
+		}
+		for _, p := range r.Forecast {
+			cw.Write(append([]string{r.Kind, r.Name,
+				common.FormatLoadDate(p.Date), formatFloat(p.Value),
+				formatFloat(p.Low), formatFloat(p.High)}, bt...))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonPoint struct {
+	Date      string
+	Predicted float64
+	Low       float64
+	High      float64
+}
+
+type jsonResult struct {
+	Kind         string
+	Name         string
+	Observed     int
+	TrendPerWeek float64     `json:",omitempty"`
+	Sigma        float64     `json:",omitempty"`
+	Forecast     []jsonPoint `json:",omitempty"`
+	BacktestMAE  *float64    `json:",omitempty"`
+	NaiveMAE     *float64    `json:",omitempty"`
+	Coverage     *float64    `json:",omitempty"`
+	Error        string      `json:",omitempty"`
+}
+
+func WriteJSON(w io.Writer, results []*Result) error {
+	var out []jsonResult
+	for _, r := range results {
+		jr := jsonResult{Kind: r.Kind, Name: r.Name, Observed: r.Observed}
+		if r.Model != nil {
+			jr.TrendPerWeek = r.Model.SlopePerWeek()
+			jr.Sigma = r.Model.Sigma
+		}
+		for _, p := range r.Forecast {
+			jr.Forecast = append(jr.Forecast, jsonPoint{
+				common.FormatLoadDate(p.Date), p.Value, p.Low, p.High})
+		}
+		if b := r.Backtest; b != nil {
+			mae, coverage := b.MAE, b.Coverage
+			jr.BacktestMAE, jr.Coverage = &mae, &coverage
+			if !math.IsNaN(b.NaiveMAE) {
+				naive := b.NaiveMAE
+				jr.NaiveMAE = &naive
+			}
+		}
+		if r.Err != nil {
+			jr.Error = r.Err.Error()
+		}
+		out = append(out, jr)
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Write writes results as "text", "csv" or "json".
+func Write(w io.Writer, format string, results []*Result) error {
+	switch format {
+	case "text":
+		return WriteText(w, results)
+	case "csv":
+		return WriteCSV(w, results)
+	case "json":
+		return WriteJSON(w, results)
+	}
+	return errors.New("Unknown report format: " + format)
+}
@@ -0,0 +1,251 @@
+// Package forecast predicts daily load volumes from a linear trend
+// plus a day-of-week seasonal component.
+
+package forecast
+
+import "errors"
+import "fmt"
+import "math"
+import "time"
+
+const (
+	// A fit needs at least two weeks of observations.
+	MinObservations = 14
+	daysPerWeek     = 7
+	// Weekday columns that have no indicator variable.
+	baseDay   = -1
+	unseenDay = -2
+)
+
+// Series is a count per day from Start.  Days without observations,
+// e.g., without a scrape, are NaN.
+type Series struct {
+	Start  time.Time
+	Values []float64
+}
+
+// Model is y(t) = Intercept + Slope * t + Seasonal[weekday(t)] + e,
+// where e has standard deviation Sigma.
+type Model struct {
+	Intercept float64
+	Slope     float64
+	Seasonal  [daysPerWeek]float64 // Indexed by time.Weekday
+	Sigma     float64
+	start     time.Time
+	days      int
+	observed  int
+	tMean     float64
+	tSxx      float64
+}
+
+// Point is a prediction with its confidence interval.
+type Point struct {
+	Date  time.Time
+	Value float64
+	Low   float64
+	High  float64
+}
+
+func NewSeries(start time.Time, days int) Series {
+	s := Series{start, make([]float64, days)}
+	for i, _ := range s.Values {
+		s.Values[i] = math.NaN()
+	}
+	return s
+}
+
+func (s Series) Date(t int) time.Time {
+	return s.Start.AddDate(0, 0, t)
+}
+
+func (s Series) Observed() int {
+	n := 0
+	for _, v := range s.Values {
+		if !math.IsNaN(v) {
+			n++
+		}
+	}
+	return n
+}
+
+// Head returns the first n days of s.
+func (s Series) Head(n int) Series {
+	return Series{s.Start, s.Values[:n]}
+}
+
+// Fit estimates the trend and seasonal components by least squares,
+// with an indicator variable for each weekday after the first one
+// observed.
+func Fit(s Series) (*Model, error) {
+	m := &Model{start: s.Start, days: len(s.Values)}
+	m.observed = s.Observed()
+	if m.observed < MinObservations {
+		return nil, errors.New(fmt.Sprint("Too few observations: ",
+			m.observed, " < ", MinObservations))
+	}
+	// Column of each weekday's indicator variable.
+	var column [daysPerWeek]int
+	for d, _ := range column {
+		column[d] = unseenDay
+	}
+	cols := 2
+	base := true
+	var st float64
+	for t, y := range s.Values {
+		if math.IsNaN(y) {
+			continue
+		}
+		st += float64(t)
+		d := m.weekday(t)
+		if column[d] != unseenDay {
+			continue
+		}
+		if base {
+			column[d] = baseDay
+			base = false
+		} else {
+			column[d] = cols
+			cols++
+		}
+	}
+	m.tMean = st / float64(m.observed)
+
+	// Normal equations X'X b = X'y.
+	xtx := make([][]float64, cols)
+	for i, _ := range xtx {
+		xtx[i] = make([]float64, cols+1)
+	}
+	row := make([]float64, cols)
+	for t, y := range s.Values {
+		if math.IsNaN(y) {
+			continue
+		}
+		for i, _ := range row {
+			row[i] = 0
+		}
+		row[0], row[1] = 1, float64(t)
+		if c := column[m.weekday(t)]; c >= 0 {
+			row[c] = 1
+		}
+		for i := 0; i < cols; i++ {
+			for j := 0; j < cols; j++ {
+				xtx[i][j] += row[i] * row[j]
+			}
+			xtx[i][cols] += row[i] * y
+		}
+		dt := float64(t) - m.tMean
+		m.tSxx += dt * dt
+	}
+	b, err := solve(xtx)
+	if err != nil {
+		return nil, err
+	}
+	m.Intercept, m.Slope = b[0], b[1]
+
+	// Center the weekdays that were observed, so that the trend
+	// carries the level.
+	var total, days float64
+	for d, c := range column {
+		switch {
+		case c >= 0:
+			m.Seasonal[d] = b[c]
+		case c == baseDay:
+			m.Seasonal[d] = 0
+		default:
+			continue
+		}
+		total += m.Seasonal[d]
+		days++
+	}
+	for d, c := range column {
+		if c != unseenDay {
+			m.Seasonal[d] -= total / days
+		}
+	}
+	m.Intercept += total / days
+
+	sse := 0.0
+	for t, y := range s.Values {
+		if !math.IsNaN(y) {
+			e := y - m.Predict(t)
+			sse += e * e
+		}
+	}
+	dof := m.observed - cols
+	if dof < 1 {
+		dof = 1
+	}
+	m.Sigma = math.Sqrt(sse / float64(dof))
+	return m, nil
+}
+
+func (m *Model) weekday(t int) time.Weekday {
+	return m.start.AddDate(0, 0, t).Weekday()
+}
+
+// solve solves the augmented system a by Gaussian elimination with
+// partial pivoting.
+func solve(a [][]float64) ([]float64, error) {
+	n := len(a)
+	for i := 0; i < n; i++ {
+		p := i
+		for r := i + 1; r < n; r++ {
+			if math.Abs(a[r][i]) > math.Abs(a[p][i]) {
+				p = r
+			}
+		}
+		if math.Abs(a[p][i]) < 1e-9 {
+			return nil, errors.New("Singular model")
+		}
+		a[i], a[p] = a[p], a[i]
+		for r := i + 1; r < n; r++ {
+			f := a[r][i] / a[i][i]
+			for c := i; c <= n; c++ {
+				a[r][c] -= f * a[i][c]
+			}
+		}
+	}
+	x := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := a[i][n]
+		for c := i + 1; c < n; c++ {
+			sum -= a[i][c] * x[c]
+		}
+		x[i] = sum / a[i][i]
+	}
+	return x, nil
+}
+
+// Predict returns the expected value on day t of the fitted series.
+func (m *Model) Predict(t int) float64 {
+	return m.Intercept + m.Slope*float64(t) + m.Seasonal[m.weekday(t)]
+}
+
+// Interval returns the prediction for day t with a two-sided interval
+// of z standard errors, truncated at zero.
+func (m *Model) Interval(t int, z float64) Point {
+	dt := float64(t) - m.tMean
+	se := m.Sigma * math.Sqrt(1+1/float64(m.observed)+dt*dt/m.tSxx)
+	v := m.Predict(t)
+	return Point{m.start.AddDate(0, 0, t), math.Max(v, 0),
+		math.Max(v-z*se, 0), math.Max(v+z*se, 0)}
+}
+
+// Forecast predicts the days following the fitted series.
+func (m *Model) Forecast(days int, z float64) []Point {
+	var points []Point
+	for h := 0; h < days; h++ {
+		points = append(points, m.Interval(m.days+h, z))
+	}
+	return points
+}
+
+// SlopePerWeek is the trend in loads per week.
+func (m *Model) SlopePerWeek() float64 {
+	return m.Slope * daysPerWeek
+}
+
+// ZScore returns the z for a two-sided confidence level, e.g., 0.9.
+func ZScore(confidence float64) float64 {
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
@@ -0,0 +1,292 @@
+package geo
+
+import "errors"
+import "fmt"
+import "io"
+import "math"
+import "sort"
+
+// Area is a named polygon, such as the region reachable from a city.
+// Its ring runs counterclockwise and is not closed.
+type Area struct {
+	Name string
+	Ring []SphereCoords
+}
+
+// WriteGeoJSON writes the area as a GeoJSON Polygon Feature.
+func (a *Area) WriteGeoJSON(w io.Writer) error {
+	ring := a.Ring
+	if len(ring) != 0 {
+		ring = append(ring[:len(ring):len(ring)], ring[0])
+	}
+	return writeGeoJSON(w, a.Name, "Polygon",
+		[][][2]float64{geoJSONPositions(ring)})
+}
+
+// Thin keeps the first of the points in each cell of a grid with the
+// given size in degrees, to bound the work of ConcaveHull.
+func Thin(points []SphereCoords, cell float64) []SphereCoords {
+	seen := make(map[[2]int64]bool)
+	var thin []SphereCoords
+	for _, p := range points {
+		key := [2]int64{int64(math.Floor(p.Lat / cell)),
+			int64(math.Floor(p.Long / cell))}
+		if !seen[key] {
+			seen[key] = true
+			thin = append(thin, p)
+		}
+	}
+	return thin
+}
+
+type planePoint struct {
+	x, y float64
+}
+
+// hullCandidate is a point with a key to order candidates by.
+type hullCandidate struct {
+	id  int
+	key float64
+}
+
+type byKey []hullCandidate
+
+func (b byKey) Len() int           { return len(b) }
+func (b byKey) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byKey) Less(i, j int) bool { return b[i].key < b[j].key }
+
+// ConcaveHull returns a polygon enclosing the points that follows
+// their outline, by walking from each boundary point to one of its k
+// nearest neighbors (Moreira and Santos).  Smaller k gives a tighter
+// outline; k grows by half as needed for a simple polygon, up to the
+// convex hull.  Points are projected onto a plane, which suits regions
+// of a few hundred miles.  Points that enclose no area are an error.
+func ConcaveHull(points []SphereCoords, k int) ([]SphereCoords, error) {
+	seen := make(map[SphereCoords]bool)
+	var unique []SphereCoords
+	for _, p := range points {
+		if !seen[p] {
+			seen[p] = true
+			unique = append(unique, p)
+		}
+	}
+	if len(unique) < 3 {
+		return nil, errors.New(fmt.Sprint("An area needs 3 points, not ",
+			len(unique)))
+	}
+	var lat float64
+	for _, p := range unique {
+		lat += p.Lat
+	}
+	scale := math.Cos(degreeToRad(lat / float64(len(unique))))
+	ps := make([]planePoint, len(unique))
+	for i, p := range unique {
+		ps[i] = planePoint{p.Long * scale, p.Lat}
+	}
+	if k < 3 {
+		k = 3
+	}
+	convex := convexHull(ps)
+	if !enclosesArea(ps, convex) {
+		return nil, errors.New(fmt.Sprint("The ", len(ps),
+			" points of the area are on a line"))
+	}
+	var hull []int
+	for ; k < len(ps) && hull == nil; k += k / 2 {
+		hull = knnHull(ps, k)
+	}
+	if hull == nil {
+		hull = convex
+	}
+	ring := make([]SphereCoords, len(hull))
+	for i, id := range hull {
+		ring[i] = unique[id]
+	}
+	return ring, nil
+}
+
+// knnHull walks the boundary counterclockwise from the lowest point,
+// each step taking the neighbor that turns farthest right without
+// crossing the walk so far.  It returns nil if it gets stuck or
+// leaves points outside.
+func knnHull(ps []planePoint, k int) []int {
+	first := 0
+	for i, p := range ps {
+		if p.y < ps[first].y || (p.y == ps[first].y && p.x < ps[first].x) {
+			first = i
+		}
+	}
+	free := make([]bool, len(ps))
+	for i := range free {
+		free[i] = i != first
+	}
+	hull := []int{first}
+	current := first
+	back := math.Pi // Direction to the previous point
+	for step := 2; ; step++ {
+		if step == 5 {
+			free[first] = true
+		}
+		var near byKey
+		for i, p := range ps {
+			if free[i] {
+				near = append(near,
+					hullCandidate{i, math.Hypot(p.x-ps[current].x, p.y-ps[current].y)})
+			}
+		}
+		sort.Sort(near)
+		if len(near) > k {
+			near = near[:k]
+		}
+		for i, c := range near {
+			p := ps[c.id]
+			turn := math.Mod(math.Atan2(p.y-ps[current].y, p.x-ps[current].x)-back,
+				2*math.Pi)
+			if turn <= 0 {
+				turn += 2 * math.Pi
+			}
+			near[i].key = turn
+		}
+		sort.Stable(near)
+		next := -1
+		for _, c := range near {
+			if !crossesHull(ps, hull, c.id, c.id == first) {
+				next = c.id
+				break
+			}
+		}
+		if next < 0 {
+			return nil
+		}
+		if next == first {
+			break
+		}
+		hull = append(hull, next)
+		free[next] = false
+		back = math.Atan2(ps[current].y-ps[next].y, ps[current].x-ps[next].x)
+		current = next
+	}
+	if len(hull) < 3 {
+		return nil
+	}
+	onHull := make([]bool, len(ps))
+	for _, id := range hull {
+		onHull[id] = true
+	}
+	for i, p := range ps {
+		if !onHull[i] && !inRing(ps, hull, p) {
+			return nil
+		}
+	}
+	return hull
+}
+
+// enclosesArea tells whether the convex hull of ps has an area beyond
+// rounding error for the spread of the points.
+func enclosesArea(ps []planePoint, hull []int) bool {
+	if len(hull) < 3 {
+		return false
+	}
+	var area, spread float64
+	for i := range hull {
+		a, b := ps[hull[i]], ps[hull[(i+1)%len(hull)]]
+		area += a.x*b.y - b.x*a.y
+		spread = math.Max(spread, math.Hypot(b.x-a.x, b.y-a.y))
+	}
+	return math.Abs(area) > 1e-9*spread*spread
+}
+
+// crossesHull tells whether the segment from the last hull point to
+// ps[to] meets a segment of the hull other than those it adjoins.
+func crossesHull(ps []planePoint, hull []int, to int, closing bool) bool {
+	from := ps[hull[len(hull)-1]]
+	start := 0
+	if closing {
+		start = 1
+	}
+	for j := start; j+2 < len(hull); j++ {
+		if segmentsMeet(from, ps[to], ps[hull[j]], ps[hull[j+1]]) {
+			return true
+		}
+	}
+	return false
+}
+
+// orientation is positive if a, b, c turn left and negative if right.
+func orientation(a, b, c planePoint) float64 {
+	return (b.x-a.x)*(c.y-a.y) - (b.y-a.y)*(c.x-a.x)
+}
+
+func between(a, b, c planePoint) bool {
+	return math.Min(a.x, b.x) <= c.x && c.x <= math.Max(a.x, b.x) &&
+		math.Min(a.y, b.y) <= c.y && c.y <= math.Max(a.y, b.y)
+}
+
+// segmentsMeet tells whether segments ab and cd share any point.
+func segmentsMeet(a, b, c, d planePoint) bool {
+	o1, o2 := orientation(a, b, c), orientation(a, b, d)
+	o3, o4 := orientation(c, d, a), orientation(c, d, b)
+	if o1*o2 < 0 && o3*o4 < 0 {
+		return true
+	}
+	return (o1 == 0 && between(a, b, c)) || (o2 == 0 && between(a, b, d)) ||
+		(o3 == 0 && between(c, d, a)) || (o4 == 0 && between(c, d, b))
+}
+
+// inRing tells whether p is inside or on the polygon, by counting the
+// edges crossed by a ray to its right.
+func inRing(ps []planePoint, ring []int, p planePoint) bool {
+	inside := false
+	for i := range ring {
+		a, b := ps[ring[i]], ps[ring[(i+1)%len(ring)]]
+		if orientation(a, b, p) == 0 && between(a, b, p) {
+			return true
+		}
+		if (a.y > p.y) != (b.y > p.y) &&
+			p.x < a.x+(p.y-a.y)*(b.x-a.x)/(b.y-a.y) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+type byXY struct {
+	ids []int
+	ps  []planePoint
+}
+
+func (b byXY) Len() int      { return len(b.ids) }
+func (b byXY) Swap(i, j int) { b.ids[i], b.ids[j] = b.ids[j], b.ids[i] }
+func (b byXY) Less(i, j int) bool {
+	p, q := b.ps[b.ids[i]], b.ps[b.ids[j]]
+	return p.x < q.x || (p.x == q.x && p.y < q.y)
+}
+
+// convexHull returns the convex hull counterclockwise, by Andrew's
+// monotone chain.
+func convexHull(ps []planePoint) []int {
+	ids := make([]int, len(ps))
+	for i := range ids {
+		ids[i] = i
+	}
+	if len(ids) < 3 {
+		return ids
+	}
+	sort.Sort(byXY{ids, ps})
+	hull := make([]int, 0, 2*len(ids))
+	for pass := 0; pass < 2; pass++ {
+		base := len(hull)
+		for _, id := range ids {
+			for len(hull) >= base+2 && orientation(ps[hull[len(hull)-2]],
+				ps[hull[len(hull)-1]], ps[id]) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, id)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(ids)-1; i < j; i, j = i+1, j-1 {
+			ids[i], ids[j] = ids[j], ids[i]
+		}
+	}
+	return hull
+}
@@ -0,0 +1,118 @@
+package geo
+
+import "bytes"
+import "encoding/json"
+import "testing"
+
+// ringArea is the signed area of a ring in degrees, positive if it
+// runs counterclockwise.
+func ringArea(ring []SphereCoords) float64 {
+	var area float64
+	for i, p := range ring {
+		q := ring[(i+1)%len(ring)]
+		area += p.Long*q.Lat - q.Long*p.Lat
+	}
+	return area / 2
+}
+
+func checkHull(t *testing.T, points, ring []SphereCoords) {
+	if len(ring) < 3 || ringArea(ring) <= 0 {
+		t.Fatalf("Incorrect hull: %v", ring)
+	}
+	ps := make([]planePoint, len(ring))
+	ids := make([]int, len(ring))
+	for i, p := range ring {
+		ps[i] = planePoint{p.Long, p.Lat}
+		ids[i] = i
+	}
+	for _, p := range points {
+		if !inRing(ps, ids, planePoint{p.Long, p.Lat}) {
+			t.Errorf("%v is outside the hull %v", p, ring)
+		}
+	}
+	for i := range ids {
+		for j := i + 2; j < len(ids); j++ {
+			if i == 0 && j == len(ids)-1 {
+				continue
+			}
+			if segmentsMeet(ps[i], ps[i+1], ps[j], ps[(j+1)%len(ps)]) {
+				t.Errorf("Hull edges %v and %v cross: %v", i, j, ring)
+			}
+		}
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	points := []SphereCoords{{40, -100}, {41, -99}, {40.5, -99.5},
+		{40, -99}, {41, -100}, {40.2, -99.8}, {40, -99.5}}
+	ring, err := ConcaveHull(points, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHull(t, points, ring)
+	if len(ring) != 4 {
+		t.Errorf("Expected the corners, got %v", ring)
+	}
+	for _, points := range [][]SphereCoords{{{40, -100}, {41, -99}, {40, -100}},
+		{{40, -100}, {40.5, -99.5}, {41, -99}, {40.2, -99.8}}} {
+		if ring, err := ConcaveHull(points, 3); err == nil {
+			t.Errorf("Expected an error for %v, got %v", points, ring)
+		}
+	}
+}
+
+func TestConcaveHull(t *testing.T) {
+	// A C shape: a grid with its right middle cut away.
+	var points []SphereCoords
+	for i := 0; i <= 10; i++ {
+		for j := 0; j <= 10; j++ {
+			if j > 3 && i > 3 && i < 7 {
+				continue
+			}
+			points = append(points, SphereCoords{40 + 0.1*float64(i), -100 + 0.1*float64(j)})
+		}
+	}
+	ring, err := ConcaveHull(points, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHull(t, points, ring)
+	notch := []planePoint{{-99.2, 40.5}}
+	ps := make([]planePoint, len(ring))
+	ids := make([]int, len(ring))
+	for i, p := range ring {
+		ps[i] = planePoint{p.Long, p.Lat}
+		ids[i] = i
+	}
+	if inRing(ps, ids, notch[0]) {
+		t.Errorf("The hull covers the notch: %v", ring)
+	}
+	if thin := Thin(points, 0.25); len(thin) >= len(points) || len(thin) == 0 {
+		t.Errorf("Thin kept %v of %v points", len(thin), len(points))
+	}
+}
+
+func TestAreaGeoJSON(t *testing.T) {
+	area := Area{"Within 1h of Omaha, NE",
+		[]SphereCoords{{41, -96}, {41, -95}, {42, -95.5}}}
+	var buf bytes.Buffer
+	if err := area.WriteGeoJSON(&buf); err != nil {
+		t.Fatal("WriteGeoJSON: ", err)
+	}
+	var f struct {
+		Properties map[string]string
+		Geometry   struct {
+			Type        string
+			Coordinates [][][2]float64
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &f); err != nil {
+		t.Fatal("Unmarshal: ", err)
+	}
+	c := f.Geometry.Coordinates
+	if f.Geometry.Type != "Polygon" || len(c) != 1 || len(c[0]) != 4 ||
+		c[0][0] != c[0][3] || c[0][2] != [2]float64{-95.5, 42} ||
+		f.Properties["name"] != area.Name || len(area.Ring) != 3 {
+		t.Errorf("Incorrect GeoJSON: %s", buf.String())
+	}
+}
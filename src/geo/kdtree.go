@@ -1,6 +1,7 @@
 package geo
 
 import "log"
+import "math"
 import "sort"
 
 import "common"
@@ -35,6 +36,17 @@ func NewTree(graph Graph) *Tree {
 	return &Tree{graph, nil}
 }
 
+// NewBuiltTree returns a tree whose vertices were linked by an
+// earlier Build, e.g., one saved to a file.
+func NewBuiltTree(graph Graph, root Vertex) *Tree {
+	return &Tree{graph, root}
+}
+
+// Root returns the vertex at the root of a built tree.
+func (t *Tree) Root() Vertex {
+	return t.root
+}
+
 func (n Vertices) Len() int      { return len(n) }
 func (n Vertices) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
 
@@ -289,3 +301,49 @@ func (t *Tree) findNearestPoint(point Coords, node Vertex,
 	}
 	return nearest, distance
 }
+
+// FindWithin returns the vertices within radius meters of point,
+// measured along the great circle.
+func (t *Tree) FindWithin(point Coords, radius float64) Vertices {
+	if t.root == nil {
+		return nil
+	}
+	limit := chordLimit(radius)
+	var found Vertices
+	t.findWithin(point, t.root, limit, 0, &found)
+	return found
+}
+
+// chordLimit is the comparable distance of the chord beneath an arc
+// of radius meters.
+func chordLimit(radius float64) compDistance {
+	if radius >= earthDiameter*math.Pi/2 {
+		return infiniteDistance
+	}
+	chord := earthDiameter * math.Sin(radius/earthDiameter) / earthPrecision
+	return compDistance(chord * chord)
+}
+
+func (t *Tree) findWithin(point Coords, node Vertex, limit compDistance,
+	depth int, found *Vertices) {
+	for node != nil {
+		np := node.Point()
+		if comparableDistance(point, np) <= limit {
+			*found = append(*found, node)
+		}
+		s := xyzSorters[depth%3]
+		d := int64(s.Value(point)) - int64(s.Value(np))
+		plane := compDistance(d * d)
+		var closer, farther Vertex
+		if s.Less(point, np) {
+			closer, farther = node.Left(t.graph), node.Right(t.graph)
+		} else {
+			closer, farther = node.Right(t.graph), node.Left(t.graph)
+		}
+		if plane <= limit {
+			t.findWithin(point, farther, limit, depth+1, found)
+		}
+		node = closer
+		depth++
+	}
+}
@@ -128,3 +128,31 @@ func TestTree(t *testing.T) {
 		t.Errorf("Nearest point failed: %s", near)
 	}
 }
+
+func TestFindWithin(t *testing.T) {
+	const N = 2000
+	g := make(testVertices, N)
+	for i, _ := range g {
+		sc := SphereCoords{rand.Float64()*40 + 20, rand.Float64()*60 - 130}
+		tn := &testNode{}
+		sc.ToCoords(tn.coord[:])
+		g[i] = tn
+	}
+	tree := NewTree(g)
+	tree.Build()
+	var center [3]EarthLoc
+	SphereCoords{40, -100}.ToCoords(center[:])
+	for _, radius := range []float64{0, 100000, 500000, 2000000} {
+		found := make(map[Vertex]bool)
+		for _, v := range tree.FindWithin(center[:], radius) {
+			found[v] = true
+		}
+		for _, v := range g {
+			within := GreatCircleDistance(center[:], v.Point()) <= radius
+			if within != found[v] {
+				t.Errorf("Radius %v: %v within %v found %v", radius,
+					v, within, found[v])
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package geo
+
+import "sort"
+
+// LocationIndex finds the known city locations near a point.
+type LocationIndex struct {
+	tree  *Tree
+	nodes locNodes
+}
+
+// Near is a location and its great circle distance in meters.
+type Near struct {
+	CityStateLoc
+	Distance float64
+}
+
+type locNode struct {
+	loc         CityStateLoc
+	coords      [3]EarthLoc
+	left, right Vertex
+}
+
+type locNodes []*locNode
+
+func NewLocationIndex(locs []CityStateLoc) *LocationIndex {
+	idx := &LocationIndex{}
+	for _, loc := range locs {
+		if !loc.Defined() {
+			continue
+		}
+		n := &locNode{loc: loc}
+		loc.ToCoords(n.coords[:])
+		idx.nodes = append(idx.nodes, n)
+	}
+	idx.tree = NewTree(idx.nodes)
+	if len(idx.nodes) != 0 {
+		idx.tree.Build()
+	}
+	return idx
+}
+
+// Within returns the locations within radius meters of center,
+// nearest first.
+func (idx *LocationIndex) Within(center SphereCoords, radius float64) []Near {
+	var c [3]EarthLoc
+	center.ToCoords(c[:])
+	var near []Near
+	for _, v := range idx.tree.FindWithin(c[:], radius) {
+		n := v.(*locNode)
+		near = append(near, Near{n.loc,
+			GreatCircleDistance(c[:], n.coords[:])})
+	}
+	sort.Sort(byDistance(near))
+	return near
+}
+
+type byDistance []Near
+
+func (b byDistance) Len() int      { return len(b) }
+func (b byDistance) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byDistance) Less(i, j int) bool {
+	if b[i].Distance != b[j].Distance {
+		return b[i].Distance < b[j].Distance
+	}
+	return b[i].CityState.String() < b[j].CityState.String()
+}
+
+func (n locNodes) Count() int {
+	return len(n)
+}
+
+func (n locNodes) Node(i int) Vertex {
+	return n[i]
+}
+
+func (n *locNode) Point() Coords {
+	return n.coords[:]
+}
+
+func (n *locNode) Left(_ Graph) Vertex {
+	return n.left
+}
+
+func (n *locNode) Right(_ Graph) Vertex {
+	return n.right
+}
+
+func (n *locNode) SetLeft(_ Graph, l Vertex) {
+	n.left = l
+}
+
+func (n *locNode) SetRight(_ Graph, r Vertex) {
+	n.right = r
+}
+
+func (n *locNode) String() string {
+	return n.loc.String()
+}
@@ -0,0 +1,32 @@
+package geo
+
+import "testing"
+
+import "common"
+
+func TestLocationIndex(t *testing.T) {
+	portland := CityStateLoc{common.CityState{City: "Portland", State: "OR"},
+		SphereCoords{45.52, -122.68}}
+	salem := CityStateLoc{common.CityState{City: "Salem", State: "OR"},
+		SphereCoords{44.94, -123.04}}
+	vancouver := CityStateLoc{common.CityState{City: "Vancouver", State: "WA"},
+		SphereCoords{45.64, -122.66}}
+	boise := CityStateLoc{common.CityState{City: "Boise", State: "ID"},
+		SphereCoords{43.61, -116.20}}
+	unknown := CityStateLoc{common.CityState{City: "Nowhere", State: "OR"},
+		SphereCoords{}}
+	idx := NewLocationIndex([]CityStateLoc{boise, salem, portland,
+		vancouver, unknown})
+	near := idx.Within(portland.SphereCoords, 80000)
+	if len(near) != 3 || near[0].CityState != portland.CityState ||
+		near[1].CityState != vancouver.CityState ||
+		near[2].CityState != salem.CityState {
+		t.Errorf("Incorrect locations: %v", near)
+	}
+	if d := near[2].Distance; d < 65000 || d > 75000 {
+		t.Errorf("Incorrect distance to Salem: %v", d)
+	}
+	if near := NewLocationIndex(nil).Within(boise.SphereCoords, 1e6); len(near) != 0 {
+		t.Errorf("Found locations in an empty index: %v", near)
+	}
+}
@@ -60,6 +60,14 @@ func (sc SphereCoords) ToCoords(c Coords) {
 	c[2] = EarthLoc(z1 * math.MaxInt32)
 }
 
+// Converts scaled 3-d earth points back to Lat/Long in degrees.
+func (c Coords) ToSphereCoords() SphereCoords {
+	x, y, z := float64(c[0]), float64(c[1]), float64(c[2])
+	lat := math.Atan2(z, math.Hypot(x, y))
+	long := math.Atan2(y, x)
+	return SphereCoords{lat * 180.0 / math.Pi, long * 180.0 / math.Pi}
+}
+
 func squareEarthLoc(x EarthLoc) compDistance {
 	return compDistance(x) * compDistance(x)
 }
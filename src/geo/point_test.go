@@ -1,5 +1,6 @@
 package geo
 
+import "math"
 import "testing"
 
 func TestGCD(t *testing.T) {
@@ -17,3 +18,16 @@ func TestGCD(t *testing.T) {
 		t.Errorf("Wrong distance %.9f", dist)
 	}
 }
+
+func TestToSphereCoords(t *testing.T) {
+	for _, sc := range []SphereCoords{
+		{45.5, -122.6}, {-33.9, 151.2}, {0.1, 0.1}, {64.8, -147.7},
+	} {
+		var c [3]EarthLoc
+		sc.ToCoords(c[:])
+		back := Coords(c[:]).ToSphereCoords()
+		if math.Abs(back.Lat-sc.Lat) > 1e-6 || math.Abs(back.Long-sc.Long) > 1e-6 {
+			t.Errorf("Round trip %v got %v", sc, back)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package geo
+
+import "encoding/json"
+import "encoding/xml"
+import "errors"
+import "fmt"
+import "io"
+import "os"
+import "path/filepath"
+import "strings"
+
+// Route is a named line through points, such as the roads of a lane,
+// that can be written for map viewers.
+type Route struct {
+	Name   string
+	Points []SphereCoords
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Properties map[string]string `json:"properties"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+}
+
+// geoJSONPositions lists points as GeoJSON positions, longitude first.
+func geoJSONPositions(points []SphereCoords) [][2]float64 {
+	ps := make([][2]float64, len(points))
+	for i, p := range points {
+		ps[i] = [2]float64{p.Long, p.Lat}
+	}
+	return ps
+}
+
+// writeGeoJSON writes a GeoJSON Feature with a name and a geometry.
+func writeGeoJSON(w io.Writer, name, kind string, coordinates interface{}) error {
+	return json.NewEncoder(w).Encode(geoJSONFeature{"Feature",
+		map[string]string{"name": name},
+		geoJSONGeometry{kind, coordinates}})
+}
+
+// WriteGeoJSON writes the route as a GeoJSON LineString Feature.
+func (r *Route) WriteGeoJSON(w io.Writer) error {
+	return writeGeoJSON(w, r.Name, "LineString", geoJSONPositions(r.Points))
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type gpx struct {
+	XMLName xml.Name   `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version string     `xml:"version,attr"`
+	Creator string     `xml:"creator,attr"`
+	Name    string     `xml:"trk>name"`
+	Points  []gpxPoint `xml:"trk>trkseg>trkpt"`
+}
+
+// WriteGPX writes the route as a GPX track.
+func (r *Route) WriteGPX(w io.Writer) error {
+	doc := gpx{Version: "1.1", Creator: "convoy", Name: r.Name}
+	for _, p := range r.Points {
+		doc.Points = append(doc.Points, gpxPoint{p.Lat, p.Long})
+	}
+	return writeXML(w, doc)
+}
+
+type kmlPlacemark struct {
+	Name       string `xml:"name"`
+	LineString string `xml:"LineString>coordinates"`
+}
+
+type kml struct {
+	XMLName   xml.Name     `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Placemark kmlPlacemark `xml:"Document>Placemark"`
+}
+
+// kmlCoordinates lists points as KML coordinate tuples.
+func kmlCoordinates(points []SphereCoords) string {
+	tuples := make([]string, len(points))
+	for i, p := range points {
+		tuples[i] = fmt.Sprintf("%.7f,%.7f", p.Long, p.Lat)
+	}
+	return strings.Join(tuples, " ")
+}
+
+// WriteKML writes the route as a KML LineString Placemark.
+func (r *Route) WriteKML(w io.Writer) error {
+	return writeXML(w, kml{Placemark: kmlPlacemark{
+		Name: r.Name, LineString: kmlCoordinates(r.Points)}})
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteFile writes the route to a file in the format of its extension:
+// .geojson or .json, .gpx, or .kml.
+func (r *Route) WriteFile(name string) error {
+	var write func(io.Writer) error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".geojson", ".json":
+		write = r.WriteGeoJSON
+	case ".gpx":
+		write = r.WriteGPX
+	case ".kml":
+		write = r.WriteKML
+	default:
+		return errors.New("Unknown route format: " + name)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,62 @@
+package geo
+
+import "bytes"
+import "encoding/json"
+import "encoding/xml"
+import "strings"
+import "testing"
+
+var testRoute = Route{"Portland, OR -> Seattle, WA",
+	[]SphereCoords{{45.5, -122.6}, {46.1, -122.9}, {47.6, -122.3}}}
+
+func TestRouteGeoJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRoute.WriteGeoJSON(&buf); err != nil {
+		t.Fatal("WriteGeoJSON: ", err)
+	}
+	var f struct {
+		Properties map[string]string
+		Geometry   struct {
+			Type        string
+			Coordinates [][2]float64
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &f); err != nil {
+		t.Fatal("Unmarshal: ", err)
+	}
+	if f.Geometry.Type != "LineString" || len(f.Geometry.Coordinates) != 3 ||
+		f.Geometry.Coordinates[2] != [2]float64{-122.3, 47.6} ||
+		f.Properties["name"] != testRoute.Name {
+		t.Errorf("Incorrect GeoJSON: %s", buf.String())
+	}
+}
+
+func TestRouteGPX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRoute.WriteGPX(&buf); err != nil {
+		t.Fatal("WriteGPX: ", err)
+	}
+	var doc gpx
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("Unmarshal: ", err)
+	}
+	if doc.Name != testRoute.Name || len(doc.Points) != 3 ||
+		doc.Points[0] != (gpxPoint{45.5, -122.6}) {
+		t.Errorf("Incorrect GPX: %s", buf.String())
+	}
+}
+
+func TestRouteKML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testRoute.WriteKML(&buf); err != nil {
+		t.Fatal("WriteKML: ", err)
+	}
+	if !strings.Contains(buf.String(),
+		"<coordinates>-122.6000000,45.5000000 -122.9000000,46.1000000 "+
+			"-122.3000000,47.6000000</coordinates>") {
+		t.Errorf("Incorrect KML: %s", buf.String())
+	}
+	if err := testRoute.WriteFile("route.txt"); err == nil {
+		t.Error("Wrote an unknown format")
+	}
+}
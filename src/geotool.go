@@ -1,6 +1,5 @@
 package main
 
-import "database/sql"
 import "flag"
 import "fmt"
 import "log"
@@ -206,7 +205,7 @@ func (cf *CityFinder) findMissingCities() error {
 	return ret
 }
 
-func NewCityFinder(db *sql.DB) (*CityFinder, error) {
+func NewCityFinder(db data.Storage) (*CityFinder, error) {
 	cd, err := data.NewConvoyData(db)
 	if err != nil {
 		return nil, err
@@ -218,7 +217,7 @@ func main() {
 	data.Main(programBody)
 }
 
-func programBody(db *sql.DB) error {
+func programBody(db data.Storage) error {
 	flag.Parse()
 
 	cf, err := NewCityFinder(db)
@@ -0,0 +1,142 @@
+package graph
+
+import "sort"
+
+// alternativePenalty multiplies the weight of the arcs of each path
+// found, steering later searches onto other arcs.
+const alternativePenalty = 1.4
+
+// alternativeRounds bounds the searches made for each route wanted.
+const alternativeRounds = 4
+
+// Alternative is a route found by Alternatives.
+type Alternative struct {
+	Path
+	Overlap []float64 // [i] is the share of Cost on arcs of route i
+}
+
+type byCost []Alternative
+
+func (b byCost) Len() int           { return len(b) }
+func (b byCost) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byCost) Less(i, j int) bool { return b[i].Cost < b[j].Cost }
+
+type arc [2]NodeId
+
+// penalized is a graph whose arcs weigh more each time they are used.
+// The arcs of a TurnGraph are keyed by the base edge they enter, so a
+// road is penalized however it is turned onto.
+type penalized struct {
+	Graph
+	directed bool
+	base     func(NodeId) NodeId
+	penalty  map[arc]float64
+}
+
+func sameNode(n NodeId) NodeId {
+	return n
+}
+
+// arc keys an arc, in either direction if the graph is undirected.
+func (g *penalized) arc(from, to NodeId) arc {
+	from, to = g.base(from), g.base(to)
+	if !g.directed && to < from {
+		from, to = to, from
+	}
+	return arc{from, to}
+}
+
+// arcs returns the keys of the arcs of a path.
+func (g *penalized) arcs(nodes []NodeId) map[arc]bool {
+	used := make(map[arc]bool)
+	for i := 0; i+1 < len(nodes); i++ {
+		used[g.arc(nodes[i], nodes[i+1])] = true
+	}
+	return used
+}
+
+func (g *penalized) Weight(from, to NodeId) float64 {
+	w := g.Graph.Weight(from, to)
+	if p, has := g.penalty[g.arc(from, to)]; has {
+		w *= p
+	}
+	return w
+}
+
+// Alternatives returns up to k routes from start to end, shortest
+// first, found by penalizing the arcs of each route and searching
+// again.  A route is kept when it costs at most stretch times the
+// shortest, and at most overlap of its cost is on arcs of any route
+// kept before it was found.  Over a TurnGraph, overlap is measured on
+// base edges.
+func Alternatives(g Graph, start, end NodeId, k int, overlap, stretch float64) []Alternative {
+	_, directed := g.(DirectedGraph)
+	pg := &penalized{g, directed, sameNode, make(map[arc]float64)}
+	if tg, ok := g.(*TurnGraph); ok {
+		_, pg.directed = tg.g.(DirectedGraph)
+		pg.base = tg.Base
+	}
+	r := NewRouter(pg)
+	var routes []Alternative
+	var arcs []map[arc]bool
+	for round := 0; len(routes) < k && round < k*alternativeRounds; round++ {
+		nodes := r.ShortestPath(start, end, nil)
+		if nodes == nil {
+			break
+		}
+		alt := Alternative{Path: Path{Nodes: nodes, Costs: make([]float64, len(nodes)-1)}}
+		alt.Settled[0] = r.Settled
+		used := pg.arcs(nodes)
+		for i := 0; i+1 < len(nodes); i++ {
+			alt.Costs[i] = g.Weight(nodes[i], nodes[i+1])
+			alt.Cost += alt.Costs[i]
+		}
+		for a := range used {
+			if p, has := pg.penalty[a]; has {
+				pg.penalty[a] = p * alternativePenalty
+			} else {
+				pg.penalty[a] = alternativePenalty
+			}
+		}
+		if len(routes) != 0 && alt.Cost > stretch*routes[0].Cost {
+			continue
+		}
+		keep := true
+		for _, other := range arcs {
+			share := alt.share(pg, other)
+			keep = keep && share <= overlap && share < 1
+		}
+		if keep {
+			routes = append(routes, alt)
+			arcs = append(arcs, used)
+		}
+		if alt.Cost == 0 {
+			break
+		}
+	}
+	sort.Stable(byCost(routes))
+	for i := range routes {
+		arcs[i] = pg.arcs(routes[i].Nodes)
+	}
+	for i := range routes {
+		routes[i].Overlap = make([]float64, len(routes))
+		for j, other := range arcs {
+			routes[i].Overlap[j] = routes[i].share(pg, other)
+		}
+	}
+	return routes
+}
+
+// share returns the share of the cost of the route on arcs.
+func (alt *Alternative) share(pg *penalized, arcs map[arc]bool) float64 {
+	if alt.Cost == 0 {
+		return 1
+	}
+	var shared float64
+	for i, c := range alt.Costs {
+		if arcs[pg.arc(alt.Nodes[i], alt.Nodes[i+1])] {
+			shared += c
+		}
+	}
+	return shared / alt.Cost
+}
@@ -0,0 +1,101 @@
+package graph
+
+import "container/heap"
+import "math"
+
+// Heuristic bounds the weight of the shortest path between two nodes
+// from below, directing a Router's search toward the end.  A bound
+// that is not consistent costs time but not optimality.
+type Heuristic interface {
+	Bound(from, to NodeId) float64
+}
+
+// Router answers shortest path queries by searching from the start
+// toward the end.  Its search state is reused between queries, so
+// that each touches only the nodes it searches.  A Router is not safe
+// for concurrent use.
+type Router struct {
+	g      Graph
+	labels []routeLabel
+	round  uint32
+	queue  chHeap
+
+	// Settled is the number of nodes settled by the last query.
+	Settled int
+}
+
+type routeLabel struct {
+	dist     float64
+	estimate float64 // Bound on the weight to the end
+	round    uint32  // Query in which the label was set
+	parent   NodeId
+}
+
+func NewRouter(g Graph) *Router {
+	return &Router{g: g, labels: make([]routeLabel, g.Count()+1)}
+}
+
+// ShortestPath returns a shortest path from start to end, or nil if
+// there is none.  With a nil Heuristic this is Dijkstra's algorithm,
+// and with one it is A*, which settles fewer nodes the tighter the
+// bounds.
+func (r *Router) ShortestPath(start, end NodeId, h Heuristic) []NodeId {
+	if r.round++; r.round == 0 {
+		for i := range r.labels {
+			r.labels[i].round = 0
+		}
+		r.round = 1
+	}
+	r.Settled = 0
+	r.queue = r.queue[:0]
+	r.relax(start, end, 0, ZeroNodeId, h)
+	for r.queue.Len() != 0 {
+		it := heap.Pop(&r.queue).(chItem)
+		u := it.id
+		l := &r.labels[u]
+		if it.key > l.dist+l.estimate {
+			continue
+		}
+		r.Settled++
+		if u == end {
+			return r.path(end)
+		}
+		for _, n := range r.g.Neighbors(u) {
+			r.relax(n, end, l.dist+r.g.Weight(u, n), u, h)
+		}
+	}
+	return nil
+}
+
+func (r *Router) relax(n, end NodeId, dist float64, parent NodeId, h Heuristic) {
+	l := &r.labels[n]
+	if l.round == r.round {
+		if dist >= l.dist {
+			return
+		}
+	} else {
+		l.round = r.round
+		l.estimate = 0
+		if h != nil {
+			l.estimate = math.Max(h.Bound(n, end), 0)
+		}
+	}
+	l.dist = dist
+	l.parent = parent
+	if !math.IsInf(l.estimate, 1) {
+		heap.Push(&r.queue, chItem{n, dist + l.estimate})
+	}
+}
+
+func (r *Router) path(end NodeId) []NodeId {
+	count := 0
+	for n := end; n != ZeroNodeId; n = r.labels[n].parent {
+		count++
+	}
+	path := make([]NodeId, count)
+	for n := end; n != ZeroNodeId; n = r.labels[n].parent {
+		count--
+		path[count] = n
+	}
+	return path
+}
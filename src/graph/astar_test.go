@@ -0,0 +1,203 @@
+package graph
+
+import "bytes"
+import "math"
+import "math/rand"
+import "testing"
+
+// plane places nodes at points, bounding weights by straight lines.
+type plane [][2]float64
+
+func (p plane) Bound(from, to NodeId) float64 {
+	return math.Hypot(p[from][0]-p[to][0], p[from][1]-p[to][1])
+}
+
+// planeGraph builds a graph whose weights are at least the distance
+// between their nodes.
+func planeGraph(nodes, edges int, rnd *rand.Rand) (*graph, plane) {
+	g := newGraph()
+	p := make(plane, nodes+1)
+	for i := 1; i <= nodes; i++ {
+		g.addNode()
+		p[i] = [2]float64{float64(rnd.Intn(1000)), float64(rnd.Intn(1000))}
+	}
+	for i := 0; i < edges; i++ {
+		from := NodeId(rnd.Intn(nodes)) + FirstNodeId
+		to := NodeId(rnd.Intn(nodes)) + FirstNodeId
+		if from == to {
+			continue
+		}
+		weight := math.Ceil(p.Bound(from, to) * (1 + rnd.Float64()))
+		if i%3 == 0 {
+			g.addArc(from, to, weight)
+		} else {
+			g.addEdge(from, to, weight)
+		}
+	}
+	return g, p
+}
+
+func checkRouter(t *testing.T, g Graph, r *Router, h Heuristic, from NodeId) int {
+	expect := dijkstraFrom(g, from)
+	settled := 0
+	for to := FirstNodeId; to <= NodeId(g.Count()); to++ {
+		path := r.ShortestPath(from, to, h)
+		settled += r.Settled
+		if math.IsInf(expect[to], 1) {
+			if path != nil {
+				t.Errorf("Found a path %v->%v: %v", from, to, path)
+			}
+			continue
+		}
+		if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+			t.Errorf("Incorrect path %v->%v: %v", from, to, path)
+			continue
+		}
+		var sum float64
+		for i := 0; i+1 < len(path); i++ {
+			sum += g.Weight(path[i], path[i+1])
+		}
+		if sum != expect[to] {
+			t.Errorf("Path %v->%v weighs %v want %v", from, to, sum, expect[to])
+		}
+	}
+	return settled
+}
+
+func TestRouter(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	g, p := planeGraph(200, 600, rnd)
+	landmarks := NewLandmarks(g, 8)
+	if len(landmarks.Nodes()) != 8 {
+		t.Errorf("Incorrect landmarks: %v", landmarks.Nodes())
+	}
+	r := NewRouter(g)
+	for i := 0; i < 5; i++ {
+		from := NodeId(rnd.Intn(g.Count())) + FirstNodeId
+		dijkstra := checkRouter(t, g, r, nil, from)
+		astar := checkRouter(t, g, r, p, from)
+		alt := checkRouter(t, g, r, landmarks, from)
+		if astar > dijkstra || alt > dijkstra {
+			t.Errorf("Settled %v by A* and %v by ALT, %v by Dijkstra",
+				astar, alt, dijkstra)
+		}
+	}
+}
+
+func TestLandmarksTurns(t *testing.T) {
+	rnd := rand.New(rand.NewSource(6))
+	g := randomGraph(40, 120, true, rnd)
+	var rs []Restriction
+	for u := FirstNodeId; u <= NodeId(g.Count()); u++ {
+		for _, v := range g.Neighbors(u) {
+			for _, w := range g.Neighbors(v) {
+				if rnd.Intn(3) == 0 {
+					rs = append(rs, Restriction{Turn{u, v, w}, false})
+				}
+			}
+		}
+	}
+	tg := NewTurnGraph(g, rs)
+	landmarks := NewLandmarks(tg, 4)
+	r := NewRouter(tg)
+	for i := 0; i < 5; i++ {
+		checkRouter(t, tg, r, landmarks, tg.Source(NodeId(rnd.Intn(g.Count()))+FirstNodeId))
+	}
+}
+
+func TestLandmarksFile(t *testing.T) {
+	g := randomGraph(50, 150, true, rand.New(rand.NewSource(7)))
+	landmarks := NewLandmarks(g, 4)
+	landmarks.Tag = "graph=1 metric=seconds"
+	var buf bytes.Buffer
+	if err := landmarks.Write(&buf); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	read, err := ReadLandmarks(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal("ReadLandmarks: ", err)
+	}
+	if read.Count() != g.Count() || len(read.Nodes()) != 4 || read.Tag != landmarks.Tag {
+		t.Errorf("Incorrect landmarks: %v %v %q", read.Count(), read.Nodes(), read.Tag)
+	}
+	for from := FirstNodeId; from <= NodeId(g.Count()); from++ {
+		for to := FirstNodeId; to <= NodeId(g.Count()); to++ {
+			if read.Bound(from, to) != landmarks.Bound(from, to) {
+				t.Fatalf("Bound %v->%v changed", from, to)
+			}
+		}
+	}
+	if _, err := ReadLandmarks(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Error("Read a truncated file")
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	rnd := rand.New(rand.NewSource(9))
+	g, _ := planeGraph(200, 800, rnd)
+	found := 0
+	for i := 0; i < 20; i++ {
+		from := NodeId(rnd.Intn(g.Count())) + FirstNodeId
+		to := NodeId(rnd.Intn(g.Count())) + FirstNodeId
+		expect := dijkstraFrom(g, from)[to]
+		routes := Alternatives(g, from, to, 3, 0.6, 1.5)
+		if math.IsInf(expect, 1) {
+			if len(routes) != 0 {
+				t.Errorf("Found routes %v->%v: %v", from, to, routes)
+			}
+			continue
+		}
+		if len(routes) == 0 || len(routes) > 3 || routes[0].Cost != expect {
+			t.Fatalf("Incorrect routes %v->%v costing %v: %v", from, to, expect, routes)
+		}
+		found += len(routes)
+		for j, r := range routes {
+			checkPath(t, r.Nodes[:1], []NodeId{from})
+			checkPath(t, r.Nodes[len(r.Nodes)-1:], []NodeId{to})
+			var sum float64
+			seen := make(map[NodeId]bool)
+			for k, n := range r.Nodes {
+				if seen[n] {
+					t.Errorf("Route %v revisits %v", r.Nodes, n)
+				}
+				seen[n] = true
+				if k+1 < len(r.Nodes) {
+					sum += g.Weight(n, r.Nodes[k+1])
+				}
+			}
+			if sum != r.Cost || r.Cost > 1.5*expect || len(r.Overlap) != len(routes) ||
+				r.Overlap[j] != 1 {
+				t.Errorf("Incorrect route %v of %v->%v: %v", j, from, to, r)
+			}
+			for k := 0; k < j; k++ {
+				if r.Cost < routes[k].Cost {
+					t.Errorf("Route %v costs less than route %v: %v", j, k, routes)
+				}
+				if r.Overlap[k] > 0.6 && routes[k].Overlap[j] > 0.6 {
+					t.Errorf("Routes %v and %v overlap by %v", j, k, r.Overlap[k])
+				}
+			}
+		}
+
+		// Turns reach the same roads, so overlap the same.
+		tg := NewTurnGraph(g, nil)
+		turned := Alternatives(tg, tg.Source(from), tg.Sink(to), 3, 0.6, 1.5)
+		if len(turned) != len(routes) {
+			t.Fatalf("Turn graph found %v routes %v->%v, not %v", len(turned), from, to, len(routes))
+		}
+		for j, r := range turned {
+			if math.Abs(r.Cost-routes[j].Cost) > 1e-9*r.Cost {
+				t.Errorf("Turn graph route %v costs %v, not %v", j, r.Cost, routes[j].Cost)
+			}
+			for k, o := range r.Overlap {
+				if math.Abs(o-routes[j].Overlap[k]) > 1e-9 {
+					t.Errorf("Turn graph route %v overlaps %v by %v, not %v",
+						j, k, o, routes[j].Overlap[k])
+				}
+			}
+		}
+	}
+	if found <= 20 {
+		t.Errorf("Found only %v routes", found)
+	}
+}
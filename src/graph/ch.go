@@ -0,0 +1,375 @@
+// Contraction hierarchies, after Geisberger et al. (2008).  Nodes are
+// contracted one at a time in order of increasing importance, adding
+// shortcut edges that preserve shortest distances among the nodes that
+// remain.  A query is a bidirectional Dijkstra search in which both
+// directions only follow edges toward more important nodes.
+
+package graph
+
+import "container/heap"
+import "math"
+
+// witnessLimit bounds the nodes settled while searching for a path
+// that makes a shortcut unnecessary.  Giving up early only adds
+// shortcuts, it never loses a shortest path.
+const witnessLimit = 500
+
+type chEdge struct {
+	to     NodeId
+	weight float64
+	middle NodeId  // The contracted node a shortcut skips, or ZeroNodeId.
+	hops   int     // The number of original edges.
+	length float64 // The sum of their lengths.
+}
+
+// CH is a contraction hierarchy.  up[n] holds the edges n->x and
+// down[n] holds the edges x->n (as x) for which x was contracted
+// after n.
+type CH struct {
+	up   [][]chEdge
+	down [][]chEdge
+
+	// Tag identifies the graph and weights contracted, and is saved
+	// with the hierarchy.
+	Tag string
+}
+
+type chItem struct {
+	id  NodeId
+	key float64
+}
+
+// chHeap is a priority queue that allows stale duplicate entries.
+type chHeap []chItem
+
+type contractor struct {
+	out, in    [][]chEdge
+	contracted []bool
+	deleted    []int // Count of contracted neighbors.
+	priority   []float64
+	dist       []float64
+	touched    []NodeId
+	queue      chHeap
+	ch         *CH
+}
+
+// Contract builds a contraction hierarchy for g.  Edges may be
+// directed: only Neighbors(n) of each node n are followed.
+func Contract(g Graph) *CH {
+	n := g.Count() + 1
+	c := &contractor{
+		out:        make([][]chEdge, n),
+		in:         make([][]chEdge, n),
+		contracted: make([]bool, n),
+		deleted:    make([]int, n),
+		priority:   make([]float64, n),
+		dist:       make([]float64, n),
+		ch:         &CH{make([][]chEdge, n), make([][]chEdge, n), ""},
+	}
+	for i := range c.dist {
+		c.dist[i] = math.Inf(1)
+	}
+	for u := FirstNodeId; u < NodeId(n); u++ {
+		for _, v := range g.Neighbors(u) {
+			if u != v {
+				c.addArc(u, chEdge{v, g.Weight(u, v), ZeroNodeId, 1, length(g, u, v)})
+			}
+		}
+	}
+	order := &chHeap{}
+	for v := FirstNodeId; v < NodeId(n); v++ {
+		c.priority[v] = c.importance(v)
+		heap.Push(order, chItem{v, c.priority[v]})
+	}
+	for order.Len() != 0 {
+		it := heap.Pop(order).(chItem)
+		v := it.id
+		if c.contracted[v] || it.key != c.priority[v] {
+			continue
+		}
+		// Lazy update: the priority may have grown since it
+		// was queued.
+		c.priority[v] = c.importance(v)
+		if order.Len() != 0 && c.priority[v] > (*order)[0].key {
+			heap.Push(order, chItem{v, c.priority[v]})
+			continue
+		}
+		for _, x := range c.contract(v) {
+			c.priority[x] = c.importance(x)
+			heap.Push(order, chItem{x, c.priority[x]})
+		}
+	}
+	return c.ch
+}
+
+// setMin adds e to edges, or lowers the weight of an existing edge to
+// the same node.
+func setMin(edges *[]chEdge, e chEdge) {
+	for i := range *edges {
+		if (*edges)[i].to == e.to {
+			if e.weight < (*edges)[i].weight {
+				(*edges)[i] = e
+			}
+			return
+		}
+	}
+	*edges = append(*edges, e)
+}
+
+func removeEdge(edges []chEdge, to NodeId) []chEdge {
+	for i := range edges {
+		if edges[i].to == to {
+			edges[i] = edges[len(edges)-1]
+			return edges[:len(edges)-1]
+		}
+	}
+	return edges
+}
+
+func (c *contractor) addArc(from NodeId, e chEdge) {
+	setMin(&c.out[from], e)
+	setMin(&c.in[e.to], chEdge{from, e.weight, e.middle, e.hops, e.length})
+}
+
+// importance is the edge difference of contracting v, plus the number
+// of its neighbors already contracted to spread contraction evenly.
+func (c *contractor) importance(v NodeId) float64 {
+	added := c.shortcuts(v, false)
+	return float64(added - len(c.in[v]) - len(c.out[v]) + c.deleted[v])
+}
+
+// shortcuts counts the shortcuts needed to contract v, and adds them
+// when apply is true.
+func (c *contractor) shortcuts(v NodeId, apply bool) int {
+	count := 0
+	for _, ie := range c.in[v] {
+		u := ie.to
+		limit := -1.0
+		for _, oe := range c.out[v] {
+			if oe.to != u && ie.weight+oe.weight > limit {
+				limit = ie.weight + oe.weight
+			}
+		}
+		if limit < 0 {
+			continue
+		}
+		c.witness(u, v, limit)
+		for _, oe := range c.out[v] {
+			w := ie.weight + oe.weight
+			if oe.to == u || c.dist[oe.to] <= w {
+				continue
+			}
+			count++
+			if apply {
+				c.addArc(u, chEdge{oe.to, w, v, ie.hops + oe.hops,
+					ie.length + oe.length})
+			}
+		}
+	}
+	return count
+}
+
+// witness computes in c.dist the distances from source that avoid
+// the node being contracted, up to limit.
+func (c *contractor) witness(source, avoid NodeId, limit float64) {
+	for _, t := range c.touched {
+		c.dist[t] = math.Inf(1)
+	}
+	c.touched = append(c.touched[:0], source)
+	c.dist[source] = 0
+	c.queue = append(c.queue[:0], chItem{source, 0})
+	settled := 0
+	for c.queue.Len() != 0 && settled < witnessLimit {
+		it := heap.Pop(&c.queue).(chItem)
+		if it.key > c.dist[it.id] {
+			continue
+		}
+		if it.key > limit {
+			break
+		}
+		settled++
+		for _, e := range c.out[it.id] {
+			d := it.key + e.weight
+			if e.to == avoid || d > limit || d >= c.dist[e.to] {
+				continue
+			}
+			if math.IsInf(c.dist[e.to], 1) {
+				c.touched = append(c.touched, e.to)
+			}
+			c.dist[e.to] = d
+			heap.Push(&c.queue, chItem{e.to, d})
+		}
+	}
+}
+
+// contract removes v from the remaining graph, recording its edges in
+// the hierarchy.  Returns the neighbors whose priority changed.
+func (c *contractor) contract(v NodeId) []NodeId {
+	c.shortcuts(v, true)
+	c.ch.up[v] = c.out[v]
+	c.ch.down[v] = c.in[v]
+	var touched []NodeId
+	for _, e := range c.out[v] {
+		c.in[e.to] = removeEdge(c.in[e.to], v)
+		c.deleted[e.to]++
+		touched = append(touched, e.to)
+	}
+	for _, e := range c.in[v] {
+		c.out[e.to] = removeEdge(c.out[e.to], v)
+		c.deleted[e.to]++
+		touched = append(touched, e.to)
+	}
+	c.out[v], c.in[v] = nil, nil
+	c.contracted[v] = true
+	return touched
+}
+
+// Count returns the number of nodes in the hierarchy.
+func (ch *CH) Count() int {
+	return len(ch.up) - 1
+}
+
+type chLabel struct {
+	dist   float64
+	hops   int
+	length float64
+	parent NodeId
+	middle NodeId
+}
+
+type chSearch struct {
+	edges  [][]chEdge
+	labels map[NodeId]chLabel
+	queue  chHeap
+}
+
+func newSearch(edges [][]chEdge, source NodeId) *chSearch {
+	s := &chSearch{edges, make(map[NodeId]chLabel), nil}
+	s.labels[source] = chLabel{0, 0, 0, ZeroNodeId, ZeroNodeId}
+	heap.Push(&s.queue, chItem{source, 0})
+	return s
+}
+
+// step settles one node, returns false when the search is exhausted
+// or cannot improve on best.
+func (s *chSearch) step(best float64) (NodeId, bool) {
+	for s.queue.Len() != 0 {
+		it := heap.Pop(&s.queue).(chItem)
+		if it.key >= best {
+			s.queue = nil
+			return ZeroNodeId, false
+		}
+		if it.key > s.labels[it.id].dist {
+			continue
+		}
+		for _, e := range s.edges[it.id] {
+			d := it.key + e.weight
+			if l, has := s.labels[e.to]; has && l.dist <= d {
+				continue
+			}
+			l := s.labels[it.id]
+			s.labels[e.to] = chLabel{d, l.hops + e.hops, l.length + e.length,
+				it.id, e.middle}
+			heap.Push(&s.queue, chItem{e.to, d})
+		}
+		return it.id, true
+	}
+	return ZeroNodeId, false
+}
+
+// query returns the shortest distance and the most important node on
+// the shortest path, or ZeroNodeId if there is no path.
+func (ch *CH) query(from, to NodeId) (float64, NodeId, [2]*chSearch) {
+	s := [2]*chSearch{newSearch(ch.up, from), newSearch(ch.down, to)}
+	best := math.Inf(1)
+	mid := ZeroNodeId
+	live := [2]bool{true, true}
+	for dir := 0; live[0] || live[1]; dir = 1 - dir {
+		if !live[dir] {
+			continue
+		}
+		var n NodeId
+		if n, live[dir] = s[dir].step(best); !live[dir] {
+			continue
+		}
+		if l, has := s[1-dir].labels[n]; has {
+			if d := s[dir].labels[n].dist + l.dist; d < best {
+				best, mid = d, n
+			}
+		}
+	}
+	return best, mid, s
+}
+
+// Distance returns the weight of the shortest path between two nodes,
+// false if there is none.
+func (ch *CH) Distance(from, to NodeId) (float64, bool) {
+	d, mid, _ := ch.query(from, to)
+	return d, mid != ZeroNodeId
+}
+
+// ShortestPath returns the nodes of a shortest path from start to end,
+// with shortcuts expanded, or nil if there is none.
+func (ch *CH) ShortestPath(start, end NodeId) []NodeId {
+	_, mid, s := ch.query(start, end)
+	if mid == ZeroNodeId {
+		return nil
+	}
+	var up []NodeId
+	for n := mid; n != start; n = s[0].labels[n].parent {
+		up = append(up, n)
+	}
+	path := []NodeId{start}
+	for i, from := len(up)-1, start; i >= 0; i-- {
+		path = ch.unpack(from, up[i], s[0].labels[up[i]].middle, path)
+		from = up[i]
+	}
+	for n := mid; n != end; {
+		l := s[1].labels[n]
+		path = ch.unpack(n, l.parent, l.middle, path)
+		n = l.parent
+	}
+	return path
+}
+
+// unpack appends the nodes after from on the edge from->to, expanding
+// shortcuts recursively.
+func (ch *CH) unpack(from, to, middle NodeId, path []NodeId) []NodeId {
+	if middle == ZeroNodeId {
+		return append(path, to)
+	}
+	path = ch.unpack(from, middle, findEdge(ch.down[middle], from).middle, path)
+	return ch.unpack(middle, to, findEdge(ch.up[middle], to).middle, path)
+}
+
+func findEdge(edges []chEdge, to NodeId) chEdge {
+	for _, e := range edges {
+		if e.to == to {
+			return e
+		}
+	}
+	panic("Shortcut edge not found")
+}
+
+func (h chHeap) Len() int {
+	return len(h)
+}
+
+func (h chHeap) Less(i, j int) bool {
+	return h[i].key < h[j].key
+}
+
+func (h chHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *chHeap) Push(x interface{}) {
+	*h = append(*h, x.(chItem))
+}
+
+func (h *chHeap) Pop() interface{} {
+	n := len(*h)
+	x := (*h)[n-1]
+	*h = (*h)[:n-1]
+	return x
+}
@@ -0,0 +1,131 @@
+package graph
+
+import "bytes"
+import "math"
+import "math/rand"
+import "testing"
+
+// dijkstraFrom is a plain quadratic Dijkstra used as a reference.
+func dijkstraFrom(g Graph, start NodeId) []float64 {
+	dist := make([]float64, g.Count()+1)
+	done := make([]bool, g.Count()+1)
+	for i := range dist {
+		dist[i] = math.Inf(1)
+	}
+	dist[start] = 0
+	for {
+		u := ZeroNodeId
+		for n := FirstNodeId; n <= NodeId(g.Count()); n++ {
+			if !done[n] && !math.IsInf(dist[n], 1) &&
+				(u == ZeroNodeId || dist[n] < dist[u]) {
+				u = n
+			}
+		}
+		if u == ZeroNodeId {
+			return dist
+		}
+		done[u] = true
+		for _, v := range g.Neighbors(u) {
+			if d := dist[u] + g.Weight(u, v); d < dist[v] {
+				dist[v] = d
+			}
+		}
+	}
+}
+
+func randomGraph(nodes, edges int, directed bool, rnd *rand.Rand) *graph {
+	g := newGraph()
+	for i := 0; i < nodes; i++ {
+		g.addNode()
+	}
+	for i := 0; i < edges; i++ {
+		from := NodeId(rnd.Intn(nodes)) + FirstNodeId
+		to := NodeId(rnd.Intn(nodes)) + FirstNodeId
+		if from == to {
+			continue
+		}
+		weight := float64(1 + rnd.Intn(100))
+		if directed {
+			g.addArc(from, to, weight)
+		} else {
+			g.addEdge(from, to, weight)
+		}
+	}
+	return g
+}
+
+func checkCH(t *testing.T, g Graph, ch *CH) {
+	for from := FirstNodeId; from <= NodeId(g.Count()); from++ {
+		expect := dijkstraFrom(g, from)
+		for to := FirstNodeId; to <= NodeId(g.Count()); to++ {
+			d, ok := ch.Distance(from, to)
+			if math.IsInf(expect[to], 1) {
+				if ok || ch.ShortestPath(from, to) != nil {
+					t.Errorf("Found a path %v->%v", from, to)
+				}
+				continue
+			}
+			if !ok || d != expect[to] {
+				t.Errorf("Distance %v->%v got %v %v want %v",
+					from, to, d, ok, expect[to])
+				continue
+			}
+			path := ch.ShortestPath(from, to)
+			if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+				t.Errorf("Incorrect path %v->%v: %v", from, to, path)
+				continue
+			}
+			var sum float64
+			for i := 0; i < len(path)-1; i++ {
+				sum += g.Weight(path[i], path[i+1])
+			}
+			if sum != d {
+				t.Errorf("Path %v->%v weighs %v want %v", from, to, sum, d)
+			}
+		}
+	}
+}
+
+func TestContract(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10; i++ {
+		g := randomGraph(60, 150, i%2 == 1, rnd)
+		checkCH(t, g, Contract(g))
+	}
+}
+
+func TestContractChain(t *testing.T) {
+	g := newGraph()
+	prev := g.addNode()
+	for i := 0; i < 20; i++ {
+		n := g.addNode()
+		g.addEdge(prev, n, 0.5)
+		prev = n
+	}
+	checkCH(t, g, Contract(g))
+	if path := Contract(g).ShortestPath(FirstNodeId, prev); len(path) != 21 {
+		t.Errorf("Incorrect chain path: %v", path)
+	}
+}
+
+func TestCHFile(t *testing.T) {
+	g := randomGraph(50, 120, true, rand.New(rand.NewSource(2)))
+	var buf bytes.Buffer
+	contracted := Contract(g)
+	contracted.Tag = "graph=1 metric=meters"
+	if err := contracted.Write(&buf); err != nil {
+		t.Fatal("Write: ", err)
+	}
+	ch, err := ReadCH(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal("ReadCH: ", err)
+	}
+	if ch.Count() != g.Count() || ch.Tag != contracted.Tag {
+		t.Errorf("Incorrect hierarchy got %v %q want %v %q",
+			ch.Count(), ch.Tag, g.Count(), contracted.Tag)
+	}
+	checkCH(t, g, ch)
+	if _, err := ReadCH(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Error("Read a truncated file")
+	}
+}
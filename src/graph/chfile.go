@@ -0,0 +1,117 @@
+package graph
+
+import "bufio"
+import "encoding/binary"
+import "errors"
+import "io"
+
+// chMagic begins a contraction hierarchy file, followed by the tag,
+// the node count and, for each node, its up and down edge lists.
+const chMagic = "convoy-ch-4\n"
+
+// maxTagSize bounds the tag read from a file.
+const maxTagSize = 1 << 16
+
+type chDiskEdge struct {
+	To     uint32
+	Weight float64
+	Middle uint32
+	Hops   uint32
+	Length float64
+}
+
+// Write saves the hierarchy in a binary format read by ReadCH.
+func (ch *CH) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(chMagic); err != nil {
+		return err
+	}
+	if err := writeTag(bw, ch.Tag); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, uint32(ch.Count())); err != nil {
+		return err
+	}
+	for n := FirstNodeId; n <= NodeId(ch.Count()); n++ {
+		for _, edges := range [][]chEdge{ch.up[n], ch.down[n]} {
+			disk := make([]chDiskEdge, len(edges))
+			for i, e := range edges {
+				disk[i] = chDiskEdge{uint32(e.to), e.weight, uint32(e.middle),
+					uint32(e.hops), e.length}
+			}
+			if err := binary.Write(bw, binary.LittleEndian, uint32(len(disk))); err != nil {
+				return err
+			}
+			if err := binary.Write(bw, binary.LittleEndian, disk); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadCH loads a hierarchy saved by Write.
+func ReadCH(r io.Reader) (*CH, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(chMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != chMagic {
+		return nil, errors.New("Not a contraction hierarchy file")
+	}
+	tag, err := readTag(br)
+	if err != nil {
+		return nil, err
+	}
+	var count uint32
+	if err := binary.Read(br, binary.LittleEndian, &count); err != nil {
+		return nil, err
+	}
+	ch := &CH{make([][]chEdge, count+1), make([][]chEdge, count+1), tag}
+	for n := FirstNodeId; n <= NodeId(count); n++ {
+		for _, edges := range []*[]chEdge{&ch.up[n], &ch.down[n]} {
+			var size uint32
+			if err := binary.Read(br, binary.LittleEndian, &size); err != nil {
+				return nil, err
+			}
+			disk := make([]chDiskEdge, size)
+			if err := binary.Read(br, binary.LittleEndian, disk); err != nil {
+				return nil, err
+			}
+			for _, e := range disk {
+				if e.To == 0 || e.To > count || e.Middle > count {
+					return nil, errors.New("Corrupt contraction hierarchy file")
+				}
+				*edges = append(*edges, chEdge{NodeId(e.To), e.Weight,
+					NodeId(e.Middle), int(e.Hops), e.Length})
+			}
+		}
+	}
+	return ch, nil
+}
+
+// writeTag writes a length-prefixed string identifying what a file
+// was computed from.
+func writeTag(w io.Writer, tag string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(tag))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, tag)
+	return err
+}
+
+func readTag(r io.Reader) (string, error) {
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return "", err
+	}
+	if size > maxTagSize {
+		return "", errors.New("Corrupt tag")
+	}
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return "", err
+	}
+	return string(tag), nil
+}
@@ -7,36 +7,55 @@ type construct struct {
 	edges Edgelist
 }
 
+// Condense returns the edges between kept nodes, each standing for a
+// path of g through nodes that are not kept.  On a TurnGraph, keeping
+// the sources, sinks and edges into kept base nodes (see Base), the
+// condensed graph obeys the turn restrictions.
 func Condense(g Graph, keepf func(n NodeId) bool) Edgelist {
 	c := &construct{g: g, keepf: keepf, busy: make(map[NodeId]bool)}
 	for n := FirstNodeId; n <= NodeId(g.Count()); n++ {
 		if !c.keepf(n) {
 			continue
 		}
-		c.condenseNode(n, n, 0.0)
+		c.condenseNode(n, n, 0.0, true)
 	}
 	return c.edges
 }
 
-func (c *construct) condenseNode(start, n NodeId, weight float64) {
+func (c *construct) condenseNode(start, n NodeId, weight float64, twoway bool) {
 	if c.busy[n] {
 		return
 	}
 	c.busy[n] = true
 	for _, nn := range c.g.Neighbors(n) {
-		c.condenseEdge(start, n, nn, weight)
+		c.condenseEdge(start, n, nn, weight, twoway)
 	}
 	c.busy[n] = false
 }
 
-func (c *construct) condenseEdge(start, pos, end NodeId, accum float64) {
+// condenseEdge follows the path from start through pos to end.  A path
+// that can be driven in reverse is added once, from its lower node.
+func (c *construct) condenseEdge(start, pos, end NodeId, accum float64, twoway bool) {
 	accum += c.g.Weight(pos, end)
+	twoway = twoway && hasNeighbor(c.g, end, pos) &&
+		c.g.Weight(end, pos) == c.g.Weight(pos, end)
 	if c.keepf(end) {
-		if start < end {
-			c.edges = append(c.edges, Edge{start, end, accum})
+		if !twoway {
+			c.edges = append(c.edges, Edge{start, end, accum, true})
+		} else if start < end {
+			c.edges = append(c.edges, Edge{start, end, accum, false})
 		}
 		return
 	}
-	c.condenseNode(start, end, accum)
+	c.condenseNode(start, end, accum, twoway)
+}
+
+func hasNeighbor(g Graph, n, nn NodeId) bool {
+	for _, x := range g.Neighbors(n) {
+		if x == nn {
+			return true
+		}
+	}
+	return false
 }
 
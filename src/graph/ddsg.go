@@ -10,9 +10,13 @@ func WriteDdsg(nodeCount int, edges Edgelist, w io.Writer) error {
 		return err
 	}
 	for _, e := range edges {
-		_, err = w.Write([]byte(fmt.Sprintf("%d %d %d 0\n",
+		direction := 0  // Both ways
+		if e.oneway {
+			direction = 1
+		}
+		_, err = w.Write([]byte(fmt.Sprintf("%d %d %d %d\n",
 			e.n0 - 1, e.n1 - 1,  // DDSG nodes are 0-origin
-			int(e.weight))))
+			int(e.weight), direction)))
 		if err != nil {
 			return err
 		}
@@ -15,8 +15,10 @@ func edgeNode(g Graph, n NodeId, s map[NodeId]bool, e *Edgelist) {
 	}
 	s[n] = true
 	for _, nn := range g.Neighbors(n) {
-		if n >= nn {
-			*e = append(*e, Edge{n, nn, g.Weight(n, nn)})
+		if !hasNeighbor(g, nn, n) {
+			*e = append(*e, Edge{n, nn, g.Weight(n, nn), true})
+		} else if n >= nn {
+			*e = append(*e, Edge{n, nn, g.Weight(n, nn), false})
 		}
 		edgeNode(g, nn, s, e)
 	}
@@ -36,7 +38,11 @@ func EdgelistToGraph(edges Edgelist) Graph {
 	m := make(map[NodeId]NodeId)
 	for _, e := range edges {
 		n1, n2 := nodeNo(e.n0, g, m), nodeNo(e.n1, g, m)
-		g.addEdge(n1, n2, e.weight)
+		if e.oneway {
+			g.addArc(n1, n2, e.weight)
+		} else {
+			g.addEdge(n1, n2, e.weight)
+		}
 	}
 	return g
 }
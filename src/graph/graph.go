@@ -3,6 +3,7 @@ package graph
 type graphNode struct {
 	neighbors []NodeId
 	weights []float64
+	preds []NodeId
 }
 
 type graph struct {
@@ -12,6 +13,7 @@ type graph struct {
 type Edge struct {
 	n0, n1 NodeId
 	weight float64
+	oneway bool  // Only from n0 to n1
 }
 
 type Edgelist []Edge
@@ -28,6 +30,10 @@ func (g *graph) Neighbors(id NodeId) []NodeId {
 	return g.nodes[id-1].neighbors
 }
 
+func (g *graph) Predecessors(id NodeId) []NodeId {
+	return g.nodes[id-1].preds
+}
+
 func (g *graph) Weight(from, to NodeId) float64 {
 	for i, n := range g.nodes[from-1].neighbors {
 		if n == to {
@@ -43,12 +49,14 @@ func (g *graph) addNode() NodeId {
 	return n
 }
 
+func (g *graph) addArc(from, to NodeId, weight float64) {
+	g.nodes[from-1].neighbors = append(g.nodes[from-1].neighbors, to)
+	g.nodes[from-1].weights = append(g.nodes[from-1].weights, weight)
+	g.nodes[to-1].preds = append(g.nodes[to-1].preds, from)
+}
+
 func (g *graph) addEdge(from, to NodeId, weight float64) {
-	addFrom := func (from, to NodeId, weight float64) {
-		g.nodes[from-1].neighbors = append(g.nodes[from-1].neighbors, to)
-		g.nodes[from-1].weights = append(g.nodes[from-1].weights, weight)
-	}
-	addFrom(from, to, weight)
-	addFrom(to, from, weight)
+	g.addArc(from, to, weight)
+	g.addArc(to, from, weight)
 }
 
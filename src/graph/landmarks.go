@@ -0,0 +1,243 @@
+package graph
+
+import "bufio"
+import "container/heap"
+import "encoding/binary"
+import "errors"
+import "io"
+import "math"
+import "runtime"
+
+// landmarkSlack covers the rounding of weights stored as float32.
+const landmarkSlack = 1e-6
+
+// landmarksMagic begins a landmarks file, followed by the tag, the
+// node and landmark counts, whether the graph is directed, the
+// landmarks, and the weights from and, if directed, to each.
+const landmarksMagic = "convoy-alt-2\n"
+
+// Landmarks is a Heuristic that bounds weights by the triangle
+// inequality on shortest paths from and to a few landmark nodes, for
+// ALT search.  Landmarks far apart at the edges of the graph give the
+// tightest bounds.
+type Landmarks struct {
+	nodes    []NodeId
+	from, to [][]float32 // [i][n] is the weight from landmark i to n, and from n
+
+	// Tag identifies the graph and weights, and is saved with the
+	// landmarks.  Landmarks of other weights give wrong bounds.
+	Tag string
+}
+
+// distances returns the shortest path weights from source, or to it
+// when reverse is set, with unreachable nodes infinite.
+func distances(g Graph, source NodeId, reverse bool) []float64 {
+	dist := make([]float64, g.Count()+1)
+	for i := range dist {
+		dist[i] = math.Inf(1)
+	}
+	dist[source] = 0
+	dg, directed := g.(DirectedGraph)
+	queue := &chHeap{{source, 0}}
+	for queue.Len() != 0 {
+		it := heap.Pop(queue).(chItem)
+		u := it.id
+		if it.key > dist[u] {
+			continue
+		}
+		ns := g.Neighbors(u)
+		if reverse && directed {
+			ns = dg.Predecessors(u)
+		}
+		for _, n := range ns {
+			var w float64
+			if reverse && directed {
+				w = g.Weight(n, u)
+			} else {
+				w = g.Weight(u, n)
+			}
+			if d := dist[u] + w; d < dist[n] {
+				dist[n] = d
+				heap.Push(queue, chItem{n, d})
+			}
+		}
+	}
+	return dist
+}
+
+func toFloat32(dist []float64) []float32 {
+	f := make([]float32, len(dist))
+	for i, d := range dist {
+		f[i] = float32(d)
+	}
+	return f
+}
+
+// NewLandmarks chooses count landmarks in g, each the node farthest
+// from those before it, and computes their shortest path weights.
+func NewLandmarks(g Graph, count int) *Landmarks {
+	l := &Landmarks{}
+	if g.Count() == 0 {
+		return l
+	}
+	_, directed := g.(DirectedGraph)
+	nearest := make([]float64, g.Count()+1)
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+	next := FirstNodeId
+	for i := 0; i < count; i++ {
+		if i == 0 {
+			// Start from the node farthest from an arbitrary one.
+			dist := distances(g, next, false)
+			for n := FirstNodeId; n <= NodeId(g.Count()); n++ {
+				if !math.IsInf(dist[n], 1) && dist[n] > dist[next] {
+					next = n
+				}
+			}
+		}
+		from := distances(g, next, false)
+		l.nodes = append(l.nodes, next)
+		l.from = append(l.from, toFloat32(from))
+		far := ZeroNodeId
+		for n := FirstNodeId; n <= NodeId(g.Count()); n++ {
+			if from[n] < nearest[n] {
+				nearest[n] = from[n]
+			}
+			if !math.IsInf(nearest[n], 1) && nearest[n] > 0 &&
+				(far == ZeroNodeId || nearest[n] > nearest[far]) {
+				far = n
+			}
+		}
+		if far == ZeroNodeId {
+			break
+		}
+		next = far
+	}
+	if directed {
+		l.to = make([][]float32, len(l.nodes))
+		done := make(chan bool)
+		workers := runtime.NumCPU()
+		for w := 0; w < workers; w++ {
+			go func(w int) {
+				for i := w; i < len(l.nodes); i += workers {
+					l.to[i] = toFloat32(distances(g, l.nodes[i], true))
+				}
+				done <- true
+			}(w)
+		}
+		for w := 0; w < workers; w++ {
+			<-done
+		}
+	}
+	return l
+}
+
+// Nodes returns the landmarks.
+func (l *Landmarks) Nodes() []NodeId {
+	return l.nodes
+}
+
+// Count returns the number of nodes of the graph.
+func (l *Landmarks) Count() int {
+	if len(l.from) == 0 {
+		return 0
+	}
+	return len(l.from[0]) - 1
+}
+
+// Bound is the largest lower bound given by any landmark.
+func (l *Landmarks) Bound(from, to NodeId) float64 {
+	bound := 0.0
+	for i := range l.nodes {
+		// From the landmark: d(L,to) <= d(L,from) + d(from,to).
+		lf, lt := float64(l.from[i][from]), float64(l.from[i][to])
+		if !math.IsInf(lf, 1) && !math.IsInf(lt, 1) {
+			bound = math.Max(bound, lt-lf-landmarkSlack*(lt+lf))
+		}
+		// To the landmark: d(from,L) <= d(from,to) + d(to,L).
+		toL := l.from[i]
+		if l.to != nil {
+			toL = l.to[i]
+		}
+		fl, tl := float64(toL[from]), float64(toL[to])
+		if !math.IsInf(fl, 1) && !math.IsInf(tl, 1) {
+			bound = math.Max(bound, fl-tl-landmarkSlack*(fl+tl))
+		}
+	}
+	return bound
+}
+
+// Write saves the landmarks in a binary format read by ReadLandmarks.
+func (l *Landmarks) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(landmarksMagic); err != nil {
+		return err
+	}
+	if err := writeTag(bw, l.Tag); err != nil {
+		return err
+	}
+	directed := uint32(0)
+	if l.to != nil {
+		directed = 1
+	}
+	header := []uint32{uint32(l.Count()), uint32(len(l.nodes)), directed}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, l.nodes); err != nil {
+		return err
+	}
+	for _, dists := range [][][]float32{l.from, l.to} {
+		for _, dist := range dists {
+			if err := binary.Write(bw, binary.LittleEndian, dist); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadLandmarks loads landmarks saved by Write.
+func ReadLandmarks(r io.Reader) (*Landmarks, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(landmarksMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != landmarksMagic {
+		return nil, errors.New("Not a landmarks file")
+	}
+	tag, err := readTag(br)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]uint32, 3)
+	if err := binary.Read(br, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+	count, size := header[0], header[1]
+	l := &Landmarks{nodes: make([]NodeId, size), Tag: tag}
+	if err := binary.Read(br, binary.LittleEndian, l.nodes); err != nil {
+		return nil, err
+	}
+	for _, n := range l.nodes {
+		if n == ZeroNodeId || uint32(n) > count {
+			return nil, errors.New("Corrupt landmarks file")
+		}
+	}
+	dists := []*[][]float32{&l.from}
+	if header[2] != 0 {
+		dists = append(dists, &l.to)
+	}
+	for _, d := range dists {
+		*d = make([][]float32, size)
+		for i := range *d {
+			(*d)[i] = make([]float32, count+1)
+			if err := binary.Read(br, binary.LittleEndian, (*d)[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return l, nil
+}
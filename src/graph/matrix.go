@@ -0,0 +1,99 @@
+// Many-to-many shortest distances using buckets, after Knopp et al.
+// (2007).  A backward upward search from every target leaves its
+// distance in a bucket at each node it reaches; a forward upward search
+// from every source then scans the buckets of the nodes it reaches.
+
+package graph
+
+import "math"
+import "runtime"
+
+// Matrix holds the shortest distances from each source (row) to each
+// target (column), with the length (see LengthGraph) and the number of
+// graph edges of each path.  Unreachable pairs have an infinite
+// distance and -1 edges.
+type Matrix struct {
+	Sources  []NodeId
+	Targets  []NodeId
+	Distance [][]float64
+	Length   [][]float64
+	Edges    [][]int
+}
+
+type bucketEntry struct {
+	target int
+	dist   float64
+	hops   int
+	length float64
+}
+
+// Get returns the distance, length and edge count from Sources[i] to
+// Targets[j], false when there is no path.
+func (m *Matrix) Get(i, j int) (float64, float64, int, bool) {
+	return m.Distance[i][j], m.Length[i][j], m.Edges[i][j], m.Edges[i][j] >= 0
+}
+
+// DistanceMatrix contracts g and computes the distances from every
+// source to every target.  Callers making repeated queries should
+// contract once and use (*CH).DistanceMatrix.
+func DistanceMatrix(g Graph, sources, targets []NodeId) *Matrix {
+	return Contract(g).DistanceMatrix(sources, targets)
+}
+
+// DistanceMatrix computes the distances from every source to every
+// target.  Rows are computed in parallel.
+func (ch *CH) DistanceMatrix(sources, targets []NodeId) *Matrix {
+	buckets := make(map[NodeId][]bucketEntry)
+	for j, t := range targets {
+		s := newSearch(ch.down, t)
+		for n, ok := s.step(math.Inf(1)); ok; n, ok = s.step(math.Inf(1)) {
+			l := s.labels[n]
+			buckets[n] = append(buckets[n], bucketEntry{j, l.dist, l.hops, l.length})
+		}
+	}
+	m := &Matrix{sources, targets, make([][]float64, len(sources)),
+		make([][]float64, len(sources)), make([][]int, len(sources))}
+	cpus := runtime.NumCPU()
+	rows := make(chan int, cpus)
+	done := make(chan bool, cpus)
+	for i := 0; i < cpus; i++ {
+		go func() {
+			for i := range rows {
+				m.Distance[i], m.Length[i], m.Edges[i] = ch.matrixRow(sources[i],
+					len(targets), buckets)
+			}
+			done <- true
+		}()
+	}
+	for i := range sources {
+		rows <- i
+	}
+	close(rows)
+	for i := 0; i < cpus; i++ {
+		<-done
+	}
+	return m
+}
+
+func (ch *CH) matrixRow(source NodeId, count int,
+	buckets map[NodeId][]bucketEntry) ([]float64, []float64, []int) {
+	dist := make([]float64, count)
+	length := make([]float64, count)
+	hops := make([]int, count)
+	for j := range dist {
+		dist[j] = math.Inf(1)
+		hops[j] = -1
+	}
+	s := newSearch(ch.up, source)
+	for n, ok := s.step(math.Inf(1)); ok; n, ok = s.step(math.Inf(1)) {
+		l := s.labels[n]
+		for _, b := range buckets[n] {
+			if d := l.dist + b.dist; d < dist[b.target] {
+				dist[b.target] = d
+				length[b.target] = l.length + b.length
+				hops[b.target] = l.hops + b.hops
+			}
+		}
+	}
+	return dist, length, hops
+}
@@ -0,0 +1,68 @@
+package graph
+
+import "math"
+import "math/rand"
+import "testing"
+
+func TestDistanceMatrix(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	g := randomGraph(80, 160, true, rnd)
+	var sources, targets []NodeId
+	for i := 0; i < 12; i++ {
+		sources = append(sources, NodeId(rnd.Intn(g.Count()))+FirstNodeId)
+		targets = append(targets, NodeId(rnd.Intn(g.Count()))+FirstNodeId)
+	}
+	ch := Contract(g)
+	m := ch.DistanceMatrix(sources, targets)
+	for i, s := range sources {
+		expect := dijkstraFrom(g, s)
+		for j, tg := range targets {
+			d, length, edges, ok := m.Get(i, j)
+			if math.IsInf(expect[tg], 1) {
+				if ok {
+					t.Errorf("Found a path %v->%v", s, tg)
+				}
+				continue
+			}
+			if !ok || d != expect[tg] || length != d {
+				t.Errorf("Distance %v->%v got %v %v want %v",
+					s, tg, d, ok, expect[tg])
+			}
+			// Equal-weight paths may differ in length.
+			path := ch.ShortestPath(s, tg)
+			if len(path)-1 != edges && !hasTies(g, s, tg) {
+				t.Errorf("Edges %v->%v got %v want %v", s, tg, edges, len(path)-1)
+			}
+		}
+	}
+	if m := DistanceMatrix(g, nil, targets); len(m.Distance) != 0 {
+		t.Errorf("Rows without sources: %v", m.Distance)
+	}
+}
+
+// hasTies reports whether more than one shortest path leads to end,
+// by counting shortest paths in a reference Dijkstra.
+func hasTies(g Graph, start, end NodeId) bool {
+	dist := dijkstraFrom(g, start)
+	count := make(map[NodeId]int)
+	var paths func(n NodeId) int
+	paths = func(n NodeId) int {
+		if n == start {
+			return 1
+		}
+		if c, has := count[n]; has {
+			return c
+		}
+		c := 0
+		for u := FirstNodeId; u <= NodeId(g.Count()); u++ {
+			for _, v := range g.Neighbors(u) {
+				if v == n && dist[u]+g.Weight(u, v) == dist[n] {
+					c += paths(u)
+				}
+			}
+		}
+		count[n] = c
+		return c
+	}
+	return paths(end) > 1
+}
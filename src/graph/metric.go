@@ -0,0 +1,83 @@
+package graph
+
+import "errors"
+
+// Metric selects the edge weight of a MultiGraph.
+type Metric int
+
+const (
+	Meters Metric = iota
+	Seconds
+)
+
+var metricNames = []string{"meters", "seconds"}
+
+// MultiGraph is a Graph with more than one weight per edge.  Weight
+// returns plain Meters, while MetricWeight may add costs such as
+// penalties for restricted roads.
+type MultiGraph interface {
+	Graph
+	MetricWeight(m Metric, from, to NodeId) float64
+}
+
+// LengthGraph is a Graph with a second measure of each edge, such as
+// meters when weighted by seconds, which Contract and DistanceMatrix
+// sum along the shortest paths by weight.
+type LengthGraph interface {
+	Graph
+	Length(from, to NodeId) float64
+}
+
+// length is the Length of an edge of a LengthGraph, else its weight.
+func length(g Graph, from, to NodeId) float64 {
+	if lg, ok := g.(LengthGraph); ok {
+		return lg.Length(from, to)
+	}
+	return g.Weight(from, to)
+}
+
+type metricGraph struct {
+	MultiGraph
+	m Metric
+}
+
+type directedMetricGraph struct {
+	metricGraph
+	d DirectedGraph
+}
+
+// ByMetric returns g weighted by m, for use with ShortestPath,
+// Contract and the other algorithms on Graph.  The result is a
+// DirectedGraph when g is.
+func ByMetric(g MultiGraph, m Metric) Graph {
+	if d, ok := g.(DirectedGraph); ok {
+		return directedMetricGraph{metricGraph{g, m}, d}
+	}
+	return metricGraph{g, m}
+}
+
+func (g directedMetricGraph) Predecessors(id NodeId) []NodeId {
+	return g.d.Predecessors(id)
+}
+
+func (g metricGraph) Weight(from, to NodeId) float64 {
+	return g.MetricWeight(g.m, from, to)
+}
+
+// Length is the plain Meters of an edge.
+func (g metricGraph) Length(from, to NodeId) float64 {
+	return g.MultiGraph.Weight(from, to)
+}
+
+func (m Metric) String() string {
+	return metricNames[m]
+}
+
+func ParseMetric(name string) (Metric, error) {
+	for i, n := range metricNames {
+		if n == name {
+			return Metric(i), nil
+		}
+	}
+	return Meters, errors.New("Unknown metric: " + name)
+}
@@ -0,0 +1,60 @@
+package graph
+
+import "testing"
+
+// timedGraph weights each edge in seconds by its speed in meters per
+// second.
+type timedGraph struct {
+	*graph
+	speed map[[2]NodeId]float64
+}
+
+func (g timedGraph) MetricWeight(m Metric, from, to NodeId) float64 {
+	if m == Meters {
+		return g.Weight(from, to)
+	}
+	return g.Weight(from, to) / g.speed[[2]NodeId{from, to}]
+}
+
+func TestByMetric(t *testing.T) {
+	g := timedGraph{newGraph(), make(map[[2]NodeId]float64)}
+	n0, n1, n2, n3 := g.addNode(), g.addNode(), g.addNode(), g.addNode()
+	edge := func(from, to NodeId, meters, speed float64) {
+		g.addEdge(from, to, meters)
+		g.speed[[2]NodeId{from, to}] = speed
+		g.speed[[2]NodeId{to, from}] = speed
+	}
+	// A short slow road and a long fast one.
+	edge(n0, n1, 1000, 10)
+	edge(n1, n3, 1000, 10)
+	edge(n0, n2, 1500, 30)
+	edge(n2, n3, 1500, 30)
+	for _, test := range []struct {
+		m      Metric
+		via    NodeId
+		weight float64
+		meters float64
+	}{
+		{Meters, n1, 2000, 2000},
+		{Seconds, n2, 100, 3000},
+	} {
+		ch := Contract(ByMetric(g, test.m))
+		if d, _ := ch.Distance(n0, n3); d != test.weight {
+			t.Errorf("%v distance got %v want %v", test.m, d, test.weight)
+		}
+		m := ch.DistanceMatrix([]NodeId{n0}, []NodeId{n3})
+		if d, meters, _, _ := m.Get(0, 0); d != test.weight || meters != test.meters {
+			t.Errorf("%v matrix got %v %v want %v %v",
+				test.m, d, meters, test.weight, test.meters)
+		}
+		if p := ch.ShortestPath(n0, n3); len(p) != 3 || p[1] != test.via {
+			t.Errorf("%v path got %v via %v", test.m, p, test.via)
+		}
+	}
+	if m, err := ParseMetric("seconds"); err != nil || m != Seconds {
+		t.Errorf("ParseMetric got %v %v", m, err)
+	}
+	if _, err := ParseMetric("hours"); err == nil {
+		t.Error("Parsed an unknown metric")
+	}
+}
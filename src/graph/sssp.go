@@ -1,9 +1,10 @@
 package graph
 
 import "container/heap"
+import "errors"
+import "fmt"
+import "math"
 
-// TODO(jmacd): Investigate cause of <100% road distance.  ~1000 city
-// pairs with distance <10km.
 type NodeId uint32
 type heapPos int32
 
@@ -21,6 +22,14 @@ type Graph interface {
 	Weight(from, to NodeId) float64
 }
 
+// DirectedGraph is a Graph whose edges may go one way, listing the
+// nodes with an edge into each node.  ShortestPath treats any other
+// Graph as undirected.
+type DirectedGraph interface {
+	Graph
+	Predecessors(id NodeId) []NodeId
+}
+
 type qpos struct {
 	// Id of this node in the graph.
         id        NodeId
@@ -45,44 +54,71 @@ type dijkstra struct {
         q  [2]queue  // [0] = forward, [1] = reverse
 }
 
+// Path is the result of a shortest path search.
+type Path struct {
+	Nodes   []NodeId  // Empty when there is no path
+	Cost    float64   // Total weight
+	Costs   []float64 // Costs[i] is the weight from Nodes[i] to Nodes[i+1]
+	Settled [2]int    // Nodes settled by the forward and reverse searches
+	Meeting NodeId    // Node at which the searches met
+}
+
+// ShortestPath returns the nodes of a shortest path from start to end,
+// or nil if there is none.
 func ShortestPath(g Graph, start, end NodeId) []NodeId {
+	return FindPath(g, start, end).Nodes
+}
+
+// FindPath searches from both start and end until no path through an
+// unsettled node can be shorter than the best found, the sum of the
+// nearest queued weights on each side reaching its cost.
+func FindPath(g Graph, start, end NodeId) *Path {
 	if start == end {
-		return []NodeId{start}
+		return &Path{Nodes: []NodeId{start}, Meeting: start}
 	}
 
         d := newDijkstra(g)
         d.q[0].visit(start, 0.0, ZeroNodeId)
         d.q[1].visit(end, 0.0, ZeroNodeId)
 
-	// The midpoint
+	// The best path found, through mid, weighs mu.
 	var mid NodeId
+	mu := math.Inf(1)
+	path := &Path{}
 
 	// Search, during which nodes are queued, settled, or unknown.
 	dir := 1
-	rounds := 0
-	for {
-		odir := dir
+	for !d.q[0].empty() && !d.q[1].empty() &&
+		d.q[0].heap[0].weight+d.q[1].heap[0].weight < mu {
 		dir = 1 - dir
 		q := &d.q[dir]
-		oq := &d.q[odir]
-
-		if q.empty() {
-			return nil
-		}
+		oq := &d.q[1-dir]
 
 		p := q.next()
-		op := &oq.data[p.id]
-
-		if op.index == settled {
-			mid = p.id
-			break
-		}
 		p.index = settled
-		neighbors := d.g.Neighbors(p.id)
-		for _, n := range neighbors {
-			q.visit(n, p.weight + g.Weight(p.id, n), p.id)
+		path.Settled[dir]++
+		ns := d.g.Neighbors(p.id)
+		if dir == 1 {
+			ns = d.predecessors(p.id)
 		}
-		rounds++
+		for _, n := range ns {
+			var w float64
+			if dir == 0 {
+				w = g.Weight(p.id, n)
+			} else {
+				w = g.Weight(n, p.id)
+			}
+			q.visit(n, p.weight+w, p.id)
+			if op := &oq.data[n]; op.index != unknown {
+				if m := q.data[n].weight + op.weight; m < mu {
+					mu = m
+					mid = n
+				}
+			}
+		}
+	}
+	if mid == ZeroNodeId {
+		return path
 	}
 	countParents := func (child, root NodeId, q *queue) int {
 		count := 0
@@ -94,21 +130,51 @@ func ShortestPath(g Graph, start, end NodeId) []NodeId {
 	}
 	fcount := countParents(mid, start, &d.q[0])
 	rcount := countParents(mid, end, &d.q[1])
-	path := make([]NodeId, fcount + rcount + 1)
+	nodes := make([]NodeId, fcount + rcount + 1)
 
 	fillPath := func (child, root NodeId, idx, incr int, q *queue) {
 		for child != root {
 			child = q.data[child].parent
-			path[idx] = child
+			nodes[idx] = child
 			idx += incr
 		}
 	}
 	fillPath(mid, start, fcount-1, -1, &d.q[0])
-	path[fcount] = mid
+	nodes[fcount] = mid
 	fillPath(mid, end, fcount+1, +1, &d.q[1])
+	path.Nodes = nodes
+	path.Meeting = mid
+	path.Costs = make([]float64, len(nodes)-1)
+	for i := range path.Costs {
+		path.Costs[i] = g.Weight(nodes[i], nodes[i+1])
+		path.Cost += path.Costs[i]
+	}
 	return path
 }
 
+// Check compares the path from start to end in g with one found by
+// Dijkstra's algorithm, returning an error if their costs differ.
+func (path *Path) Check(g Graph, start, end NodeId) error {
+	nodes := NewRouter(g).ShortestPath(start, end, nil)
+	var cost float64
+	for i := 0; i+1 < len(nodes); i++ {
+		cost += g.Weight(nodes[i], nodes[i+1])
+	}
+	if (nodes == nil) != (path.Nodes == nil) ||
+		math.Abs(cost-path.Cost) > 1e-9*cost {
+		return errors.New(fmt.Sprint("Path ", start, " -> ", end, " costs ",
+			path.Cost, " but Dijkstra found ", cost, " ", nodes))
+	}
+	return nil
+}
+
+func (d *dijkstra) predecessors(id NodeId) []NodeId {
+	if dg, ok := d.g.(DirectedGraph); ok {
+		return dg.Predecessors(id)
+	}
+	return d.g.Neighbors(id)
+}
+
 func newDijkstra(g Graph) *dijkstra {
 	nodes := int(g.Count()+1)
         d := &dijkstra{g: g}
@@ -1,5 +1,7 @@
 package graph
 
+import "math"
+import "math/rand"
 import "testing"
 
 func (g *graph) check(t *testing.T, n0, n1 NodeId, expect []NodeId) {
@@ -45,4 +47,77 @@ func TestSssp(t *testing.T) {
 	g.addEdge(n3, n4, 5.0)
 	g.addEdge(n2, n4, 1.0)
 	g.check(t, n0, n4, []NodeId{n0, n3, n2, n4})
-}
\ No newline at end of file
+}
+
+func TestFindPath(t *testing.T) {
+	rnd := rand.New(rand.NewSource(8))
+	for i := 0; i < 6; i++ {
+		g := randomGraph(60, 150, i%2 == 1, rnd)
+		for from := FirstNodeId; from <= NodeId(g.Count()); from += 7 {
+			expect := dijkstraFrom(g, from)
+			for to := FirstNodeId; to <= NodeId(g.Count()); to++ {
+				path := FindPath(g, from, to)
+				if err := path.Check(g, from, to); err != nil {
+					t.Error(err)
+				}
+				if math.IsInf(expect[to], 1) {
+					if path.Nodes != nil {
+						t.Errorf("Found a path %v->%v: %v", from, to, path.Nodes)
+					}
+					continue
+				}
+				if path.Cost != expect[to] {
+					t.Errorf("Path %v->%v costs %v want %v",
+						from, to, path.Cost, expect[to])
+				}
+				if from == to {
+					continue
+				}
+				var sum float64
+				for j, c := range path.Costs {
+					if c != g.Weight(path.Nodes[j], path.Nodes[j+1]) {
+						t.Errorf("Incorrect cost %v of %v", j, path)
+					}
+					sum += c
+				}
+				if sum != path.Cost || len(path.Costs) != len(path.Nodes)-1 ||
+					path.Settled[0]+path.Settled[1] == 0 {
+					t.Errorf("Incorrect path %v->%v: %v", from, to, path)
+				}
+				meets := false
+				for _, n := range path.Nodes {
+					meets = meets || n == path.Meeting
+				}
+				if !meets {
+					t.Errorf("Path %v does not meet at %v", path.Nodes, path.Meeting)
+				}
+			}
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	rnd := rand.New(rand.NewSource(8))
+	for _, directed := range []bool{false, true} {
+		g := randomGraph(100, 300, directed, rnd)
+		for i := 0; i < 5; i++ {
+			from := NodeId(rnd.Intn(g.Count())) + FirstNodeId
+			expect := dijkstraFrom(g, from)
+			limit := float64(rnd.Intn(300))
+			seen := make(map[NodeId]bool)
+			last := 0.0
+			for _, r := range Within(g, from, limit) {
+				if r.Cost != expect[r.Node] || r.Cost > limit || r.Cost < last || seen[r.Node] {
+					t.Errorf("Incorrect reach %v from %v within %v", r, from, limit)
+				}
+				seen[r.Node] = true
+				last = r.Cost
+			}
+			for n := FirstNodeId; n <= NodeId(g.Count()); n++ {
+				if expect[n] <= limit && !seen[n] {
+					t.Errorf("Did not reach %v from %v within %v", n, from, limit)
+				}
+			}
+		}
+	}
+}
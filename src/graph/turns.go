@@ -0,0 +1,202 @@
+package graph
+
+// Turn is a path through three adjacent nodes, entering Via from From
+// and leaving it toward To.
+type Turn struct {
+	From, Via, To NodeId
+}
+
+// Restriction forbids a turn, or with Only forbids every other turn
+// from the same edge.
+type Restriction struct {
+	Turn
+	Only bool
+}
+
+// TurnGraph is the edge-based form of a directed graph, in which each
+// node is an edge of the base graph and a restricted turn is a missing
+// edge.  Every base node n also has a Source(n), with edges to the
+// base edges leaving n, and a Sink(n), reached from the base edges
+// entering n.  A TurnGraph is a DirectedGraph.
+type TurnGraph struct {
+	g        Graph
+	count    NodeId   // Base nodes
+	firstOut []int    // Base edges leaving n are firstOut[n]..firstOut[n+1]
+	arcFrom  []NodeId // Base edge index to its start
+	arcTo    []NodeId
+	firstIn  []int // Base edges entering n are in[firstIn[n]..firstIn[n+1]]
+	in       []int
+	banned   map[Turn]bool
+	only     map[[2]NodeId]NodeId
+}
+
+// NewTurnGraph builds the turn-aware form of g.  Edges of g may be
+// directed: only Neighbors(n) of each node n are followed.
+func NewTurnGraph(g Graph, restrictions []Restriction) *TurnGraph {
+	n := NodeId(g.Count())
+	tg := &TurnGraph{g: g, count: n,
+		firstOut: make([]int, n+2),
+		firstIn:  make([]int, n+2),
+		banned:   make(map[Turn]bool),
+		only:     make(map[[2]NodeId]NodeId),
+	}
+	for u := FirstNodeId; u <= n; u++ {
+		tg.firstOut[u] = len(tg.arcTo)
+		for _, v := range g.Neighbors(u) {
+			tg.arcFrom = append(tg.arcFrom, u)
+			tg.arcTo = append(tg.arcTo, v)
+			tg.firstIn[v+1]++
+		}
+	}
+	tg.firstOut[n+1] = len(tg.arcTo)
+	for v := FirstNodeId; v <= n+1; v++ {
+		tg.firstIn[v] += tg.firstIn[v-1]
+	}
+	tg.in = make([]int, len(tg.arcTo))
+	fill := make([]int, n+1)
+	copy(fill, tg.firstIn)
+	for a, v := range tg.arcTo {
+		tg.in[fill[v]] = a
+		fill[v]++
+	}
+	for _, r := range restrictions {
+		if r.Only {
+			tg.only[[2]NodeId{r.From, r.Via}] = r.To
+		} else {
+			tg.banned[r.Turn] = true
+		}
+	}
+	return tg
+}
+
+// Source is the node from which paths leave base node n.
+func (tg *TurnGraph) Source(n NodeId) NodeId {
+	return n
+}
+
+// Sink is the node at which paths arrive at base node n.
+func (tg *TurnGraph) Sink(n NodeId) NodeId {
+	return tg.count + n
+}
+
+func (tg *TurnGraph) arcNode(a int) NodeId {
+	return 2*tg.count + FirstNodeId + NodeId(a)
+}
+
+// arc returns the base edge index of a node, or -1 for a source or
+// sink.
+func (tg *TurnGraph) arc(id NodeId) int {
+	if id <= 2*tg.count {
+		return -1
+	}
+	return int(id - 2*tg.count - FirstNodeId)
+}
+
+// Base returns the base node at which a node arrives: the node of a
+// source or sink, or the end of a base edge.
+func (tg *TurnGraph) Base(id NodeId) NodeId {
+	if a := tg.arc(id); a >= 0 {
+		return tg.arcTo[a]
+	}
+	if id > tg.count {
+		return id - tg.count
+	}
+	return id
+}
+
+// Allowed reports whether a path may turn from, via, to.
+func (tg *TurnGraph) Allowed(t Turn) bool {
+	if to, has := tg.only[[2]NodeId{t.From, t.Via}]; has {
+		return t.To == to
+	}
+	return !tg.banned[t]
+}
+
+func (tg *TurnGraph) Count() int {
+	return int(2*tg.count) + len(tg.arcTo)
+}
+
+func (tg *TurnGraph) Neighbors(id NodeId) []NodeId {
+	a := tg.arc(id)
+	if id > tg.count && a < 0 {
+		return nil
+	}
+	var ns []NodeId
+	if a < 0 {
+		for b := tg.firstOut[id]; b < tg.firstOut[id+1]; b++ {
+			ns = append(ns, tg.arcNode(b))
+		}
+		return ns
+	}
+	u, v := tg.arcFrom[a], tg.arcTo[a]
+	for b := tg.firstOut[v]; b < tg.firstOut[v+1]; b++ {
+		if tg.Allowed(Turn{u, v, tg.arcTo[b]}) {
+			ns = append(ns, tg.arcNode(b))
+		}
+	}
+	return append(ns, tg.Sink(v))
+}
+
+func (tg *TurnGraph) Predecessors(id NodeId) []NodeId {
+	a := tg.arc(id)
+	if id <= tg.count {
+		return nil
+	}
+	var ps []NodeId
+	if a < 0 {
+		v := id - tg.count
+		for _, b := range tg.in[tg.firstIn[v]:tg.firstIn[v+1]] {
+			ps = append(ps, tg.arcNode(b))
+		}
+		return ps
+	}
+	u, v := tg.arcFrom[a], tg.arcTo[a]
+	ps = append(ps, tg.Source(u))
+	for _, b := range tg.in[tg.firstIn[u]:tg.firstIn[u+1]] {
+		if tg.Allowed(Turn{tg.arcFrom[b], u, v}) {
+			ps = append(ps, tg.arcNode(b))
+		}
+	}
+	return ps
+}
+
+// Weight is that of the base edge entered, zero on arriving at a sink.
+func (tg *TurnGraph) Weight(from, to NodeId) float64 {
+	a := tg.arc(to)
+	if a < 0 {
+		return 0
+	}
+	return tg.g.Weight(tg.arcFrom[a], tg.arcTo[a])
+}
+
+// Length is that of the base edge entered, zero on arriving at a sink.
+func (tg *TurnGraph) Length(from, to NodeId) float64 {
+	a := tg.arc(to)
+	if a < 0 {
+		return 0
+	}
+	return length(tg.g, tg.arcFrom[a], tg.arcTo[a])
+}
+
+// BasePath converts a path from a source to a sink into base nodes.
+func (tg *TurnGraph) BasePath(path []NodeId) []NodeId {
+	if len(path) == 0 {
+		return nil
+	}
+	base := []NodeId{path[0]}
+	for _, id := range path {
+		if a := tg.arc(id); a >= 0 {
+			base = append(base, tg.arcTo[a])
+		}
+	}
+	return base
+}
+
+// ShortestPath returns the base nodes of a shortest path that obeys
+// the turn restrictions, or nil if there is none.
+func (tg *TurnGraph) ShortestPath(start, end NodeId) []NodeId {
+	if start == end {
+		return []NodeId{start}
+	}
+	return tg.BasePath(ShortestPath(tg, tg.Source(start), tg.Sink(end)))
+}
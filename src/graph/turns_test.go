@@ -0,0 +1,144 @@
+package graph
+
+import "math"
+import "math/rand"
+import "testing"
+
+func checkPath(t *testing.T, s, expect []NodeId) {
+	if len(s) != len(expect) {
+		t.Errorf("Incorrect path got %v want %v", s, expect)
+		return
+	}
+	for i := range s {
+		if s[i] != expect[i] {
+			t.Errorf("Incorrect path got %v want %v", s, expect)
+			return
+		}
+	}
+}
+
+func TestDirectedPath(t *testing.T) {
+	g := newGraph()
+	n0, n1, n2 := g.addNode(), g.addNode(), g.addNode()
+	g.addArc(n0, n1, 1)
+	g.addArc(n1, n2, 1)
+	g.addArc(n2, n0, 1)
+	g.check(t, n0, n2, []NodeId{n0, n1, n2})
+	g.check(t, n2, n1, []NodeId{n2, n0, n1})
+	g.check(t, n1, n0, []NodeId{n1, n2, n0})
+}
+
+func TestCondenseOneway(t *testing.T) {
+	g := newGraph()
+	n0, n1, n2, n3, n4 := g.addNode(), g.addNode(), g.addNode(), g.addNode(), g.addNode()
+	// A one-way ramp from n0 to n2, and a two-way road from n2 to n4.
+	g.addArc(n0, n1, 100)
+	g.addArc(n1, n2, 100)
+	g.addEdge(n2, n3, 100)
+	g.addEdge(n3, n4, 100)
+	edges := Condense(g, func(n NodeId) bool {
+		return n == n0 || n == n2 || n == n4
+	})
+	if len(edges) != 2 {
+		t.Fatalf("Expected 2 edges, got %v", edges)
+	}
+	for _, e := range edges {
+		if e.n0 == n0 && (e.n1 != n2 || !e.oneway || e.weight != 200) {
+			t.Errorf("Incorrect ramp: %v", e)
+		}
+		if e.n0 == n2 && (e.n1 != n4 || e.oneway || e.weight != 200) {
+			t.Errorf("Incorrect road: %v", e)
+		}
+	}
+	cg := EdgelistToGraph(edges)
+	if back := GraphToEdgelist(cg); len(back) != 2 {
+		t.Errorf("Incorrect round trip: %v", back)
+	}
+}
+
+func TestTurnGraph(t *testing.T) {
+	//  n0 - n1 - n2
+	//        |  /
+	//        n3
+	g := newGraph()
+	n0, n1, n2, n3 := g.addNode(), g.addNode(), g.addNode(), g.addNode()
+	g.addEdge(n0, n1, 1)
+	g.addEdge(n1, n2, 1)
+	g.addEdge(n1, n3, 1)
+	g.addEdge(n2, n3, 1)
+
+	tg := NewTurnGraph(g, nil)
+	checkPath(t, tg.ShortestPath(n0, n3), []NodeId{n0, n1, n3})
+
+	tg = NewTurnGraph(g, []Restriction{{Turn{n0, n1, n3}, false}})
+	checkPath(t, tg.ShortestPath(n0, n3), []NodeId{n0, n1, n2, n3})
+	checkPath(t, tg.ShortestPath(n3, n0), []NodeId{n3, n1, n0})
+
+	tg = NewTurnGraph(g, []Restriction{{Turn{n0, n1, n3}, true}})
+	checkPath(t, tg.ShortestPath(n0, n0), []NodeId{n0})
+	checkPath(t, tg.ShortestPath(n0, n2), []NodeId{n0, n1, n3, n2})
+
+	// Contraction keeps the restrictions.
+	ch := Contract(tg)
+	if d, ok := ch.Distance(tg.Source(n0), tg.Sink(n2)); !ok || d != 3 {
+		t.Errorf("Restricted distance got %v %v want 3", d, ok)
+	}
+	path := tg.BasePath(ch.ShortestPath(tg.Source(n0), tg.Sink(n2)))
+	checkPath(t, path, []NodeId{n0, n1, n3, n2})
+
+	// So does condensing to the ends.
+	edges := Condense(tg, func(n NodeId) bool {
+		return tg.Base(n) == n0 || tg.Base(n) == n2
+	})
+	cg := newGraph()
+	for i := 0; i < tg.Count(); i++ {
+		cg.addNode()
+	}
+	for _, e := range edges {
+		if e.oneway {
+			cg.addArc(e.n0, e.n1, e.weight)
+		} else {
+			cg.addEdge(e.n0, e.n1, e.weight)
+		}
+	}
+	if d := dijkstraFrom(cg, tg.Source(n0))[tg.Sink(n2)]; d != 3 {
+		t.Errorf("Condensed restricted distance got %v want 3", d)
+	}
+}
+
+func TestTurnGraphRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	g := randomGraph(40, 120, true, rnd)
+	var rs []Restriction
+	for u := FirstNodeId; u <= NodeId(g.Count()); u++ {
+		for _, v := range g.Neighbors(u) {
+			for _, w := range g.Neighbors(v) {
+				if rnd.Intn(3) == 0 {
+					rs = append(rs, Restriction{Turn{u, v, w}, rnd.Intn(4) == 0})
+				}
+			}
+		}
+	}
+	tg := NewTurnGraph(g, rs)
+	ch := Contract(tg)
+	for i := 0; i < 10; i++ {
+		from := NodeId(rnd.Intn(g.Count())) + FirstNodeId
+		expect := dijkstraFrom(tg, tg.Source(from))
+		for to := FirstNodeId; to <= NodeId(g.Count()); to++ {
+			if to == from {
+				continue
+			}
+			d, ok := ch.Distance(tg.Source(from), tg.Sink(to))
+			if want := expect[tg.Sink(to)]; ok == math.IsInf(want, 1) || (ok && d != want) {
+				t.Errorf("Distance %v->%v got %v %v want %v", from, to, d, ok, want)
+				continue
+			}
+			path := tg.BasePath(ch.ShortestPath(tg.Source(from), tg.Sink(to)))
+			for j := 0; j+2 < len(path); j++ {
+				if !tg.Allowed(Turn{path[j], path[j+1], path[j+2]}) {
+					t.Errorf("Path %v makes a restricted turn", path)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package graph
+
+import "container/heap"
+
+// Reach is a node reached by Within, and the weight of the shortest
+// path to it.
+type Reach struct {
+	Node NodeId
+	Cost float64
+}
+
+// Within returns the nodes reachable from start by paths weighing at
+// most limit, nearest first.  The search touches only those nodes and
+// their neighbors.
+func Within(g Graph, start NodeId, limit float64) []Reach {
+	dist := map[NodeId]float64{start: 0}
+	var reached []Reach
+	queue := &chHeap{{start, 0}}
+	for queue.Len() != 0 {
+		it := heap.Pop(queue).(chItem)
+		if it.key > dist[it.id] {
+			continue
+		}
+		reached = append(reached, Reach{it.id, it.key})
+		for _, n := range g.Neighbors(it.id) {
+			d := it.key + g.Weight(it.id, n)
+			if old, has := dist[n]; d > limit || (has && d >= old) {
+				continue
+			}
+			dist[n] = d
+			heap.Push(queue, chItem{n, d})
+		}
+	}
+	return reached
+}
@@ -0,0 +1,208 @@
+package lanes
+
+import "encoding/csv"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "math"
+import "sort"
+import "strconv"
+
+import "boards"
+import "common"
+import "geo"
+
+// RoadDistances looks up road distances in kilometers, estimating
+// those not known from the great circle distance times Circuity.
+type RoadDistances struct {
+	Known     DistanceFunc
+	Locations map[common.CityState]geo.SphereCoords
+	Circuity  float64
+}
+
+// Distance returns the distance between two places, whether it was
+// estimated, and false if it is neither known nor can be estimated.
+func (r *RoadDistances) Distance(src, dest common.CityState) (int, bool, bool, error) {
+	if src == dest {
+		return 0, false, true, nil
+	}
+	km, has, err := r.Known(src, dest)
+	if err != nil || has {
+		return km, false, has, err
+	}
+	sloc, shas := r.Locations[src]
+	dloc, dhas := r.Locations[dest]
+	if !shas || !dhas {
+		return 0, false, false, nil
+	}
+	var sc, dc [3]geo.EarthLoc
+	sloc.ToCoords(sc[:])
+	dloc.ToCoords(dc[:])
+	meters := geo.GreatCircleDistance(sc[:], dc[:]) * r.Circuity
+	return int(math.Floor(meters/1000 + 0.5)), true, true, nil
+}
+
+// Backhaul is a load leaving from near a delivery city.  Deadhead is
+// the empty leg from the delivery city to the load's origin.
+type Backhaul struct {
+	Load       boards.Load
+	Count      int
+	Deadhead   int
+	Loaded     int
+	Estimated  bool
+	Price      boards.Price
+	CentsPerKm float64 // Over deadhead and loaded kilometers
+}
+
+// Backhauls collects the priced loads whose origins are near a
+// delivery city.
+type Backhauls struct {
+	From      common.CityState
+	origins   map[common.CityState]bool
+	distances *RoadDistances
+	list      []*Backhaul
+}
+
+func NewBackhauls(from common.CityState, near []geo.Near,
+	distances *RoadDistances) *Backhauls {
+	origins := make(map[common.CityState]bool)
+	for _, n := range near {
+		origins[n.CityState] = true
+	}
+	origins[from] = true
+	return &Backhauls{from, origins, distances, nil}
+}
+
+// Add considers a load posted count times.  Loads from other places,
+// without a known rate, or without distances are ignored.
+func (b *Backhauls) Add(load boards.Load, count int) error {
+	if !b.origins[load.Origin] {
+		return nil
+	}
+	deadhead, dest, has, err := b.distances.Distance(b.From, load.Origin)
+	if err != nil || !has {
+		return err
+	}
+	loaded, lest, has, err := b.distances.Distance(load.Origin, load.Dest)
+	if err != nil || !has || loaded == 0 {
+		return err
+	}
+	price, ok := load.Rate.Normalize(float64(loaded))
+	if !ok {
+		return nil
+	}
+	b.list = append(b.list, &Backhaul{load, count, deadhead, loaded,
+		dest || lest, price,
+		float64(price.TotalCents) / float64(deadhead+loaded)})
+	return nil
+}
+
+// Ranked returns the backhauls by decreasing price per total
+// kilometer.
+func (b *Backhauls) Ranked() []*Backhaul {
+	sort.Sort(byCentsPerKm(b.list))
+	return b.list
+}
+
+type byCentsPerKm []*Backhaul
+
+func (b byCentsPerKm) Len() int      { return len(b) }
+func (b byCentsPerKm) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byCentsPerKm) Less(i, j int) bool {
+	if b[i].CentsPerKm != b[j].CentsPerKm {
+		return b[i].CentsPerKm > b[j].CentsPerKm
+	}
+	return b[i].Deadhead < b[j].Deadhead
+}
+
+func (h *Backhaul) estimate() string {
+	if h.Estimated {
+		return "est"
+	}
+	return ""
+}
+
+func WriteBackhaulsText(w io.Writer, from common.CityState, list []*Backhaul) error {
+	if _, err := fmt.Fprintf(w, "Backhauls from %v: %d\n",
+		from, len(list)); err != nil {
+		return err
+	}
+	for _, h := range list {
+		if _, err := fmt.Fprintf(w,
+			"$%.2f/km %s %v -> %v %s: %d km deadhead + %d km loaded %s, %s x%d [%s]\n",
+			h.CentsPerKm/100, common.FormatLoadDate(h.Load.PickupDate),
+			h.Load.Origin, h.Load.Dest, h.Load.Equipment,
+			h.Deadhead, h.Loaded, h.estimate(), h.Load.Rate,
+			h.Count, h.Load.Phone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteBackhaulsCSV(w io.Writer, list []*Backhaul) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"PerKm", "PickupDate", "OriginCity", "OriginState",
+		"DestCity", "DestState", "Equipment", "Deadhead", "Loaded",
+		"Estimated", "Total", "Rate", "Count", "Phone"})
+	for _, h := range list {
+		cw.Write([]string{strconv.FormatFloat(h.CentsPerKm/100, 'f', 2, 64),
+			common.FormatLoadDate(h.Load.PickupDate),
+			h.Load.Origin.City, h.Load.Origin.State,
+			h.Load.Dest.City, h.Load.Dest.State, h.Load.Equipment,
+			strconv.Itoa(h.Deadhead), strconv.Itoa(h.Loaded),
+			strconv.FormatBool(h.Estimated),
+			dollars(h.Price.TotalCents), h.Load.Rate.String(),
+			strconv.Itoa(h.Count), h.Load.Phone})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonBackhaul struct {
+	PerKm      float64
+	PickupDate string
+	Origin     string
+	Dest       string
+	Equipment  string
+	Deadhead   int
+	Loaded     int
+	Estimated  bool
+	Total      float64
+	Rate       string
+	Count      int
+	Phone      string
+}
+
+func WriteBackhaulsJSON(w io.Writer, list []*Backhaul) error {
+	out := []jsonBackhaul{}
+	for _, h := range list {
+		out = append(out, jsonBackhaul{h.CentsPerKm / 100,
+			common.FormatLoadDate(h.Load.PickupDate),
+			h.Load.Origin.String(), h.Load.Dest.String(),
+			h.Load.Equipment, h.Deadhead, h.Loaded, h.Estimated,
+			float64(h.Price.TotalCents) / 100, h.Load.Rate.String(),
+			h.Count, h.Load.Phone})
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteBackhauls writes the backhauls as "text", "csv" or "json".
+func WriteBackhauls(w io.Writer, format string, from common.CityState,
+	list []*Backhaul) error {
+	switch format {
+	case "text":
+		return WriteBackhaulsText(w, from, list)
+	case "csv":
+		return WriteBackhaulsCSV(w, list)
+	case "json":
+		return WriteBackhaulsJSON(w, list)
+	}
+	return errors.New("Unknown report format: " + format)
+}
@@ -0,0 +1,70 @@
+package lanes
+
+import "testing"
+
+import "boards"
+import "common"
+import "geo"
+
+var (
+	boise    = common.CityState{"Boise", "ID"}
+	nampa    = common.CityState{"Nampa", "ID"}
+	meridian = common.CityState{"Meridian", "ID"}
+	salem    = common.CityState{"Salem", "OR"}
+	reno     = common.CityState{"Reno", "NV"}
+)
+
+func backhaulDistance(src, dest common.CityState) (int, bool, error) {
+	switch {
+	case src == boise && dest == nampa:
+		return 30, true, nil
+	case dest == salem:
+		return 700, true, nil
+	}
+	return 0, false, nil
+}
+
+func TestBackhauls(t *testing.T) {
+	distances := &RoadDistances{backhaulDistance,
+		map[common.CityState]geo.SphereCoords{
+			boise:    geo.SphereCoords{43.61, -116.20},
+			meridian: geo.SphereCoords{43.61, -116.39},
+			reno:     geo.SphereCoords{39.53, -119.81},
+		}, 1.2}
+	near := []geo.Near{{geo.CityStateLoc{nampa, geo.SphereCoords{}}, 0},
+		{geo.CityStateLoc{meridian, geo.SphereCoords{}}, 0}}
+	b := NewBackhauls(boise, near, distances)
+	for _, load := range []boards.Load{
+		// 1400 / 730 km
+		testLoad("Nampa, ID", "Salem, OR", "Van", "$1400"),
+		// 1400 / 700 km
+		testLoad("Boise, ID", "Salem, OR", "Van", "$1400"),
+		// Too far away
+		testLoad("Reno, NV", "Salem, OR", "Van", "$5000"),
+		// No rate
+		testLoad("Nampa, ID", "Salem, OR", "Van", "Call"),
+		// No distance to Nampa, estimated to Reno
+		testLoad("Meridian, ID", "Nampa, ID", "Van", "$100"),
+		testLoad("Meridian, ID", "Reno, NV", "Van", "$600"),
+	} {
+		if err := b.Add(load, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := b.Ranked()
+	if len(list) != 3 {
+		t.Fatalf("Expected 3 backhauls: %v", list)
+	}
+	if list[0].Load.Origin != boise || list[0].CentsPerKm != 200 ||
+		list[0].Deadhead != 0 || list[0].Estimated {
+		t.Errorf("Incorrect first backhaul: %+v", list[0])
+	}
+	if list[1].Load.Origin != nampa || list[1].Deadhead != 30 ||
+		list[1].Loaded != 700 {
+		t.Errorf("Incorrect second backhaul: %+v", list[1])
+	}
+	if h := list[2]; h.Load.Dest != reno || !h.Estimated ||
+		h.Deadhead < 15 || h.Deadhead > 20 || h.Loaded < 550 {
+		t.Errorf("Incorrect estimated backhaul: %+v", h)
+	}
+}
@@ -0,0 +1,263 @@
+// Package lanes summarizes loads by origin and destination.
+
+package lanes
+
+import "encoding/csv"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "math"
+import "sort"
+import "strconv"
+import "strings"
+import "time"
+
+import "boards"
+import "common"
+
+type Key struct {
+	Origin common.CityState
+	Dest   common.CityState
+}
+
+// Lane is the summary of loads posted between two places.  Prices
+// are in cents and cover only the loads whose rate could be totaled.
+type Lane struct {
+	Origin      common.CityState
+	Dest        common.CityState
+	Loads       int
+	Priced      int
+	Kilometers  int // 0 if the road distance is unknown
+	P10         int
+	Median      int
+	P90         int
+	CentsPerKm  float64 // median, 0 if the road distance is unknown
+	Equipment   map[string]int
+	prices      []float64
+	pricesPerKm []float64
+}
+
+type Report struct {
+	From  time.Time
+	To    time.Time
+	Lanes []*Lane
+	lanes map[Key]*Lane
+}
+
+// DistanceFunc returns the road distance between two places, if known.
+type DistanceFunc func(src, dest common.CityState) (int, bool, error)
+
+func NewReport(from, to time.Time) *Report {
+	return &Report{from, to, nil, make(map[Key]*Lane)}
+}
+
+// Add counts a load posted count times, using dist to price it.
+func (r *Report) Add(load boards.Load, count int, dist DistanceFunc) error {
+	key := Key{load.Origin, load.Dest}
+	lane, has := r.lanes[key]
+	if !has {
+		km, _, err := dist(load.Origin, load.Dest)
+		if err != nil {
+			return err
+		}
+		lane = &Lane{Origin: load.Origin, Dest: load.Dest,
+			Kilometers: km, Equipment: make(map[string]int)}
+		r.lanes[key] = lane
+		r.Lanes = append(r.Lanes, lane)
+	}
+	lane.Loads += count
+	lane.Equipment[load.Equipment] += count
+	total, ok := 0, false
+	if lane.Kilometers > 0 {
+		if price, priced := load.Rate.Normalize(float64(lane.Kilometers)); priced {
+			total, ok = price.TotalCents, true
+		}
+	} else if load.Rate.Type == boards.RateFlat {
+		total, ok = load.Rate.Cents, true
+	}
+	if !ok {
+		return nil
+	}
+	for i := 0; i < count; i++ {
+		lane.prices = append(lane.prices, float64(total))
+		if lane.Kilometers > 0 {
+			lane.pricesPerKm = append(lane.pricesPerKm,
+				float64(total)/float64(lane.Kilometers))
+		}
+	}
+	return nil
+}
+
+// Finish computes the statistics for each lane and orders the lanes
+// by decreasing load count.
+func (r *Report) Finish() {
+	for _, lane := range r.Lanes {
+		sort.Float64s(lane.prices)
+		sort.Float64s(lane.pricesPerKm)
+		lane.Priced = len(lane.prices)
+		if lane.Priced != 0 {
+			lane.P10 = round(Percentile(lane.prices, 10))
+			lane.Median = round(Percentile(lane.prices, 50))
+			lane.P90 = round(Percentile(lane.prices, 90))
+		}
+		if len(lane.pricesPerKm) != 0 {
+			lane.CentsPerKm = Percentile(lane.pricesPerKm, 50)
+		}
+	}
+	sort.Sort(byLoads(r.Lanes))
+}
+
+// Percentile interpolates the p'th percentile of sorted, non-empty
+// values.
+func Percentile(sorted []float64, p float64) float64 {
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(lo)
+	return sorted[lo] + frac*(sorted[lo+1]-sorted[lo])
+}
+
+func round(f float64) int {
+	return int(math.Floor(f + 0.5))
+}
+
+type byLoads []*Lane
+
+func (l byLoads) Len() int      { return len(l) }
+func (l byLoads) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byLoads) Less(i, j int) bool {
+	if l[i].Loads != l[j].Loads {
+		return l[i].Loads > l[j].Loads
+	}
+	if l[i].Origin != l[j].Origin {
+		return l[i].Origin.String() < l[j].Origin.String()
+	}
+	return l[i].Dest.String() < l[j].Dest.String()
+}
+
+// EquipmentMix formats equipment counts as "Van:3 Flatbed:1", most
+// common first.
+func (l *Lane) EquipmentMix() string {
+	var names []string
+	for name, _ := range l.Equipment {
+		names = append(names, name)
+	}
+	sort.Sort(byCount{names, l.Equipment})
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprint(name, ":", l.Equipment[name]))
+	}
+	return strings.Join(parts, " ")
+}
+
+type byCount struct {
+	names  []string
+	counts map[string]int
+}
+
+func (b byCount) Len() int      { return len(b.names) }
+func (b byCount) Swap(i, j int) { b.names[i], b.names[j] = b.names[j], b.names[i] }
+func (b byCount) Less(i, j int) bool {
+	ci, cj := b.counts[b.names[i]], b.counts[b.names[j]]
+	if ci != cj {
+		return ci > cj
+	}
+	return b.names[i] < b.names[j]
+}
+
+func dollars(cents int) string {
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+func (r *Report) dateRange() string {
+	return fmt.Sprint(common.FormatLoadDate(r.From), " to ",
+		common.FormatLoadDate(r.To))
+}
+
+func (r *Report) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "Lanes from %s: %d\n",
+		r.dateRange(), len(r.Lanes)); err != nil {
+		return err
+	}
+	for _, l := range r.Lanes {
+		if _, err := fmt.Fprintf(w,
+			"%v -> %v: %d loads, %d priced, p10/median/p90 $%s/$%s/$%s, %d km, $%.2f/km [%s]\n",
+			l.Origin, l.Dest, l.Loads, l.Priced,
+			dollars(l.P10), dollars(l.Median), dollars(l.P90),
+			l.Kilometers, l.CentsPerKm/100, l.EquipmentMix()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"OriginCity", "OriginState", "DestCity", "DestState",
+		"Loads", "Priced", "P10", "Median", "P90", "Kilometers",
+		"PerKm", "Equipment"})
+	for _, l := range r.Lanes {
+		cw.Write([]string{l.Origin.City, l.Origin.State,
+			l.Dest.City, l.Dest.State,
+			strconv.Itoa(l.Loads), strconv.Itoa(l.Priced),
+			dollars(l.P10), dollars(l.Median), dollars(l.P90),
+			strconv.Itoa(l.Kilometers),
+			strconv.FormatFloat(l.CentsPerKm/100, 'f', 2, 64),
+			l.EquipmentMix()})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonLane struct {
+	Origin     string
+	Dest       string
+	Loads      int
+	Priced     int
+	P10        float64
+	Median     float64
+	P90        float64
+	Kilometers int
+	PerKm      float64
+	Equipment  map[string]int
+}
+
+type jsonReport struct {
+	From  string
+	To    string
+	Lanes []jsonLane
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	jr := jsonReport{common.FormatLoadDate(r.From),
+		common.FormatLoadDate(r.To), nil}
+	for _, l := range r.Lanes {
+		jr.Lanes = append(jr.Lanes, jsonLane{l.Origin.String(),
+			l.Dest.String(), l.Loads, l.Priced,
+			float64(l.P10) / 100, float64(l.Median) / 100,
+			float64(l.P90) / 100, l.Kilometers, l.CentsPerKm / 100,
+			l.Equipment})
+	}
+	out, err := json.MarshalIndent(jr, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+// Write writes the report as "text", "csv" or "json".
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "text":
+		return r.WriteText(w)
+	case "csv":
+		return r.WriteCSV(w)
+	case "json":
+		return r.WriteJSON(w)
+	}
+	return errors.New("Unknown report format: " + format)
+}
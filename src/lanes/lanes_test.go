@@ -0,0 +1,95 @@
+package lanes
+
+import "bytes"
+import "encoding/json"
+import "strings"
+import "testing"
+import "time"
+
+import "boards"
+import "common"
+
+func testLoad(from, to, equip, rate string) boards.Load {
+	return boards.Load{0, "test", time.Now(),
+		common.ParseCityState(from), common.ParseCityState(to),
+		"Full", 48, 40000, equip, boards.ParseRate(rate), 0, ""}
+}
+
+func testDistance(src, dest common.CityState) (int, bool, error) {
+	if src.City == "Salem" && dest.City == "Boise" {
+		return 800, true, nil
+	}
+	return 0, false, nil
+}
+
+func TestPercentile(t *testing.T) {
+	v := []float64{1, 2, 3, 4, 5}
+	if p := Percentile(v, 50); p != 3 {
+		t.Errorf("Median %v", p)
+	}
+	if p := Percentile(v, 10); p != 1.4 {
+		t.Errorf("P10 %v", p)
+	}
+	if p := Percentile(v, 100); p != 5 {
+		t.Errorf("P100 %v", p)
+	}
+	if p := Percentile([]float64{7}, 90); p != 7 {
+		t.Errorf("Single %v", p)
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := NewReport(time.Now(), time.Now())
+	for _, l := range []struct {
+		load  boards.Load
+		count int
+	}{
+		{testLoad("Salem, OR", "Boise, ID", "Van", "$1000"), 2},
+		{testLoad("Salem, OR", "Boise, ID", "Flatbed", "$2000"), 1},
+		{testLoad("Salem, OR", "Boise, ID", "Van", "Call"), 1},
+		{testLoad("Eugene, OR", "Boise, ID", "Van", "$500"), 1},
+		{testLoad("Eugene, OR", "Boise, ID", "Van", "2.00/mi"), 1},
+	} {
+		if err := r.Add(l.load, l.count, testDistance); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.Finish()
+	if len(r.Lanes) != 2 {
+		t.Fatalf("Expected 2 lanes: %v", r.Lanes)
+	}
+	salem := r.Lanes[0]
+	if salem.Origin.City != "Salem" || salem.Loads != 4 ||
+		salem.Priced != 3 || salem.Median != 100000 ||
+		salem.P90 != 180000 || salem.CentsPerKm != 125 ||
+		salem.EquipmentMix() != "Van:3 Flatbed:1" {
+		t.Errorf("Incorrect lane: %+v", salem)
+	}
+	// Without a distance only the flat rate is priced.
+	eugene := r.Lanes[1]
+	if eugene.Loads != 2 || eugene.Priced != 1 || eugene.Median != 50000 ||
+		eugene.CentsPerKm != 0 {
+		t.Errorf("Incorrect lane: %+v", eugene)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 ||
+		lines[1] != "Salem,OR,Boise,ID,4,3,1000.00,1000.00,1800.00,800,1.25,Van:3 Flatbed:1" {
+		t.Errorf("Incorrect CSV: %q", lines)
+	}
+	buf.Reset()
+	if err := r.Write(&buf, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var jr jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &jr); err != nil ||
+		len(jr.Lanes) != 2 || jr.Lanes[0].Median != 1000 {
+		t.Errorf("Incorrect JSON: %v %s", err, buf.String())
+	}
+	if err := r.Write(&buf, "xml"); err == nil {
+		t.Errorf("Wrote an unknown format")
+	}
+}
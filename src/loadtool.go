@@ -1,21 +1,81 @@
 package main
 
-import "database/sql"
 import "errors"
 import "flag"
 import "fmt"
 import "log"
+import "os"
 import "runtime"
+import "sort"
 import "time"
 
 import "boards"
 import "common"
 
 import "data"
+import "fleet"
+import "forecast"
+import "geo"
+import "lanes"
 import "scraper"
+import "trips"
 
 var show_by_city = flag.String("show_by_city", 
 	"", "Source or destination")
+var board = flag.String("board", "",
+	"Only analyze loads from this board; all boards if empty")
+var lane_report = flag.Bool("lane_report", false,
+	"Summarize prices and equipment by origin and destination")
+var report_format = flag.String("report_format", "text",
+	"Report format: text, csv or json")
+var forecast_days = flag.Int("forecast_days", 0,
+	"Predict load volumes for this many days after the last scrape")
+var forecast_by = flag.String("forecast_by", "",
+	"Forecast by state, cluster or equipment; all if empty")
+var holdout_days = flag.Int("holdout_days", 7,
+	"Backtest forecasts against this many held-out last days; 0 to skip")
+var confidence = flag.Float64("confidence", 0.9,
+	"Confidence level of forecast intervals")
+var cluster_km = flag.Float64("cluster_km", 80,
+	"Radius of the origin city clusters forecast")
+var backhaul_from = flag.String("backhaul_from", "",
+	"Rank loads leaving from near this delivery city, e.g. \"Boise, ID\"")
+var backhaul_km = flag.Float64("backhaul_km", 150,
+	"Radius around --backhaul_from to look for loads")
+var backhaul_limit = flag.Int("backhaul_limit", 25,
+	"Number of backhauls to show")
+var circuity = flag.Float64("circuity", 1.2,
+	"Ratio of road to great circle distance, for unknown road distances")
+var plan_from = flag.String("plan_from", "",
+	"Plan itineraries for a truck starting at this city")
+var plan_date = flag.String("plan_date", "",
+	"Start date of planned itineraries, YYYY-MM-DD; the last scrape if empty")
+var horizon_days = flag.Int("horizon_days", 3,
+	"Days within which planned loads must be delivered")
+var top_k = flag.Int("top_k", 5, "Number of itineraries to show")
+var deadhead_cost = flag.Float64("deadhead_cost", 100,
+	"Cost in cents of each empty kilometer driven")
+var driving_hours = flag.Float64("driving_hours", 11,
+	"Hours a day a truck can drive")
+var handling_hours = flag.Float64("handling_hours", 1,
+	"Hours to load or unload")
+var max_legs = flag.Int("max_legs", 4, "Most loads in an itinerary")
+var beam_width = flag.Int("beam_width", 100,
+	"Partial itineraries kept at each leg of the search")
+var speed_kph = flag.Float64("speed_kph", 80,
+	"Average speed, where travel times are not in --distance_dir")
+var distance_dir = flag.String("distance_dir", "",
+	"Directory of osrmtool distance tables for travel times")
+var fleet_file = flag.String("fleet_file", "",
+	"Assign loads to the trucks in this CSV file")
+var return_home = flag.Bool("return_home", true,
+	"Count the empty drive home after each truck's last delivery")
+var vrp_iterations = flag.Int("vrp_iterations", 20,
+	"Rounds of local search when assigning loads to a fleet")
+var from_date = flag.String("from", "",
+	"First day to report, YYYY-MM-DD; the first scrape if empty")
+var to_date = flag.String("to", "",
+	"Last day to report, YYYY-MM-DD; the last scrape if empty")
 
 type LoadSet struct {
 	data.ConvoyData
@@ -33,6 +93,9 @@ type LoadSet struct {
 	// Map of day number to loads, grouped and counted, de-duped, etc.
 	loads []map[boards.Load]int
 
+	// City name corrections applied to loads
+	corrections map[common.CityState]common.CityState
+
 	// Some statistics
 	dups, sameday, pastdate, repeat, ltrepeat, gtrepeat, total int
 }
@@ -109,10 +172,19 @@ func (ls *LoadSet) removeRepost(tday int, tload, yload boards.Load) {
 	}
 }
 
+func (ls *LoadSet) correct(load *boards.Load) {
+	if corr, has := ls.corrections[load.Origin]; has {
+		load.Origin = corr
+	}
+	if corr, has := ls.corrections[load.Dest]; has {
+		load.Dest = corr
+	}
+}
+
 func (ls *LoadSet) readLoads() error {
-	corrections := make(map[common.CityState]common.CityState)
+	ls.corrections = make(map[common.CityState]common.CityState)
 	if err := ls.ForAllCorrections(func(in, out common.CityState) error {
-		corrections[in] = out
+		ls.corrections[in] = out
 		return nil
 	}); err != nil {
 		return err
@@ -122,6 +194,9 @@ func (ls *LoadSet) readLoads() error {
 		ls.loads[i] = make(map[boards.Load]int)
 	}
 	if err := ls.ConvoyData.ForAllLoads(func (load boards.Load) error {
+		if len(*board) != 0 && load.Board != *board {
+			return nil
+		}
 		day := ls.scrapeToDay[load.ScrapeId]
 		date := ls.dayToDate[day]
 		if date.Equal(load.PickupDate) {
@@ -131,12 +206,7 @@ func (ls *LoadSet) readLoads() error {
 			return nil
 		}
 		load.ScrapeId = 0
-		if corr, has := corrections[load.Origin]; has {
-			load.Origin = corr
-		}
-		if corr, has := corrections[load.Dest]; has {
-			load.Dest = corr
-		}
+		ls.correct(&load)
 		if cnt, has := ls.loads[day][load]; has {
 			ls.loads[day][load] = cnt + 1
 			ls.dups++
@@ -175,7 +245,7 @@ func (ls *LoadSet) readLoads() error {
 	return nil
 }
 
-func NewLoadSet(db *sql.DB) (*LoadSet, error) {
+func NewLoadSet(db data.Storage) (*LoadSet, error) {
 	ls := &LoadSet{}
 	cd, err := data.NewConvoyData(db)
 	if err != nil {
@@ -207,11 +277,300 @@ func (ls *LoadSet) showByCity(cs common.CityState) error {
 	return nil
 }
 
+// date returns the date of a day number, whether or not it was
+// scraped.
+func (ls *LoadSet) date(day int) time.Time {
+	return ls.dayZero.AddDate(0, 0, day)
+}
+
+// dayRange returns the days selected by --from and --to.
+func (ls *LoadSet) dayRange() (int, int, error) {
+	first, last := 0, ls.days-1
+	if len(*from_date) != 0 {
+		from, err := common.ParseLoadDate(*from_date)
+		if err != nil {
+			return 0, 0, err
+		}
+		for first <= last && ls.date(first).Before(from) {
+			first++
+		}
+	}
+	if len(*to_date) != 0 {
+		to, err := common.ParseLoadDate(*to_date)
+		if err != nil {
+			return 0, 0, err
+		}
+		for last >= first && ls.date(last).After(to) {
+			last--
+		}
+	}
+	return first, last, nil
+}
+
+// laneReport summarizes the loads remaining after repost removal.
+func (ls *LoadSet) laneReport() error {
+	first, last, err := ls.dayRange()
+	if err != nil {
+		return err
+	}
+	if first > last {
+		return errors.New("No scrapes in the date range")
+	}
+	report := lanes.NewReport(ls.date(first), ls.date(last))
+	for day := first; day <= last; day++ {
+		for load, count := range ls.loads[day] {
+			if err := report.Add(load, count, ls.GetRoadDistance); err != nil {
+				return err
+			}
+		}
+	}
+	report.Finish()
+	return report.Write(os.Stdout, *report_format)
+}
+
+// originClusters groups the load origins that have known locations,
+// the busiest origins leading.
+func (ls *LoadSet) originClusters() (map[common.CityState]string, error) {
+	volume := make(map[common.CityState]int)
+	for _, loadmap := range ls.loads {
+		for load, count := range loadmap {
+			volume[load.Origin] += count
+		}
+	}
+	var cities []geo.CityStateLoc
+	if err := ls.ForAllLocations(func(_ int64, loc geo.CityStateLoc) error {
+		if _, has := volume[loc.CityState]; has {
+			cities = append(cities, loc)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Sort(byVolume{cities, volume})
+	return forecast.ClusterCities(cities, *cluster_km*1000), nil
+}
+
+type byVolume struct {
+	cities []geo.CityStateLoc
+	volume map[common.CityState]int
+}
+
+func (b byVolume) Len() int      { return len(b.cities) }
+func (b byVolume) Swap(i, j int) { b.cities[i], b.cities[j] = b.cities[j], b.cities[i] }
+func (b byVolume) Less(i, j int) bool {
+	vi, vj := b.volume[b.cities[i].CityState], b.volume[b.cities[j].CityState]
+	if vi != vj {
+		return vi > vj
+	}
+	return b.cities[i].CityState.String() < b.cities[j].CityState.String()
+}
+
+// forecastReport predicts load volumes following the days selected by
+// --from and --to.
+func (ls *LoadSet) forecastReport() error {
+	first, last, err := ls.dayRange()
+	if err != nil {
+		return err
+	}
+	if first > last {
+		return errors.New("No scrapes in the date range")
+	}
+	clusters, err := ls.originClusters()
+	if err != nil {
+		return err
+	}
+	observed := make([]bool, last-first+1)
+	for day := first; day <= last; day++ {
+		observed[day-first] = ls.dayToScrape[day] != 0
+	}
+	groups := forecast.NewGroups(ls.date(first), observed, clusters)
+	for day := first; day <= last; day++ {
+		for load, count := range ls.loads[day] {
+			groups.Add(day-first, load, count)
+		}
+	}
+	results := groups.Forecast(*forecast_by, *forecast_days,
+		*holdout_days, forecast.ZScore(*confidence))
+	return forecast.Write(os.Stdout, *report_format, results)
+}
+
+// lastScrape returns the last scrape in the days selected by --from
+// and --to.
+func (ls *LoadSet) lastScrape() (int, error) {
+	first, last, err := ls.dayRange()
+	if err != nil {
+		return 0, err
+	}
+	for last >= first && ls.dayToScrape[last] == 0 {
+		last--
+	}
+	if first > last {
+		return 0, errors.New("No scrapes in the date range")
+	}
+	return last, nil
+}
+
+// forAllPosted calls lfunc with each corrected load posted on day,
+// including reposts, since they are still available.
+func (ls *LoadSet) forAllPosted(day int, lfunc func(boards.Load) error) error {
+	scrapeId := ls.dayToScrape[day]
+	return ls.ForAllLoads(func(load boards.Load) error {
+		if load.ScrapeId != scrapeId ||
+			(len(*board) != 0 && load.Board != *board) {
+			return nil
+		}
+		ls.correct(&load)
+		return lfunc(load)
+	})
+}
+
+// locations returns the known locations and their Ids.
+func (ls *LoadSet) locations() ([]geo.CityStateLoc,
+	map[common.CityState]geo.SphereCoords, map[common.CityState]int64, error) {
+	var locs []geo.CityStateLoc
+	coords := make(map[common.CityState]geo.SphereCoords)
+	ids := make(map[common.CityState]int64)
+	if err := ls.ForAllLocations(func(id int64, loc geo.CityStateLoc) error {
+		locs = append(locs, loc)
+		coords[loc.CityState] = loc.SphereCoords
+		ids[loc.CityState] = id
+		return nil
+	}); err != nil {
+		return nil, nil, nil, err
+	}
+	return locs, coords, ids, nil
+}
+
+// backhauls ranks the loads from the last scrape in the selected
+// days that leave from near --backhaul_from.
+func (ls *LoadSet) backhauls() error {
+	last, err := ls.lastScrape()
+	if err != nil {
+		return err
+	}
+	from := common.ParseCityState(*backhaul_from)
+	locs, coords, _, err := ls.locations()
+	if err != nil {
+		return err
+	}
+	center, has := coords[from]
+	if !has {
+		return errors.New(fmt.Sprint("Unknown location: ", from))
+	}
+	near := geo.NewLocationIndex(locs).Within(center, *backhaul_km*1000)
+	b := lanes.NewBackhauls(from, near,
+		&lanes.RoadDistances{ls.GetRoadDistance, coords, *circuity})
+	if err := ls.forAllPosted(last, func(load boards.Load) error {
+		return b.Add(load, 1)
+	}); err != nil {
+		return err
+	}
+	list := b.Ranked()
+	if len(list) > *backhaul_limit {
+		list = list[:*backhaul_limit]
+	}
+	return lanes.WriteBackhauls(os.Stdout, *report_format, from, list)
+}
+
+// planTrips chains loads from the last scrape in the selected days
+// into itineraries starting at --plan_from.
+func (ls *LoadSet) planTrips() error {
+	last, err := ls.lastScrape()
+	if err != nil {
+		return err
+	}
+	start := ls.date(last)
+	if len(*plan_date) != 0 {
+		if start, err = common.ParseLoadDate(*plan_date); err != nil {
+			return err
+		}
+	}
+	from := common.ParseCityState(*plan_from)
+	_, coords, ids, err := ls.locations()
+	if err != nil {
+		return err
+	}
+	if _, has := coords[from]; !has {
+		return errors.New(fmt.Sprint("Unknown location: ", from))
+	}
+	var loads []*boards.Load
+	if err := ls.forAllPosted(last, func(load boards.Load) error {
+		if !load.PickupDate.Before(start) {
+			loads = append(loads, &load)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	travel := trips.NewTravel(*distance_dir, ids,
+		&lanes.RoadDistances{ls.GetRoadDistance, coords, *circuity},
+		*speed_kph)
+	planner := trips.NewPlanner(trips.Options{from, start,
+		time.Duration(*horizon_days) * 24 * time.Hour,
+		*deadhead_cost, *driving_hours,
+		time.Duration(*handling_hours * float64(time.Hour)),
+		*max_legs, *beam_width}, travel.Lookup, loads)
+	itineraries, err := planner.Plan(*top_k)
+	if err != nil {
+		return err
+	}
+	return trips.Write(os.Stdout, *report_format, itineraries)
+}
+
+// assignFleet assigns loads from the last scrape in the selected days
+// to the trucks in --fleet_file.
+func (ls *LoadSet) assignFleet() error {
+	f, err := os.Open(*fleet_file)
+	if err != nil {
+		return err
+	}
+	trucks, err := fleet.ReadTrucks(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	last, err := ls.lastScrape()
+	if err != nil {
+		return err
+	}
+	start := ls.date(last)
+	if len(*plan_date) != 0 {
+		if start, err = common.ParseLoadDate(*plan_date); err != nil {
+			return err
+		}
+	}
+	_, coords, ids, err := ls.locations()
+	if err != nil {
+		return err
+	}
+	var loads []*boards.Load
+	if err := ls.forAllPosted(last, func(load boards.Load) error {
+		if !load.PickupDate.Before(start) {
+			loads = append(loads, &load)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	travel := trips.NewTravel(*distance_dir, ids,
+		&lanes.RoadDistances{ls.GetRoadDistance, coords, *circuity},
+		*speed_kph)
+	sol, err := fleet.Solve(trucks, loads, travel.Lookup, fleet.Options{
+		start, time.Duration(*horizon_days) * 24 * time.Hour,
+		*deadhead_cost, *driving_hours,
+		time.Duration(*handling_hours * float64(time.Hour)),
+		*return_home, *vrp_iterations})
+	if err != nil {
+		return err
+	}
+	return fleet.Write(os.Stdout, *report_format, sol)
+}
+
 func main() {
 	data.Main(programBody)
 }
 
-func programBody(db *sql.DB) error {
+func programBody(db data.Storage) error {
 	flag.Parse()
 	argv := flag.Args()
 	runtime.GOMAXPROCS(runtime.NumCPU())
@@ -226,6 +585,16 @@ func programBody(db *sql.DB) error {
 	switch {
 	case len(*show_by_city) != 0:
 		ls.showByCity(common.ParseCityState(*show_by_city))
+	case *lane_report:
+		return ls.laneReport()
+	case *forecast_days > 0:
+		return ls.forecastReport()
+	case len(*backhaul_from) != 0:
+		return ls.backhauls()
+	case len(*plan_from) != 0:
+		return ls.planTrips()
+	case len(*fleet_file) != 0:
+		return ls.assignFleet()
 	}
 	return nil
 }
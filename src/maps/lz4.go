@@ -0,0 +1,77 @@
+package maps
+
+import "errors"
+import "fmt"
+
+// decodeLz4 decompresses a single LZ4 block, the format of a blob's
+// lz4_data, into exactly size bytes.
+func decodeLz4(src []byte, size int32) ([]byte, error) {
+	if size < 0 {
+		return nil, errors.New(fmt.Sprint("Negative lz4 size: ", size))
+	}
+	dst := make([]byte, 0, size)
+	corrupt := errors.New("Corrupt lz4 block")
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		literals := int(token >> 4)
+		if literals == 15 {
+			for {
+				if i >= len(src) {
+					return nil, corrupt
+				}
+				b := src[i]
+				i++
+				literals += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		if i+literals > len(src) {
+			return nil, corrupt
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			// The last sequence has no match.
+			break
+		}
+		if i+2 > len(src) {
+			return nil, corrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, corrupt
+		}
+		match := int(token & 15)
+		if match == 15 {
+			for {
+				if i >= len(src) {
+					return nil, corrupt
+				}
+				b := src[i]
+				i++
+				match += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		match += 4
+		if len(dst)+match > int(size) {
+			return nil, corrupt
+		}
+		// Matches may overlap the bytes they produce.
+		for start := len(dst) - offset; match > 0; match-- {
+			dst = append(dst, dst[start])
+			start++
+		}
+	}
+	if len(dst) != int(size) {
+		return nil, errors.New(
+			fmt.Sprintln("Insufficient lz4 data:", len(dst), size))
+	}
+	return dst, nil
+}
@@ -11,6 +11,8 @@ import "log"
 import "runtime"
 
 import "code.google.com/p/goprotobuf/proto"
+import "github.com/klauspost/compress/zstd"
+import "github.com/ulikunitz/xz/lzma"
 
 import "proto/osm"
 
@@ -19,12 +21,20 @@ var (
 )
 
 type Map struct {
+	Header                     Header
 	numNodes, numWays, numRels int64
 	blockCh                    chan *osm.Blob
 	graphCh                    chan *BlockData
 	doneCh                     chan bool
 }
 
+// Header holds the features declared by the file's OSMHeader block.
+type Header struct {
+	Sorted          bool // Sort.Type_then_ID: nodes, then ways, then relations
+	LocationsOnWays bool // Ways carry the locations of their nodes
+	Features        []string
+}
+
 type BlockData struct {
 	Nodes []Node
 	Ways  []Way
@@ -85,6 +95,9 @@ func NewMap() *Map {
 }
 
 func readFixed(f io.Reader, s int32) ([]byte, error) {
+	if s < 0 {
+		return nil, errors.New(fmt.Sprint("Negative size: ", s))
+	}
 	buf, err := ioutil.ReadAll(&io.LimitedReader{f, int64(s)})
 	if err != nil {
 		return nil, err
@@ -149,6 +162,33 @@ func decodeWay(pway *osm.Way, way *Way, bp *blockParams) error {
 	return nil
 }
 
+// decodeWayNodes returns the nodes of a way written with
+// LocationsOnWays, which may be absent from the file's node blocks.
+func decodeWayNodes(pway *osm.Way, way *Way, bp *blockParams) ([]Node, error) {
+	lats := pway.GetLat()
+	lons := pway.GetLon()
+	if len(lats) == 0 && len(lons) == 0 {
+		return nil, nil
+	}
+	if len(lats) != len(way.Refs) || len(lons) != len(way.Refs) {
+		return nil, errors.New(fmt.Sprintf(
+			"Incorrect way location lengths: %d %d %d",
+			len(way.Refs), len(lats), len(lons)))
+	}
+	nodes := make([]Node, len(way.Refs))
+	var llat int64
+	var llon int64
+	for i, ref := range way.Refs {
+		llat += lats[i]
+		llon += lons[i]
+		n := &nodes[i]
+		n.Id = ref
+		n.Lat = 1e-9 * float64(bp.latOffset+(bp.granularity*llat))
+		n.Long = 1e-9 * float64(bp.lonOffset+(bp.granularity*llon))
+	}
+	return nodes, nil
+}
+
 func decodeAttrs(keys, vals []uint32, bp *blockParams) Attributes {
 	attrs := make(Attributes, len(keys))
 	for i, _ := range attrs {
@@ -173,14 +213,20 @@ func decodeRelation(prel *osm.Relation, rel *Relation, bp *blockParams) error {
 
 }
 
-func decodeWays(pways []*osm.Way, bp *blockParams) ([]Way, error) {
+func decodeWays(pways []*osm.Way, bp *blockParams) ([]Way, []Node, error) {
 	ways := make([]Way, len(pways))
+	var nodes []Node
 	for i, pway := range pways {
 		if err := decodeWay(pway, &ways[i], bp); err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		wnodes, err := decodeWayNodes(pway, &ways[i], bp)
+		if err != nil {
+			return nil, nil, err
 		}
+		nodes = append(nodes, wnodes...)
 	}
-	return ways, nil
+	return ways, nodes, nil
 }
 
 func decodeRelations(prels []*osm.Relation, bp *blockParams) ([]Relation, error) {
@@ -209,18 +255,19 @@ func decodeBlock(pb *osm.PrimitiveBlock) (*BlockData, error) {
 			if err != nil {
 				return nil, err
 			}
-			bdata.Nodes = nodes
+			bdata.Nodes = append(bdata.Nodes, nodes...)
 		}
-		ways, err := decodeWays(pg.GetWays(), bparams)
+		ways, wnodes, err := decodeWays(pg.GetWays(), bparams)
 		if err != nil {
 			return nil, err
 		}
-		bdata.Ways = ways
+		bdata.Ways = append(bdata.Ways, ways...)
+		bdata.Nodes = append(bdata.Nodes, wnodes...)
 		relations, err := decodeRelations(pg.GetRelations(), bparams)
 		if err != nil {
 			return nil, err
 		}
-		bdata.Rels = relations
+		bdata.Rels = append(bdata.Rels, relations...)
 	}
 	return bdata, nil
 }
@@ -275,8 +322,11 @@ func (m *Map) buildGraph(bf func(*BlockData)) {
 	m.doneCh <- true
 }
 
+// decompressBlob decodes a blob by whichever encoding it was written
+// with.
 func decompressBlob(blob *osm.Blob) ([]byte, error) {
 	var data []byte
+	var err error
 	enc := "unknown"
 
 	// Uncompress the raw data, if necessary
@@ -296,6 +346,29 @@ func decompressBlob(blob *osm.Blob) ([]byte, error) {
 		}
 	case blob.LzmaData != nil:
 		enc = "lzma"
+		lr, err := lzma.NewReader(bytes.NewReader(blob.LzmaData))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = readFixed(lr, blob.GetRawSize()); err != nil {
+			return nil, err
+		}
+	case blob.ZstdData != nil:
+		enc = "zstd"
+		zr, err := zstd.NewReader(bytes.NewReader(blob.ZstdData),
+			zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		if data, err = readFixed(zr, blob.GetRawSize()); err != nil {
+			return nil, err
+		}
+	case blob.Lz4Data != nil:
+		enc = "lz4"
+		if data, err = decodeLz4(blob.Lz4Data, blob.GetRawSize()); err != nil {
+			return nil, err
+		}
 	}
 	if data == nil {
 		return nil, errors.New("Unsupported OSM data encoding: " + enc)
@@ -303,7 +376,7 @@ func decompressBlob(blob *osm.Blob) ([]byte, error) {
 	return data, nil
 }
 
-func readHeader(b *osm.Blob) error {
+func (m *Map) readHeader(b *osm.Blob) error {
 	var hdrblock osm.HeaderBlock
 	data, err := decompressBlob(b)
 	if err != nil {
@@ -312,21 +385,39 @@ func readHeader(b *osm.Blob) error {
 	if err := proto.Unmarshal(data, &hdrblock); err != nil {
 		return err
 	}
+	return m.setHeader(&hdrblock)
+}
+
+// setHeader checks that the features a file requires are supported
+// and records those it declares.
+func (m *Map) setHeader(hdrblock *osm.HeaderBlock) error {
 	haveVersion := false
 	haveDense := false
-	for _, rf := range hdrblock.RequiredFeatures {
+	m.Header = Header{}
+	for _, rf := range hdrblock.GetRequiredFeatures() {
 		switch rf {
 		case "OsmSchema-V0.6":
 			haveVersion = true
 		case "DenseNodes":
 			haveDense = true
+		case "LocationsOnWays":
+			m.Header.LocationsOnWays = true
 		default:
 			return errors.New("Unknown map required feature:" + rf)
 		}
 	}
 	if !haveVersion || !haveDense {
 		return errors.New("Unsupported map type: " +
-			proto.CompactTextString(&hdrblock))
+			proto.CompactTextString(hdrblock))
+	}
+	for _, of := range hdrblock.GetOptionalFeatures() {
+		switch of {
+		case "Sort.Type_then_ID":
+			m.Header.Sorted = true
+		case "LocationsOnWays":
+			m.Header.LocationsOnWays = true
+		}
+		m.Header.Features = append(m.Header.Features, of)
 	}
 	return nil
 }
@@ -385,7 +476,7 @@ func (m *Map) ReadMap(file io.Reader, bf func(*BlockData)) error {
 		// Now process each blob
 		switch bh.GetType() {
 		case "OSMHeader":
-			if err := readHeader(blob); err != nil {
+			if err := m.readHeader(blob); err != nil {
 				return err
 			}
 		case "OSMData":
@@ -0,0 +1,117 @@
+package maps
+
+import "bytes"
+import "compress/zlib"
+import "io"
+import "math"
+import "testing"
+
+import "code.google.com/p/goprotobuf/proto"
+import "github.com/klauspost/compress/zstd"
+import "github.com/ulikunitz/xz/lzma"
+
+import "proto/osm"
+
+func TestDecompressBlob(t *testing.T) {
+	raw := bytes.Repeat([]byte("abcabcabcd"), 100)
+	size := proto.Int32(int32(len(raw)))
+
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write(raw)
+	zw.Close()
+
+	var lbuf bytes.Buffer
+	lw, err := lzma.NewWriter(&lbuf)
+	if err != nil {
+		t.Fatal("lzma: ", err)
+	}
+	lw.Write(raw)
+	lw.Close()
+
+	var sbuf bytes.Buffer
+	sw, err := zstd.NewWriter(&sbuf)
+	if err != nil {
+		t.Fatal("zstd: ", err)
+	}
+	sw.Write(raw)
+	sw.Close()
+
+	// Ten literals, a match of 985 at offset 10, then five literals.
+	lz4 := append([]byte{0xaf}, raw[:10]...)
+	lz4 = append(lz4, 10, 0, 255, 255, 255, 201, 0x50)
+	lz4 = append(lz4, raw[995:]...)
+	for _, blob := range []*osm.Blob{
+		&osm.Blob{Raw: raw},
+		&osm.Blob{RawSize: size, ZlibData: zbuf.Bytes()},
+		&osm.Blob{RawSize: size, LzmaData: lbuf.Bytes()},
+		&osm.Blob{RawSize: size, ZstdData: sbuf.Bytes()},
+		&osm.Blob{RawSize: size, Lz4Data: lz4},
+	} {
+		data, err := decompressBlob(blob)
+		if err != nil {
+			t.Errorf("decompressBlob: %v", err)
+			continue
+		}
+		if !bytes.Equal(data, raw) {
+			t.Errorf("Incorrect data: %q", data)
+		}
+	}
+	if _, err := decompressBlob(&osm.Blob{RawSize: size, Lz4Data: lz4[:8]}); err == nil {
+		t.Error("Decoded a truncated lz4 block")
+	}
+	negative := proto.Int32(-1)
+	for _, blob := range []*osm.Blob{
+		&osm.Blob{RawSize: negative, ZlibData: zbuf.Bytes()},
+		&osm.Blob{RawSize: negative, Lz4Data: lz4},
+	} {
+		if _, err := decompressBlob(blob); err == nil || err == io.EOF {
+			t.Errorf("Decoded a negative size: %v", err)
+		}
+	}
+	if _, err := decompressBlob(&osm.Blob{}); err == nil {
+		t.Error("Decoded an empty blob")
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	m := NewMap()
+	hdr := &osm.HeaderBlock{
+		RequiredFeatures: []string{"OsmSchema-V0.6", "DenseNodes"},
+		OptionalFeatures: []string{"Sort.Type_then_ID", "LocationsOnWays"},
+	}
+	if err := m.setHeader(hdr); err != nil {
+		t.Fatal("setHeader: ", err)
+	}
+	if !m.Header.Sorted || !m.Header.LocationsOnWays || len(m.Header.Features) != 2 {
+		t.Errorf("Incorrect header: %v", m.Header)
+	}
+	hdr.RequiredFeatures = append(hdr.RequiredFeatures, "HistoricalInformation")
+	if err := m.setHeader(hdr); err == nil {
+		t.Error("Accepted historical information")
+	}
+}
+
+func TestWayLocations(t *testing.T) {
+	bp := &blockParams{nil, 100, 0, 0}
+	pway := &osm.Way{Id: proto.Int64(7),
+		Refs: []int64{10, 1, 1},
+		Lat:  []int64{450000000, 10, 10},
+		Lon:  []int64{-900000000, -10, 0}}
+	ways, nodes, err := decodeWays([]*osm.Way{pway}, bp)
+	if err != nil {
+		t.Fatal("decodeWays: ", err)
+	}
+	if len(ways) != 1 || len(nodes) != 3 {
+		t.Fatalf("Incorrect ways %v nodes %v", ways, nodes)
+	}
+	last := nodes[2]
+	if last.Id != 12 || math.Abs(last.Lat-45.000002) > 1e-9 ||
+		math.Abs(last.Long+90.000001) > 1e-9 {
+		t.Errorf("Incorrect node: %v", last)
+	}
+	pway.Lat = pway.Lat[:2]
+	if _, _, err := decodeWays([]*osm.Way{pway}, bp); err == nil {
+		t.Error("Accepted mismatched locations")
+	}
+}
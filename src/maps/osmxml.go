@@ -0,0 +1,192 @@
+package maps
+
+import "encoding/xml"
+import "errors"
+import "io"
+import "log"
+
+// xmlBatch is the number of elements in each BlockData read from XML.
+const xmlBatch = 8000
+
+type xmlTag struct {
+	Key   string `xml:"k,attr"`
+	Value string `xml:"v,attr"`
+}
+
+type xmlNode struct {
+	Id   int64    `xml:"id,attr"`
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Tags []xmlTag `xml:"tag"`
+}
+
+type xmlRef struct {
+	Ref int64 `xml:"ref,attr"`
+}
+
+type xmlWay struct {
+	Id   int64    `xml:"id,attr"`
+	Refs []xmlRef `xml:"nd"`
+	Tags []xmlTag `xml:"tag"`
+}
+
+type xmlMember struct {
+	Type string `xml:"type,attr"`
+	Ref  int64  `xml:"ref,attr"`
+	Role string `xml:"role,attr"`
+}
+
+type xmlRelation struct {
+	Id      int64       `xml:"id,attr"`
+	Members []xmlMember `xml:"member"`
+	Tags    []xmlTag    `xml:"tag"`
+}
+
+var memberTypes = map[string]MemberType{
+	"node":     NODE,
+	"way":      WAY,
+	"relation": RELATION,
+}
+
+func xmlAttrs(tags []xmlTag) Attributes {
+	if len(tags) == 0 {
+		return nil
+	}
+	attrs := make(Attributes, len(tags))
+	for i, tag := range tags {
+		attrs[i] = Attribute{tag.Key, tag.Value}
+	}
+	return attrs
+}
+
+// decodeXML calls element with each node, way and relation of an OSM
+// XML or osmChange stream, as a *Node, *Way or *Relation, along with
+// the osmChange action enclosing it.
+func decodeXML(file io.Reader, element func(action string, e interface{})) error {
+	d := xml.NewDecoder(file)
+	action := ""
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if end, ok := token.(xml.EndElement); ok && end.Name.Local == action {
+			action = ""
+			continue
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "create", "modify", "delete":
+			action = start.Name.Local
+		case "node":
+			var xn xmlNode
+			if err := d.DecodeElement(&xn, &start); err != nil {
+				return err
+			}
+			element(action, &Node{xn.Id, xn.Lat, xn.Lon, xmlAttrs(xn.Tags)})
+		case "way":
+			var xw xmlWay
+			if err := d.DecodeElement(&xw, &start); err != nil {
+				return err
+			}
+			refs := make([]int64, len(xw.Refs))
+			for i, ref := range xw.Refs {
+				refs[i] = ref.Ref
+			}
+			element(action, &Way{xw.Id, xmlAttrs(xw.Tags), refs})
+		case "relation":
+			var xr xmlRelation
+			if err := d.DecodeElement(&xr, &start); err != nil {
+				return err
+			}
+			ents := make([]RelEntry, len(xr.Members))
+			for i, m := range xr.Members {
+				t, has := memberTypes[m.Type]
+				if !has {
+					return errors.New("Unknown OSM member type: " + m.Type)
+				}
+				ents[i] = RelEntry{m.Ref, t, m.Role}
+			}
+			element(action, &Relation{xr.Id, xmlAttrs(xr.Tags), ents})
+		}
+	}
+}
+
+// add appends an element decoded by decodeXML.
+func (bd *BlockData) add(e interface{}) {
+	switch e := e.(type) {
+	case *Node:
+		bd.Nodes = append(bd.Nodes, *e)
+	case *Way:
+		bd.Ways = append(bd.Ways, *e)
+	case *Relation:
+		bd.Rels = append(bd.Rels, *e)
+	}
+}
+
+// ReadXML reads an OSM XML file, passing its elements to bf in
+// batches as ReadMap does for PBF.
+func (m *Map) ReadXML(file io.Reader, bf func(*BlockData)) error {
+	bd := &BlockData{}
+	count := 0
+	flush := func() {
+		m.numNodes += int64(len(bd.Nodes))
+		m.numWays += int64(len(bd.Ways))
+		m.numRels += int64(len(bd.Rels))
+		bf(bd)
+		bd = &BlockData{}
+		count = 0
+	}
+	if err := decodeXML(file, func(_ string, e interface{}) {
+		bd.add(e)
+		if count++; count == xmlBatch {
+			flush()
+		}
+	}); err != nil {
+		return err
+	}
+	if count != 0 {
+		flush()
+	}
+	log.Println("Finished reading XML",
+		m.numNodes, "nodes",
+		m.numWays, "ways",
+		m.numRels, "relations")
+	return nil
+}
+
+// Change is an osmChange (.osc) file: the elements it creates, the new
+// versions of those it modifies, and those it deletes.
+type Change struct {
+	Create, Modify, Delete BlockData
+}
+
+// ReadChange reads an osmChange file.
+func ReadChange(file io.Reader) (*Change, error) {
+	c := &Change{}
+	var err error
+	if derr := decodeXML(file, func(action string, e interface{}) {
+		switch action {
+		case "create":
+			c.Create.add(e)
+		case "modify":
+			c.Modify.add(e)
+		case "delete":
+			c.Delete.add(e)
+		default:
+			err = errors.New("OSM element outside a change action")
+		}
+	}); derr != nil {
+		return nil, derr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
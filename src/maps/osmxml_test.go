@@ -0,0 +1,95 @@
+package maps
+
+import "strings"
+import "testing"
+
+const testOsm = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="test">
+ <bounds minlat="45" minlon="-91" maxlat="46" maxlon="-90"/>
+ <node id="1" lat="45.5" lon="-90.5"/>
+ <node id="2" lat="45.6" lon="-90.4">
+  <tag k="highway" v="traffic_signals"/>
+ </node>
+ <way id="10">
+  <nd ref="1"/>
+  <nd ref="2"/>
+  <tag k="highway" v="primary"/>
+  <tag k="maxspeed" v="45 mph"/>
+ </way>
+ <relation id="20">
+  <member type="way" ref="10" role="from"/>
+  <member type="node" ref="2" role="via"/>
+  <tag k="type" v="restriction"/>
+ </relation>
+</osm>`
+
+const testOsc = `<?xml version="1.0" encoding="UTF-8"?>
+<osmChange version="0.6" generator="test">
+ <create>
+  <node id="3" lat="45.7" lon="-90.3"/>
+ </create>
+ <modify>
+  <way id="10">
+   <nd ref="1"/>
+   <nd ref="2"/>
+   <nd ref="3"/>
+   <tag k="highway" v="primary"/>
+  </way>
+ </modify>
+ <delete>
+  <relation id="20"/>
+ </delete>
+</osmChange>`
+
+func TestReadXML(t *testing.T) {
+	var bds []*BlockData
+	m := NewMap()
+	if err := m.ReadXML(strings.NewReader(testOsm), func(bd *BlockData) {
+		bds = append(bds, bd)
+	}); err != nil {
+		t.Fatal("ReadXML: ", err)
+	}
+	if len(bds) != 1 {
+		t.Fatalf("Expected one batch, got %v", len(bds))
+	}
+	bd := bds[0]
+	if len(bd.Nodes) != 2 || len(bd.Ways) != 1 || len(bd.Rels) != 1 {
+		t.Fatalf("Incorrect block: %v", bd)
+	}
+	if n := bd.Nodes[1]; n.Id != 2 || n.Lat != 45.6 || n.Long != -90.4 ||
+		len(n.Attrs) != 1 {
+		t.Errorf("Incorrect node: %v", n)
+	}
+	way := bd.Ways[0]
+	if way.Id != 10 || len(way.Refs) != 2 || way.Refs[1] != 2 ||
+		way.SpeedKph() < 72 || way.SpeedKph() > 73 {
+		t.Errorf("Incorrect way: %v", way)
+	}
+	rel := bd.Rels[0]
+	if rel.Id != 20 || len(rel.Ents) != 2 ||
+		rel.Ents[1] != (RelEntry{2, NODE, "via"}) {
+		t.Errorf("Incorrect relation: %v", rel)
+	}
+	if err := m.ReadXML(strings.NewReader("<osm><node"), func(*BlockData) {}); err == nil {
+		t.Error("Read truncated XML")
+	}
+}
+
+func TestReadChange(t *testing.T) {
+	c, err := ReadChange(strings.NewReader(testOsc))
+	if err != nil {
+		t.Fatal("ReadChange: ", err)
+	}
+	if len(c.Create.Nodes) != 1 || c.Create.Nodes[0].Id != 3 {
+		t.Errorf("Incorrect create: %v", c.Create)
+	}
+	if len(c.Modify.Ways) != 1 || len(c.Modify.Ways[0].Refs) != 3 {
+		t.Errorf("Incorrect modify: %v", c.Modify)
+	}
+	if len(c.Delete.Rels) != 1 || c.Delete.Rels[0].Id != 20 {
+		t.Errorf("Incorrect delete: %v", c.Delete)
+	}
+	if _, err := ReadChange(strings.NewReader(testOsm)); err == nil {
+		t.Error("Read a map as a change")
+	}
+}
@@ -0,0 +1,71 @@
+package maps
+
+import "strings"
+
+// TurnRestriction is a restriction relation: a turn from one way to
+// another at a node.  With Only, every other turn from the way is
+// forbidden.
+type TurnRestriction struct {
+	From, Via, To int64 // Way, node, way
+	Only          bool
+}
+
+// Oneway returns 1 when a way may only be driven in the order of its
+// nodes, -1 when only in reverse, and 0 when both ways.  Motorways and
+// roundabouts are one-way unless tagged otherwise.
+func (way *Way) Oneway() int {
+	if value, has := way.Attrs.Get("oneway"); has {
+		switch value {
+		case "yes", "true", "1":
+			return 1
+		case "-1", "reverse":
+			return -1
+		}
+		return 0
+	}
+	if value, _ := way.Attrs.Get("junction"); value == "roundabout" {
+		return 1
+	}
+	switch value, _ := way.Attrs.Get("highway"); value {
+	case "motorway", "motorway_link":
+		return 1
+	}
+	return 0
+}
+
+// TurnRestriction decodes a restriction relation, using the
+// restriction:hgv tag when hgv is true.  Restrictions through a via
+// way are not supported.
+func (rel *Relation) TurnRestriction(hgv bool) (TurnRestriction, bool) {
+	var tr TurnRestriction
+	if kind, _ := rel.Attrs.Get("type"); kind != "restriction" {
+		return tr, false
+	}
+	value, has := rel.Attrs.Get("restriction")
+	if hgvValue, hasHgv := rel.Attrs.Get("restriction:hgv"); hgv && hasHgv {
+		value, has = hgvValue, true
+	}
+	switch {
+	case !has:
+		return tr, false
+	case strings.HasPrefix(value, "only_"):
+		tr.Only = true
+	case !strings.HasPrefix(value, "no_"):
+		return tr, false
+	}
+	found := 0
+	for _, e := range rel.Ents {
+		switch {
+		case e.Role == "from" && e.Type == WAY:
+			tr.From = e.Member
+		case e.Role == "via" && e.Type == NODE:
+			tr.Via = e.Member
+		case e.Role == "to" && e.Type == WAY:
+			tr.To = e.Member
+		default:
+			continue
+		}
+		found++
+	}
+	return tr, found == 3
+}
@@ -0,0 +1,44 @@
+package maps
+
+import "testing"
+
+func TestOneway(t *testing.T) {
+	for _, test := range []struct {
+		attrs  Attributes
+		expect int
+	}{
+		{Attributes{{"highway", "primary"}}, 0},
+		{Attributes{{"highway", "primary"}, {"oneway", "yes"}}, 1},
+		{Attributes{{"highway", "primary"}, {"oneway", "-1"}}, -1},
+		{Attributes{{"highway", "motorway_link"}}, 1},
+		{Attributes{{"highway", "motorway"}, {"oneway", "no"}}, 0},
+		{Attributes{{"highway", "secondary"}, {"junction", "roundabout"}}, 1},
+	} {
+		way := &Way{1, test.attrs, nil}
+		if o := way.Oneway(); o != test.expect {
+			t.Errorf("Oneway %v got %v want %v", test.attrs, o, test.expect)
+		}
+	}
+}
+
+func TestTurnRestriction(t *testing.T) {
+	ents := []RelEntry{{10, WAY, "from"}, {5, NODE, "via"}, {11, WAY, "to"}}
+	rel := &Relation{1, Attributes{{"type", "restriction"},
+		{"restriction", "no_left_turn"}}, ents}
+	if tr, ok := rel.TurnRestriction(false); !ok ||
+		tr != (TurnRestriction{10, 5, 11, false}) {
+		t.Errorf("Incorrect restriction: %v %v", tr, ok)
+	}
+	rel.Attrs = append(rel.Attrs, Attribute{"restriction:hgv", "only_straight_on"})
+	if tr, ok := rel.TurnRestriction(true); !ok || !tr.Only {
+		t.Errorf("Incorrect hgv restriction: %v %v", tr, ok)
+	}
+	rel.Ents = []RelEntry{{10, WAY, "from"}, {12, WAY, "via"}, {11, WAY, "to"}}
+	if _, ok := rel.TurnRestriction(false); ok {
+		t.Error("Accepted a via way")
+	}
+	rel.Attrs = Attributes{{"type", "multipolygon"}}
+	if _, ok := rel.TurnRestriction(false); ok {
+		t.Error("Accepted a multipolygon")
+	}
+}
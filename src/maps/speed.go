@@ -0,0 +1,76 @@
+package maps
+
+import "strconv"
+import "strings"
+
+const kphPerMph = 1.609344
+
+// DefaultKph is the typical speed on each highway class, used when a
+// way has no usable maxspeed.
+var DefaultKph = map[string]float64{
+	"motorway":       105,
+	"motorway_link":  60,
+	"trunk":          90,
+	"trunk_link":     50,
+	"primary":        80,
+	"primary_link":   45,
+	"secondary":      70,
+	"secondary_link": 40,
+	"tertiary":       55,
+	"tertiary_link":  35,
+	"unclassified":   45,
+	"residential":    35,
+	"living_street":  10,
+	"service":        20,
+	"road":           40,
+}
+
+// UnknownKph is the speed of a way with an unrecognized class.
+const UnknownKph = 40
+
+// Get returns the value of the first attribute with key.
+func (attrs Attributes) Get(key string) (string, bool) {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// ParseMaxspeed reads a maxspeed tag such as "90", "55 mph" or
+// "50;70" (the first value is used) in km/h.  Symbolic values such as
+// "none", "signals" or "US:urban" are not understood.
+func ParseMaxspeed(value string) (float64, bool) {
+	value = strings.TrimSpace(strings.SplitN(value, ";", 2)[0])
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(value, "mph"):
+		value = strings.TrimSuffix(value, "mph")
+		scale = kphPerMph
+	case strings.HasSuffix(value, "km/h"):
+		value = strings.TrimSuffix(value, "km/h")
+	case strings.HasSuffix(value, "kmh"):
+		value = strings.TrimSuffix(value, "kmh")
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || speed <= 0 {
+		return 0, false
+	}
+	return speed * scale, true
+}
+
+// SpeedKph is the travel speed on a way: its maxspeed, or else the
+// default for its highway class.
+func (way *Way) SpeedKph() float64 {
+	if v, has := way.Attrs.Get("maxspeed"); has {
+		if kph, ok := ParseMaxspeed(v); ok {
+			return kph
+		}
+	}
+	class, _ := way.Attrs.Get("highway")
+	if kph, has := DefaultKph[class]; has {
+		return kph
+	}
+	return UnknownKph
+}
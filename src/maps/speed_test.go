@@ -0,0 +1,42 @@
+package maps
+
+import "math"
+import "testing"
+
+func TestParseMaxspeed(t *testing.T) {
+	for value, expect := range map[string]float64{
+		"90":       90,
+		"55 mph":   55 * kphPerMph,
+		"65mph":    65 * kphPerMph,
+		"100 km/h": 100,
+		"50;70":    50,
+		"none":     0,
+		"US:urban": 0,
+		"signals":  0,
+		"":         0,
+		"-10":      0,
+	} {
+		kph, ok := ParseMaxspeed(value)
+		if ok != (expect != 0) || math.Abs(kph-expect) > 1e-9 {
+			t.Errorf("Maxspeed %q got %v %v want %v", value, kph, ok, expect)
+		}
+	}
+}
+
+func TestSpeedKph(t *testing.T) {
+	for _, test := range []struct {
+		attrs  Attributes
+		expect float64
+	}{
+		{Attributes{{"highway", "motorway"}}, 105},
+		{Attributes{{"highway", "motorway"}, {"maxspeed", "70 mph"}},
+			70 * kphPerMph},
+		{Attributes{{"highway", "primary"}, {"maxspeed", "none"}}, 80},
+		{Attributes{{"highway", "bridleway"}}, UnknownKph},
+	} {
+		way := &Way{1, test.attrs, nil}
+		if kph := way.SpeedKph(); math.Abs(kph-test.expect) > 1e-9 {
+			t.Errorf("Speed of %v got %v want %v", test.attrs, kph, test.expect)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package maps
+
+import "regexp"
+import "strconv"
+import "strings"
+
+const (
+	metersPerFoot  = 0.3048
+	tonnesPerPound = 0.00045359237
+	tonnesPerShort = 0.90718474
+
+	tractorFeet = 20
+	tarePounds  = 35000
+)
+
+// Vehicle is a truck's legal size, in metric units as OSM tags them.
+type Vehicle struct {
+	Name   string
+	Tonnes float64 // Gross weight
+	Height float64 // Meters
+	Length float64 // Meters, tractor and trailer
+}
+
+// Access is the restriction a way places on a vehicle.
+type Access int
+
+const (
+	Allowed   Access = iota
+	Penalized        // Only to reach a destination on the way.
+	Forbidden
+)
+
+// Vehicles are the profiles by name, at the legal maximum gross weight
+// and height.
+var Vehicles = map[string]Vehicle{
+	"dry_van_53": {"dry_van_53", 80000 * tonnesPerPound, 13.5 * metersPerFoot,
+		(53 + tractorFeet) * metersPerFoot},
+	"reefer_53": {"reefer_53", 80000 * tonnesPerPound, 13.5 * metersPerFoot,
+		(53 + tractorFeet) * metersPerFoot},
+	"flatbed_48": {"flatbed_48", 80000 * tonnesPerPound, 13.5 * metersPerFoot,
+		(48 + tractorFeet) * metersPerFoot},
+}
+
+var equipmentVehicles = []struct {
+	prefix, name string
+}{
+	{"reefer", "reefer_53"},
+	{"flat", "flatbed_48"},
+	{"step", "flatbed_48"},
+	{"van", "dry_van_53"},
+}
+
+var feetInchesRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(?:'|ft)\s*(?:(\d+(?:\.\d+)?)\s*(?:"|in))?$`)
+var numberUnitRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([a-z]*)$`)
+
+// VehicleFor returns the profile for a load's equipment, sized for
+// its cargo pounds and trailer feet when those are known.
+func VehicleFor(equipment string, pounds, feet int) Vehicle {
+	v := Vehicles["dry_van_53"]
+	lower := strings.ToLower(strings.TrimSpace(equipment))
+	for _, ev := range equipmentVehicles {
+		if strings.HasPrefix(lower, ev.prefix) {
+			v = Vehicles[ev.name]
+			break
+		}
+	}
+	if pounds > 0 {
+		v.Tonnes = float64(pounds+tarePounds) * tonnesPerPound
+	}
+	if feet > 0 {
+		v.Length = float64(feet+tractorFeet) * metersPerFoot
+	}
+	return v
+}
+
+// ParseMeters reads a maxheight or maxlength tag such as "4.1",
+// "4.1 m", "13'6\"" or "60 ft".
+func ParseMeters(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if m := feetInchesRe.FindStringSubmatch(value); m != nil {
+		feet, _ := strconv.ParseFloat(m[1], 64)
+		inches := 0.0
+		if m[2] != "" {
+			inches, _ = strconv.ParseFloat(m[2], 64)
+		}
+		return (feet + inches/12) * metersPerFoot, true
+	}
+	m := numberUnitRe.FindStringSubmatch(value)
+	if m == nil || (m[2] != "" && m[2] != "m") {
+		return 0, false
+	}
+	meters, err := strconv.ParseFloat(m[1], 64)
+	return meters, err == nil && meters > 0
+}
+
+// ParseTonnes reads a maxweight tag such as "40", "36.3 t", "40 st"
+// or "80000 lbs".
+func ParseTonnes(value string) (float64, bool) {
+	m := numberUnitRe.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, false
+	}
+	amount, err := strconv.ParseFloat(m[1], 64)
+	if err != nil || amount <= 0 {
+		return 0, false
+	}
+	switch m[2] {
+	case "", "t":
+		return amount, true
+	case "st":
+		return amount * tonnesPerShort, true
+	case "lb", "lbs":
+		return amount * tonnesPerPound, true
+	}
+	return 0, false
+}
+
+func accessOf(value string) Access {
+	switch value {
+	case "no", "private", "agricultural", "forestry":
+		return Forbidden
+	case "destination", "delivery", "customers":
+		return Penalized
+	}
+	return Allowed
+}
+
+// Access decides whether the vehicle may use a way.  An hgv tag
+// overrides access; size limits that cannot be parsed are ignored.
+func (v *Vehicle) Access(attrs Attributes) Access {
+	access := Allowed
+	if value, has := attrs.Get("access"); has {
+		access = accessOf(value)
+	}
+	if value, has := attrs.Get("hgv"); has {
+		if value == "yes" || value == "designated" {
+			access = Allowed
+		} else {
+			access = accessOf(value)
+		}
+	}
+	limits := []struct {
+		key   string
+		parse func(string) (float64, bool)
+		size  float64
+	}{
+		{"maxweight", ParseTonnes, v.Tonnes},
+		{"maxheight", ParseMeters, v.Height},
+		{"maxlength", ParseMeters, v.Length},
+	}
+	for _, l := range limits {
+		if value, has := attrs.Get(l.key); has {
+			if limit, ok := l.parse(value); ok && l.size > limit {
+				return Forbidden
+			}
+		}
+	}
+	return access
+}
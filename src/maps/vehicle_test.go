@@ -0,0 +1,64 @@
+package maps
+
+import "math"
+import "testing"
+
+func TestParseSizes(t *testing.T) {
+	for value, expect := range map[string]float64{
+		"4.1":     4.1,
+		"4.1 m":   4.1,
+		"13'6\"":  13.5 * metersPerFoot,
+		"14 ft":   14 * metersPerFoot,
+		"none":    0,
+		"default": 0,
+	} {
+		meters, ok := ParseMeters(value)
+		if ok != (expect != 0) || math.Abs(meters-expect) > 1e-9 {
+			t.Errorf("Meters %q got %v %v want %v", value, meters, ok, expect)
+		}
+	}
+	for value, expect := range map[string]float64{
+		"40":        40,
+		"36.3 t":    36.3,
+		"20 st":     20 * tonnesPerShort,
+		"80000 lbs": 80000 * tonnesPerPound,
+		"heavy":     0,
+	} {
+		tonnes, ok := ParseTonnes(value)
+		if ok != (expect != 0) || math.Abs(tonnes-expect) > 1e-9 {
+			t.Errorf("Tonnes %q got %v %v want %v", value, tonnes, ok, expect)
+		}
+	}
+}
+
+func TestVehicleAccess(t *testing.T) {
+	van := Vehicles["dry_van_53"]
+	for _, test := range []struct {
+		attrs  Attributes
+		expect Access
+	}{
+		{Attributes{{"highway", "primary"}}, Allowed},
+		{Attributes{{"hgv", "no"}}, Forbidden},
+		{Attributes{{"hgv", "destination"}}, Penalized},
+		{Attributes{{"access", "no"}, {"hgv", "yes"}}, Allowed},
+		{Attributes{{"access", "private"}}, Forbidden},
+		{Attributes{{"maxweight", "10"}}, Forbidden},
+		{Attributes{{"maxweight", "40"}}, Allowed},
+		{Attributes{{"maxheight", "12'0\""}}, Forbidden},
+		{Attributes{{"maxheight", "4.5"}}, Allowed},
+		{Attributes{{"maxlength", "18"}}, Forbidden},
+		{Attributes{{"maxheight", "default"}, {"hgv", "delivery"}}, Penalized},
+	} {
+		if a := van.Access(test.attrs); a != test.expect {
+			t.Errorf("Access %v got %v want %v", test.attrs, a, test.expect)
+		}
+	}
+	short := VehicleFor("Flatbed", 20000, 24)
+	if short.Name != "flatbed_48" || short.Access(Attributes{{"maxlength", "18"}}) != Allowed ||
+		short.Access(Attributes{{"maxweight", "20"}}) != Forbidden {
+		t.Errorf("Incorrect loaded vehicle: %v", short)
+	}
+	if v := VehicleFor("Reefer", 0, 0); v != Vehicles["reefer_53"] {
+		t.Errorf("Incorrect reefer: %v", v)
+	}
+}
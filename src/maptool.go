@@ -1,13 +1,22 @@
 package main
 
+import "compress/gzip"
+import "errors"
 import "flag"
 import "fmt"
+import "hash/crc32"
 import "io"
 import "log"
+import "math"
 import "os"
+import "path/filepath"
 import "runtime"
-import "database/sql"
+import "sort"
+import "strconv"
+import "strings"
+import "time"
 import "io/ioutil"
+import "unsafe"
 
 import "common"
 import "data"
@@ -15,11 +24,61 @@ import "geo"
 import "graph"
 import "maps"
 
-var input = flag.String("input", "", "OSM PBF formatted file")
+var input = flag.String("input", "", "OSM PBF formatted file, or XML if named .osm")
 var contraction_program = flag.String("contraction_program",
 	"../bin/contraction", "Program for computing ch-format")
 var tmp_dir = flag.String("tmp_dir",
 	"../bin/contraction", "Program for computing ch-format")
+var ch_file = flag.String("ch_file", "",
+	"Contraction hierarchy file, built from --input when missing; "+
+	"computes load distances in-process instead of writing DDSG")
+var vehicle = flag.String("vehicle", "",
+	"Truck profile whose restrictions apply: dry_van_53, reefer_53 or flatbed_48")
+var vehicle_load = flag.String("vehicle_load", "",
+	"Truck profile sized for a load, as \"Equipment:pounds:feet\" "+
+	"(e.g. \"Flatbed:45000:48\"), instead of --vehicle")
+var destination_penalty = flag.Float64("destination_penalty", 5,
+	"Weight multiplier for roads a truck may use only to reach a destination")
+var graph_file = flag.String("graph_file", "",
+	"Binary road graph, written from --input when missing or built "+
+	"with another truck profile or --turn_restrictions, "+
+	"then memory-mapped instead of reading --input")
+var changes = flag.String("changes", "",
+	"Comma-separated osmChange (.osc) files applied in order to "+
+	"--graph_file, which is then rewritten")
+var turn_restrictions = flag.Bool("turn_restrictions", true,
+	"Route on a turn-aware graph obeying OSM restriction relations")
+var metric = flag.String("metric", "meters",
+	"Route by shortest meters or fastest seconds; each needs its own --ch_file")
+
+var route = flag.String("route", "",
+	"Log the route between two cities, as \"City, ST:City, ST\", "+
+	"instead of computing load distances")
+var route_file = flag.String("route_file", "",
+	"Write the --route geometry to this .geojson, .gpx or .kml file")
+var search = flag.String("search", "astar",
+	"Search for --route: ch (needs --ch_file), bidirectional, "+
+	"dijkstra, astar or alt")
+var cross_check = flag.Bool("cross_check", false,
+	"Verify --search=bidirectional against Dijkstra's algorithm, "+
+	"failing on a mismatch")
+var landmarks_file = flag.String("landmarks_file", "",
+	"ALT landmarks for --search=alt, computed when missing "+
+	"or made for another map, --metric or truck")
+var landmarks = flag.Int("landmarks", 16, "Number of ALT landmarks")
+var alternatives = flag.Int("alternatives", 0,
+	"Log up to this many different routes for --route, written to "+
+	"--route_file numbered from 1")
+var max_overlap = flag.Float64("max_overlap", 0.7,
+	"Largest share of an alternative route on roads of a better one")
+var stretch = flag.Float64("stretch", 1.4,
+	"Largest cost of an alternative route relative to the best")
+var isochrone = flag.String("isochrone", "",
+	"Log the cities reachable from \"City, ST\" within --hours by "+
+	"--metric=seconds, instead of computing load distances")
+var hours = flag.Float64("hours", 11, "Driving time budget for --isochrone")
+var isochrone_file = flag.String("isochrone_file", "",
+	"Write the area reachable for --isochrone to this .geojson file")
 
 var highwayTypes = map[string]bool{
 	"motorway":       true,
@@ -43,7 +102,8 @@ type mapId int64
 
 type mapCount struct {
 	id graph.NodeId
-	ec uint32  // edge count
+	ec uint32  // outgoing edge count
+	ic uint32  // incoming edge count
 }
 
 type mapData1 struct {
@@ -52,19 +112,41 @@ type mapData1 struct {
 	// 32-bit count of outgoing edges)
 	mapIds     map[mapId]mapCount
 	nextNodeId graph.NodeId
-	totalEdges uint32
+	totalEdges uint32  // directed
+
+	// Restriction relations, and the nodes of their from and to
+	// ways, filled-in by the second pass.
+	restrictions []maps.TurnRestriction
+	restrictionRels []int64
+	restrictWays map[int64][]int64
 }
 
+// node has a fixed size, without pointers, so that it can be
+// memory-mapped from a graph file.
 type node struct {
 	id                  graph.NodeId
 	position            [3]geo.EarthLoc
 	treeLeft, treeRight graph.NodeId
-	neighbors           []graph.NodeId
 }
 
+type edgeAttr struct {
+	kph float32
+	factor float32  // Weight multiplier for restricted roads
+}
+
+// mapData2 holds the graph in compressed sparse row form: the edges
+// leaving node n are edges[firstOut[n]:firstOut[n+1]], and similarly
+// the edges entering it in preds.  The OSM ids of nodes and of the
+// ways of edges are kept for applying changes.
 type mapData2 struct {
 	nodes []node
+	firstOut []uint32
 	edges []graph.NodeId
+	attrs []edgeAttr
+	firstIn []uint32
+	preds []graph.NodeId
+	osmIds []int64
+	ways []int64
 }
 
 type nodeDist struct {
@@ -77,7 +159,15 @@ type mapTool struct {
 	loc2node map[common.CityState]nodeDist
 	tree *geo.Tree
 	data *mapData2
+	ch *graph.CH
+	graphFile *common.ArrayFile
+	metric graph.Metric
+	turns []graph.Restriction
+	turnRels []int64  // The relation of each turn
+	routes graph.Graph  // The graph routes are computed on
+	turnGraph *graph.TurnGraph  // The routes graph, when turn-aware
 	input *mapData1
+	changed bool  // Changes were applied to the graph file
 }
 
 type cityNode struct {
@@ -90,12 +180,43 @@ type cityPair struct {
 	fromNodeD, toNodeD nodeDist
 }
 
-type cityDist struct {
-	from, to common.CityState
-	meters int
+// truck is the --vehicle or --vehicle_load profile, or nil.
+var truck *maps.Vehicle
+
+// parseVehicleLoad returns the profile for a load's "Equipment:pounds:feet",
+// where pounds and feet may be empty when unknown.
+func parseVehicleLoad(spec string) (maps.Vehicle, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return maps.Vehicle{}, errors.New("Incorrect --vehicle_load: " + spec)
+	}
+	var sizes [2]int
+	for i, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return maps.Vehicle{}, errors.New("Incorrect --vehicle_load: " + spec)
+		}
+		sizes[i] = n
+	}
+	return maps.VehicleFor(parts[0], sizes[0], sizes[1]), nil
+}
+
+// truckTag describes the truck profile, empty without one.
+func truckTag() string {
+	if truck == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v/%.1ft/%.1fm/%.1fm",
+		truck.Name, truck.Tonnes, truck.Height, truck.Length)
 }
 
 func keepWay(way *maps.Way) bool {
+	if truck != nil && truck.Access(way.Attrs) == maps.Forbidden {
+		return false
+	}
 	for _, a := range way.Attrs {
 		if a.Key == "highway" {
 			if yes, has := highwayTypes[a.Value]; has {
@@ -115,42 +236,95 @@ func (md *mapData1) mapPass1(bd *maps.BlockData) {
 		if len(way.Refs) < 2 {
 			continue
 		}
-		md.totalEdges += uint32(len(way.Refs)) - 1
-		for e, ref := range way.Refs {
-			var edges uint32
-			if e == 0 || e == (len(way.Refs)-1) {
-				edges = 1
-			} else {
-				edges = 2
+		for _, ref := range way.Refs {
+			if _, has := md.mapIds[mapId(ref)]; !has {
+				md.mapIds[mapId(ref)] = mapCount{md.nextNodeId, 0, 0}
+				md.nextNodeId++
 			}
-
-			value, has := md.mapIds[mapId(ref)]
-			if has {
-				value.ec += edges
-				md.mapIds[mapId(ref)] = value
-				continue
+		}
+		oneway := way.Oneway()
+		for e := 1; e < len(way.Refs); e++ {
+			if oneway >= 0 {
+				md.countEdge(way.Refs[e-1], way.Refs[e])
 			}
-
-			md.mapIds[mapId(ref)] = mapCount{md.nextNodeId, edges}
-			md.nextNodeId++
+			if oneway <= 0 {
+				md.countEdge(way.Refs[e], way.Refs[e-1])
+			}
+		}
+	}
+	for r := 0; r < len(bd.Rels); r++ {
+		tr, ok := bd.Rels[r].TurnRestriction(truck != nil)
+		if !ok || !*turn_restrictions {
+			continue
 		}
+		md.restrictions = append(md.restrictions, tr)
+		md.restrictionRels = append(md.restrictionRels, bd.Rels[r].Id)
+		md.restrictWays[tr.From] = nil
+		md.restrictWays[tr.To] = nil
 	}
 }
 
-func (md *mapData2) addEdge(n0 *node, v1 graph.NodeId) {
-	for i, neighbor := range n0.neighbors {
-		if neighbor != graph.ZeroNodeId {
-			continue
+func (md *mapData1) countEdge(from, to int64) {
+	mc := md.mapIds[mapId(from)]
+	mc.ec++
+	md.mapIds[mapId(from)] = mc
+	mc = md.mapIds[mapId(to)]
+	mc.ic++
+	md.mapIds[mapId(to)] = mc
+	md.totalEdges++
+}
+
+// adjacent returns the node next to via at an end of way.
+func (md *mapData1) adjacent(way, via int64) (graph.NodeId, bool) {
+	refs := md.restrictWays[way]
+	var ref int64
+	switch {
+	case len(refs) < 2:
+		return graph.ZeroNodeId, false
+	case refs[0] == via:
+		ref = refs[1]
+	case refs[len(refs)-1] == via:
+		ref = refs[len(refs)-2]
+	default:
+		return graph.ZeroNodeId, false
+	}
+	mc, has := md.mapIds[mapId(ref)]
+	return mc.id, has
+}
+
+// turns resolves the restriction relations into turns between nodes,
+// skipping those on ways that were not kept, and returns the relation
+// of each.
+func (md *mapData1) turns() ([]graph.Restriction, []int64) {
+	var rs []graph.Restriction
+	var rels []int64
+	for i, tr := range md.restrictions {
+		via, has := md.mapIds[mapId(tr.Via)]
+		from, hasFrom := md.adjacent(tr.From, tr.Via)
+		to, hasTo := md.adjacent(tr.To, tr.Via)
+		if has && hasFrom && hasTo {
+			rs = append(rs, graph.Restriction{
+				graph.Turn{from, via.id, to}, tr.Only})
+			rels = append(rels, md.restrictionRels[i])
 		}
-		n0.neighbors[i] = v1
-		return
 	}
-	panic("Invalid edge count")
+	return rs, rels
 }
 
-func (md *mapData2) addEdges(v0, v1 graph.NodeId) {
-	md.addEdge(&md.nodes[v0], v1)
-	md.addEdge(&md.nodes[v1], v0)
+func (md *mapData2) addArc(v0, v1 graph.NodeId, attr edgeAttr, way int64) {
+	ei := md.firstOut[v0]
+	for ; ei < md.firstOut[v0+1] && md.edges[ei] != graph.ZeroNodeId; ei++ {
+	}
+	pi := md.firstIn[v1]
+	for ; pi < md.firstIn[v1+1] && md.preds[pi] != graph.ZeroNodeId; pi++ {
+	}
+	if ei == md.firstOut[v0+1] || pi == md.firstIn[v1+1] {
+		panic("Invalid edge count")
+	}
+	md.edges[ei] = v1
+	md.attrs[ei] = attr
+	md.ways[ei] = way
+	md.preds[pi] = v0
 }
 
 func (md *mapData2) mapPass2(bd *maps.BlockData, md1 *mapData1) {
@@ -162,6 +336,7 @@ func (md *mapData2) mapPass2(bd *maps.BlockData, md1 *mapData1) {
 		}
 		mn := &md.nodes[mc.id]
 		mn.id = mc.id
+		md.osmIds[mc.id] = mapnode.Id
 		geo.SphereCoords{Lat: mapnode.Lat, Long: mapnode.Long}.
 			ToCoords(mn.position[:])
 	}
@@ -173,17 +348,36 @@ func (md *mapData2) mapPass2(bd *maps.BlockData, md1 *mapData1) {
 		if len(way.Refs) < 2 {
 			continue
 		}
+		if _, has := md1.restrictWays[way.Id]; has {
+			md1.restrictWays[way.Id] = way.Refs
+		}
+		oneway := way.Oneway()
+		attr := wayAttr(way)
 		for e := 1; e < len(way.Refs); e++ {
 			mc0, has0 := md1.mapIds[mapId(way.Refs[e-1])]
 			mc1, has1 := md1.mapIds[mapId(way.Refs[e])]
 			if !has0 || !has1 {
 				panic("Corrupted mapIds?")
 			}
-			md.addEdges(mc0.id, mc1.id)
+			if oneway >= 0 {
+				md.addArc(mc0.id, mc1.id, attr, way.Id)
+			}
+			if oneway <= 0 {
+				md.addArc(mc1.id, mc0.id, attr, way.Id)
+			}
 		}
 	}
 }
 
+// wayAttr returns the attributes of the edges of a kept way.
+func wayAttr(way *maps.Way) edgeAttr {
+	attr := edgeAttr{float32(way.SpeedKph()), 1}
+	if truck != nil && truck.Access(way.Attrs) == maps.Penalized {
+		attr.factor = float32(*destination_penalty)
+	}
+	return attr
+}
+
 func readInput() io.Reader {
 	file, err := os.Open(*input)
 	if err != nil {
@@ -193,29 +387,46 @@ func readInput() io.Reader {
 	return file
 }
 
+// readOsm passes the blocks of --input to bf, read as XML or PBF by
+// its name.
+func readOsm(osm *maps.Map, bf func(*maps.BlockData)) error {
+	if strings.HasSuffix(*input, ".osm") {
+		return osm.ReadXML(readInput(), bf)
+	}
+	return osm.ReadMap(readInput(), bf)
+}
+
 func newMapData1() *mapData1 {
 	return &mapData1{
 		mapIds:     make(map[mapId]mapCount),
 		nextNodeId: graph.FirstNodeId,
 		totalEdges: 0,
+		restrictWays: make(map[int64][]int64),
 	}
 }
 
 func newMapData2(md1 *mapData1) *mapData2 {
 	md2 := &mapData2{
 		make([]node, md1.nextNodeId),
-		make([]graph.NodeId, md1.totalEdges*2),
+		make([]uint32, md1.nextNodeId+1),
+		make([]graph.NodeId, md1.totalEdges),
+		make([]edgeAttr, md1.totalEdges),
+		make([]uint32, md1.nextNodeId+1),
+		make([]graph.NodeId, md1.totalEdges),
+		make([]int64, md1.nextNodeId),
+		make([]int64, md1.totalEdges),
 	}
-	ei := 0
-	c := 0
 	for _, mc := range md1.mapIds {
-		np := &md2.nodes[mc.id]
-		np.neighbors = md2.edges[ei : ei+int(mc.ec)]
-		ei += int(mc.ec)
-		c++
+		md2.firstOut[mc.id+1] = mc.ec
+		md2.firstIn[mc.id+1] = mc.ic
 	}
-	if ei != len(md2.edges) {
-		panic(fmt.Sprintln("Incorrect edge count", ei, len(md2.edges)))
+	for i := 1; i < len(md2.firstOut); i++ {
+		md2.firstOut[i] += md2.firstOut[i-1]
+		md2.firstIn[i] += md2.firstIn[i-1]
+	}
+	ei, pi := md2.firstOut[md1.nextNodeId], md2.firstIn[md1.nextNodeId]
+	if ei != md1.totalEdges || pi != md1.totalEdges {
+		panic(fmt.Sprintln("Incorrect edge count", ei, pi, md1.totalEdges))
 	}
 	return md2
 }
@@ -224,7 +435,7 @@ func main() {
 	data.Main(programBody)
 }
 
-func programBody(db *sql.DB) error {
+func programBody(db data.Storage) error {
 	var mt mapTool
 	cd, err := data.NewConvoyData(db)
 	if err != nil {
@@ -232,11 +443,89 @@ func programBody(db *sql.DB) error {
 	}
 	mt.ConvoyData = *cd
 	mt.loc2node = make(map[common.CityState]nodeDist)
+	if mt.metric, err = graph.ParseMetric(*metric); err != nil {
+		return err
+	}
+	if *vehicle != "" {
+		v, has := maps.Vehicles[*vehicle]
+		if !has {
+			return errors.New("Unknown vehicle: " + *vehicle)
+		}
+		truck = &v
+	}
+	if *vehicle_load != "" {
+		if truck != nil {
+			return errors.New("Give --vehicle or --vehicle_load, not both")
+		}
+		v, err := parseVehicleLoad(*vehicle_load)
+		if err != nil {
+			return err
+		}
+		truck = &v
+	}
+
+	if *graph_file != "" {
+		if err := mt.readGraph(*graph_file); err != nil &&
+			!os.IsNotExist(err) {
+			return err
+		}
+	}
+	if *changes != "" {
+		if mt.data == nil {
+			return errors.New("--changes requires an existing --graph_file")
+		}
+		for _, name := range strings.Split(*changes, ",") {
+			if err := mt.applyChange(name); err != nil {
+				return err
+			}
+		}
+	}
+	if mt.data == nil || mt.changed {
+		if mt.data == nil {
+			if err := mt.readMap(); err != nil {
+				return err
+			}
+		}
+		if *graph_file != "" {
+			if err := mt.writeGraph(*graph_file); err != nil {
+				return err
+			}
+			log.Println("Wrote graph file:", *graph_file)
+		}
+	}
+	mt.setRouting()
+
+	if err := mt.findCityNodes(); err != nil {
+		return err
+	}		
 
+	if *route != "" {
+		return mt.routeCities(*route)
+	}
+	if *isochrone != "" {
+		return mt.reachable(*isochrone)
+	}
+	if *ch_file != "" {
+		if err := mt.loadHierarchy(*ch_file); err != nil {
+			return err
+		}
+		return mt.findCityDistances()
+	}
+
+	ddsgName, err := mt.writeDdsg()
+	if err != nil {
+		return err
+	}
+	log.Println("Wrote ddsg file:", ddsgName)
+	return nil
+}
+
+// readMap builds the graph from the --input OSM file.
+func (mt *mapTool) readMap() error {
 	osm := maps.NewMap()
 
 	md1 := newMapData1()
-	if err := osm.ReadMap(readInput(), func(bd *maps.BlockData) {
+	if err := readOsm(osm, func(bd *maps.BlockData) {
 		md1.mapPass1(bd)
 	}); err != nil {
 		return err
@@ -246,7 +535,7 @@ func programBody(db *sql.DB) error {
 		md1.totalEdges, "edges")
 
 	md2 := newMapData2(md1)
-	if err := osm.ReadMap(readInput(), func(bd *maps.BlockData) {
+	if err := readOsm(osm, func(bd *maps.BlockData) {
 		md2.mapPass2(bd, md1)
 	}); err != nil {
 		return err
@@ -261,24 +550,13 @@ func programBody(db *sql.DB) error {
 		}
 	}
 	mt.data = md2
+	mt.turns, mt.turnRels = md1.turns()
+	log.Println("Using", len(mt.turns), "of", len(md1.restrictions),
+		"turn restrictions")
 	mt.tree = geo.NewTree(md2)
 	mt.tree.Build()
 	log.Println("Built geospatial tree")
 	common.PrintMem()
-
-	if err := mt.findCityNodes(); err != nil {
-		return err
-	}		
-
-	// if err := mt.findCityDistances(); err != nil {
-	// 	return err
-	// }
-
-	ddsgName, err := mt.writeDdsg()
-	if err != nil {
-		return err
-	}
-	log.Println("Wrote ddsg file:", ddsgName)
 	return nil
 }
 
@@ -326,11 +604,15 @@ func (md *mapData2) Node(i int) geo.Vertex {
 }
 
 func (md *mapData2) Edges() int {
-	return len(md.edges) / 2
+	return len(md.edges)
 }
 
 func (md *mapData2) Neighbors(n graph.NodeId) []graph.NodeId {
-	return md.nodes[n].neighbors
+	return md.edges[md.firstOut[n]:md.firstOut[n+1]]
+}
+
+func (md *mapData2) Predecessors(n graph.NodeId) []graph.NodeId {
+	return md.preds[md.firstIn[n]:md.firstIn[n+1]]
 }
 
 func (md *mapData2) Weight(from, to graph.NodeId) float64 {
@@ -338,6 +620,26 @@ func (md *mapData2) Weight(from, to graph.NodeId) float64 {
 		md.nodes[from].position[:], md.nodes[to].position[:])
 }
 
+// MetricWeight returns meters, or seconds at the way's speed, times
+// the penalty for restricted roads.  Of ways joining the same nodes,
+// the lightest counts; nodes not joined are infinitely far apart.
+func (md *mapData2) MetricWeight(m graph.Metric, from, to graph.NodeId) float64 {
+	meters := md.Weight(from, to)
+	best := math.Inf(1)
+	for i, n := range md.Neighbors(from) {
+		if n != to {
+			continue
+		}
+		attr := md.attrs[int(md.firstOut[from])+i]
+		weight := meters
+		if m == graph.Seconds {
+			weight /= float64(attr.kph) / 3.6
+		}
+		best = math.Min(best, weight * float64(attr.factor))
+	}
+	return best
+}
+
 func (mt *mapTool) locateCity(csl geo.CityStateLoc) nodeDist {
 	var coords [3]geo.EarthLoc
 	csl.SphereCoords.ToCoords(coords[:])
@@ -382,12 +684,410 @@ func (mt *mapTool) findCityNodes() error {
 	return nil
 }
 
-func (mt *mapTool) shortestPath(csp cityPair) int {
-	nodes := graph.ShortestPath(mt.data, csp.fromNodeD.id, csp.toNodeD.id)
-	var dist float64
+// loadHierarchy reads the contraction hierarchy for the map from
+// name, or contracts the map and saves it there.  A hierarchy for
+// another map, metric or truck, including the map before changes, is
+// rebuilt.
+func (mt *mapTool) loadHierarchy(name string) error {
+	g := mt.routing()
+	tag := mt.routingTag()
+	if f, err := os.Open(name); err == nil {
+		ch, err := graph.ReadCH(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if ch.Tag != tag {
+			log.Printf("Rebuilding contraction hierarchy for %v, not %v: %v",
+				tag, ch.Tag, name)
+		} else if ch.Count() != g.Count() {
+			return errors.New(fmt.Sprint("Hierarchy has ", ch.Count(),
+				" nodes, map has ", g.Count(), ": ", name))
+		} else {
+			mt.ch = ch
+			log.Println("Read contraction hierarchy:", name)
+			return nil
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	mt.ch = graph.Contract(g)
+	mt.ch.Tag = tag
+	log.Println("Contracted", mt.ch.Count(), "nodes by", mt.metric)
+	common.PrintMem()
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := mt.ch.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	log.Println("Wrote contraction hierarchy:", name)
+	return f.Close()
+}
+
+// setRouting builds the graph that routes are computed on, once the
+// map is loaded and changed: the map by metric, turn-aware when it has
+// restrictions.
+func (mt *mapTool) setRouting() {
+	mt.routes = graph.ByMetric(mt.data, mt.metric)
+	mt.turnGraph = nil
+	if len(mt.turns) != 0 {
+		mt.turnGraph = graph.NewTurnGraph(mt.routes, mt.turns)
+		mt.routes = mt.turnGraph
+	}
+}
+
+// routing returns the graph that routes are computed on.
+func (mt *mapTool) routing() graph.Graph {
+	return mt.routes
+}
+
+// source and sink map nodes of the map to the routing graph.
+func (mt *mapTool) source(n graph.NodeId) graph.NodeId {
+	if mt.turnGraph == nil {
+		return n
+	}
+	return mt.turnGraph.Source(n)
+}
+
+func (mt *mapTool) sink(n graph.NodeId) graph.NodeId {
+	if mt.turnGraph == nil {
+		return n
+	}
+	return mt.turnGraph.Sink(n)
+}
+
+// basePath maps a path in the routing graph to map nodes.
+func (mt *mapTool) basePath(nodes []graph.NodeId) []graph.NodeId {
+	if mt.turnGraph == nil {
+		return nodes
+	}
+	return mt.turnGraph.BasePath(nodes)
+}
+
+// route returns the map nodes of the best route between two nodes.
+func (mt *mapTool) route(from, to graph.NodeId) []graph.NodeId {
+	return mt.basePath(mt.ch.ShortestPath(mt.source(from), mt.sink(to)))
+}
+
+// greatCircle bounds the weight between nodes of the routing graph by
+// the great-circle distance at the lowest weight per meter of any edge.
+type greatCircle struct {
+	md *mapData2
+	tg *graph.TurnGraph
+	rate float64
+}
+
+func (mt *mapTool) newGreatCircle() *greatCircle {
+	rate := math.Inf(1)
+	for _, attr := range mt.data.attrs {
+		r := float64(attr.factor)
+		if mt.metric == graph.Seconds {
+			r *= 3.6 / float64(attr.kph)
+		}
+		rate = math.Min(rate, r)
+	}
+	if math.IsInf(rate, 1) {
+		rate = 0
+	}
+	// Allow for rounding in the edge weights.
+	return &greatCircle{mt.data, mt.turnGraph, rate * (1 - 1e-9)}
+}
+
+func (gc *greatCircle) Bound(from, to graph.NodeId) float64 {
+	if gc.tg != nil {
+		from, to = gc.tg.Base(from), gc.tg.Base(to)
+	}
+	return gc.rate * geo.GreatCircleDistance(
+		gc.md.nodes[from].Point(), gc.md.nodes[to].Point())
+}
+
+// loadLandmarks reads the ALT landmarks for the routing graph g from
+// name, or computes them and saves them there.  Landmarks for another
+// map, metric or truck would bound routes wrongly, so are recomputed.
+func (mt *mapTool) loadLandmarks(g graph.Graph, name string) (*graph.Landmarks, error) {
+	tag := mt.routingTag()
+	if f, err := os.Open(name); err == nil {
+		l, err := graph.ReadLandmarks(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if l.Tag != tag {
+			log.Printf("Recomputing landmarks for %v, not %v: %v",
+				tag, l.Tag, name)
+		} else if l.Count() != g.Count() {
+			return nil, errors.New(fmt.Sprint("Landmarks have ", l.Count(),
+				" nodes, map has ", g.Count(), ": ", name))
+		} else {
+			log.Println("Read landmarks:", name)
+			return l, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	l := graph.NewLandmarks(g, *landmarks)
+	l.Tag = tag
+	log.Println("Chose", len(l.Nodes()), "landmarks by", mt.metric)
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.Write(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	log.Println("Wrote landmarks:", name)
+	return l, f.Close()
+}
+
+// routeCities logs the route between the two cities of spec, found by
+// --search.
+func (mt *mapTool) routeCities(spec string) error {
+	names := strings.Split(spec, ":")
+	if len(names) != 2 {
+		return errors.New("Incorrect --route: " + spec)
+	}
+	var ends [2]nodeDist
+	for i, name := range names {
+		cs := common.ParseCityState(name)
+		nd, has := mt.loc2node[cs]
+		if !has {
+			return errors.New("Unknown city: " + name)
+		}
+		ends[i] = nd
+	}
+	if *alternatives > 0 {
+		return mt.alternativeRoutes(names, ends)
+	}
+	var nodes []graph.NodeId
+	settled := 0
+	var elapsed time.Duration
+	if *search == "ch" {
+		if *ch_file == "" {
+			return errors.New("--search=ch needs --ch_file")
+		}
+		if err := mt.loadHierarchy(*ch_file); err != nil {
+			return err
+		}
+		start := time.Now()
+		nodes = mt.route(ends[0].id, ends[1].id)
+		elapsed = time.Since(start)
+	} else if *search == "bidirectional" {
+		g := mt.routing()
+		start := time.Now()
+		p := graph.FindPath(g, mt.source(ends[0].id), mt.sink(ends[1].id))
+		elapsed = time.Since(start)
+		if *cross_check {
+			err := p.Check(g, mt.source(ends[0].id), mt.sink(ends[1].id))
+			if err != nil {
+				return err
+			}
+		}
+		nodes = mt.basePath(p.Nodes)
+		settled = p.Settled[0] + p.Settled[1]
+		log.Printf("Searches met at %v, costing %.1f %v",
+			p.Meeting, p.Cost, mt.metric)
+	} else {
+		g := mt.routing()
+		var h graph.Heuristic
+		switch *search {
+		case "dijkstra":
+		case "astar":
+			h = mt.newGreatCircle()
+		case "alt":
+			if *landmarks_file == "" {
+				return errors.New("--search=alt needs --landmarks_file")
+			}
+			l, err := mt.loadLandmarks(g, *landmarks_file)
+			if err != nil {
+				return err
+			}
+			h = l
+		default:
+			return errors.New("Unknown search: " + *search)
+		}
+		r := graph.NewRouter(g)
+		start := time.Now()
+		nodes = r.ShortestPath(mt.source(ends[0].id), mt.sink(ends[1].id), h)
+		elapsed = time.Since(start)
+		settled = r.Settled
+		nodes = mt.basePath(nodes)
+	}
+	if nodes == nil {
+		log.Printf("%v -> %v not connected", names[0], names[1])
+		return nil
+	}
+	dist := ends[0].dist + ends[1].dist
 	for i := 0; i < len(nodes) - 1; i++ {
 		dist += mt.data.Weight(nodes[i], nodes[i+1])
 	}
+	log.Printf("%v -> %v = %.1fkm, %d nodes, by %v in %v settling %d",
+		names[0], names[1], dist / 1000.0, len(nodes), *search,
+		elapsed, settled)
+	if *route_file == "" {
+		return nil
+	}
+	if err := mt.routeGeometry(names[0] + " -> " + names[1], nodes).
+		WriteFile(*route_file); err != nil {
+		return err
+	}
+	log.Println("Wrote route:", *route_file)
+	return nil
+}
+
+// alternativeRoutes logs up to --alternatives routes between ends,
+// each with its overlap with the better routes.
+func (mt *mapTool) alternativeRoutes(names []string, ends [2]nodeDist) error {
+	start := time.Now()
+	routes := graph.Alternatives(mt.routing(), mt.source(ends[0].id),
+		mt.sink(ends[1].id), *alternatives, *max_overlap, *stretch)
+	log.Printf("%v -> %v has %d routes, found in %v",
+		names[0], names[1], len(routes), time.Since(start))
+	for i, r := range routes {
+		nodes := mt.basePath(r.Nodes)
+		dist := ends[0].dist + ends[1].dist
+		for j := 0; j < len(nodes) - 1; j++ {
+			dist += mt.data.Weight(nodes[j], nodes[j+1])
+		}
+		log.Printf("Route %d = %.1fkm, costing %.1f %v, overlaps %.2f",
+			i + 1, dist / 1000.0, r.Cost, mt.metric, r.Overlap[:i])
+		if *route_file == "" {
+			continue
+		}
+		ext := filepath.Ext(*route_file)
+		file := fmt.Sprintf("%v-%d%v",
+			strings.TrimSuffix(*route_file, ext), i + 1, ext)
+		if err := mt.routeGeometry(fmt.Sprintf("%v -> %v #%d",
+			names[0], names[1], i + 1), nodes).WriteFile(file); err != nil {
+			return err
+		}
+		log.Println("Wrote route:", file)
+	}
+	return nil
+}
+
+// routeGeometry returns the positions of the map nodes of a route.
+func (mt *mapTool) routeGeometry(name string, nodes []graph.NodeId) *geo.Route {
+	r := &geo.Route{Name: name, Points: make([]geo.SphereCoords, len(nodes))}
+	for i, n := range nodes {
+		r.Points[i] = mt.data.nodes[n].Point().ToSphereCoords()
+	}
+	return r
+}
+
+// hullCells is the number of grid cells across the reachable nodes
+// kept for the hull, and hullNeighbors the neighbors it starts from.
+const (
+	hullCells = 64
+	hullNeighbors = 10
+)
+
+type cityHours struct {
+	cs common.CityState
+	hours float64
+}
+
+type byHours []cityHours
+
+func (b byHours) Len() int { return len(b) }
+func (b byHours) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byHours) Less(i, j int) bool { return b[i].hours < b[j].hours }
+
+// reachable logs the cities whose nodes can be reached from name
+// within --hours, and writes the outline of the reachable nodes to
+// --isochrone_file.
+func (mt *mapTool) reachable(name string) error {
+	if mt.metric != graph.Seconds {
+		return errors.New("--isochrone needs --metric=seconds")
+	}
+	nd, has := mt.loc2node[common.ParseCityState(name)]
+	if !has {
+		return errors.New("Unknown city: " + name)
+	}
+	start := time.Now()
+	reach := graph.Within(mt.routing(), mt.source(nd.id), *hours * 3600)
+	elapsed := time.Since(start)
+	// Reached in order of cost, so the first visit to a map node is
+	// the cheapest.
+	cost := make(map[graph.NodeId]float64)
+	var points []geo.SphereCoords
+	for _, r := range reach {
+		n := r.Node
+		if mt.turnGraph != nil {
+			n = mt.turnGraph.Base(n)
+		}
+		if _, has := cost[n]; !has {
+			cost[n] = r.Cost
+			points = append(points, mt.data.nodes[n].Point().ToSphereCoords())
+		}
+	}
+	var cities []cityHours
+	for cs, cnd := range mt.loc2node {
+		if c, has := cost[cnd.id]; has {
+			cities = append(cities, cityHours{cs, c / 3600})
+		}
+	}
+	sort.Sort(byHours(cities))
+	for _, c := range cities {
+		log.Printf("%v reaches %v in %.1fh", name, c.cs, c.hours)
+	}
+	log.Printf("%v reaches %d nodes and %d cities within %vh in %v",
+		name, len(cost), len(cities), *hours, elapsed)
+	if *isochrone_file == "" {
+		return nil
+	}
+	return mt.writeArea(fmt.Sprintf("Within %vh of %v", *hours, name),
+		points, *isochrone_file)
+}
+
+// writeArea writes the concave hull of points as GeoJSON.
+func (mt *mapTool) writeArea(name string, points []geo.SphereCoords,
+	file string) error {
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLong, maxLong := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		minLat, maxLat = math.Min(minLat, p.Lat), math.Max(maxLat, p.Lat)
+		minLong, maxLong = math.Min(minLong, p.Long), math.Max(maxLong, p.Long)
+	}
+	if span := math.Max(maxLat - minLat, maxLong - minLong); span > 0 {
+		points = geo.Thin(points, span / hullCells)
+	}
+	ring, err := geo.ConcaveHull(points, hullNeighbors)
+	if err != nil {
+		return errors.New(fmt.Sprint(err, ": ", name))
+	}
+	area := geo.Area{name, ring}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := area.WriteGeoJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	log.Printf("Wrote %d point outline: %v", len(area.Ring), file)
+	return f.Close()
+}
+
+// pairDistance returns the road meters between a city pair from the
+// matrix, or -1 when they are not connected.  When routing by seconds
+// or with penalties the meters are those of the chosen path.
+func (mt *mapTool) pairDistance(csp cityPair, m *graph.Matrix,
+	rows, cols map[graph.NodeId]int) int {
+	cost, dist, edges, ok := m.Get(rows[csp.fromNodeD.id], cols[csp.toNodeD.id])
+	if !ok {
+		log.Printf("%v -> %v not connected", csp.from, csp.to)
+		return -1
+	}
+	if mt.turnGraph != nil {
+		edges--  // Arriving at the sink
+	}
+	if mt.metric == graph.Seconds || truck != nil {
+		log.Printf("%v -> %v = %.2f %v", csp.from, csp.to, cost, mt.metric)
+	}
 	dist += csp.fromNodeD.dist
 	dist += csp.toNodeD.dist
 	fromP := mt.data.nodes[csp.fromNodeD.id].Point()
@@ -395,32 +1095,17 @@ func (mt *mapTool) shortestPath(csp cityPair) int {
 	log.Printf("%v -> %v = %.1fkm (%.1f%%) %d segments",
 		csp.from, csp.to, dist / 1000.0, 
 		100.0 * (float64(dist) / geo.GreatCircleDistance(fromP, toP)),
-		len(nodes))
+		edges)
 	return int(dist)
 }
 
+// findCityDistances computes the missing load-pair distances with one
+// many-to-many search and adds them in bulk.
 func (mt *mapTool) findCityDistances() error {
-	cpus := runtime.NumCPU()
-	ch1 := make(chan cityPair, cpus)
-	ch2 := make(chan cityDist, cpus)
-	ch3 := make(chan bool, cpus)
-	for i := 0; i < cpus; i++ {
-		go func() {
-			for csp := range ch1 {
-				ch2 <- cityDist{csp.from, csp.to, mt.shortestPath(csp)}
-			}
-			ch3 <- true
-		}()
-	}
-	go func() {
-		for csd := range ch2 {
-			if err := mt.AddRoadDistance(csd.from, csd.to, csd.meters / 1000); err != nil {
-				log.Println("AddRoadDistance", csd.from, csd.to,
-					"failed:", err)
-			}
-		}
-		ch3 <- true
-	}()
+	var pairs []cityPair
+	rows := make(map[graph.NodeId]int)
+	cols := make(map[graph.NodeId]int)
+	var sources, targets []graph.NodeId
 	if err := mt.ForAllLoadPairsMissingDistance(
 		func (from, to geo.CityStateLoc) error {
 
@@ -431,18 +1116,32 @@ func (mt *mapTool) findCityDistances() error {
 			log.Println("Missing a location:", from, to)
 			return nil
 		}
-
-		ch1 <- cityPair{from.CityState, to.CityState, fromNodeD, toNodeD}
+		if _, has := rows[fromNodeD.id]; !has {
+			rows[fromNodeD.id] = len(sources)
+			sources = append(sources, mt.source(fromNodeD.id))
+		}
+		if _, has := cols[toNodeD.id]; !has {
+			cols[toNodeD.id] = len(targets)
+			targets = append(targets, mt.sink(toNodeD.id))
+		}
+		pairs = append(pairs, cityPair{from.CityState, to.CityState, fromNodeD, toNodeD})
 		return nil
 	}); err != nil {
 		return err
 	}
-	close(ch1)
-	for i := 0; i < cpus; i++ {
-		<- ch3
+	log.Println("Computing", len(sources), "x", len(targets),
+		"distances for", len(pairs), "load pairs")
+	m := mt.ch.DistanceMatrix(sources, targets)
+	for _, csp := range pairs {
+		meters := mt.pairDistance(csp, m, rows, cols)
+		if meters < 0 {
+			continue
+		}
+		if err := mt.AddRoadDistance(csp.from, csp.to, meters / 1000); err != nil {
+			log.Println("AddRoadDistance", csp.from, csp.to,
+				"failed:", err)
+		}
 	}
-	close(ch2)
-	<- ch3
 	return nil
 }
 
@@ -455,13 +1154,23 @@ func (mt *mapTool) writeDdsg() (string, error) {
 		keep[nd.id] = true
 	}
 	for i := graph.FirstNodeId; i < graph.NodeId(len(mt.data.nodes)); i++ {
-		if len(mt.data.nodes[i].neighbors) > 2 {
+		if len(mt.data.Neighbors(i)) + len(mt.data.Predecessors(i)) > 4 {
 			keep[i] = true
 		}
 	}
 	log.Println("Condensed graph keep", len(keep), "nodes")
-	edges := graph.Condense(mt.data, func (n graph.NodeId) bool {
-		return keep[n]
+	// DDSG has no turns, so restrictions are kept by condensing the
+	// turn-aware graph, whose nodes are edges of the map.
+	var g graph.Graph = mt.data
+	base := func (n graph.NodeId) graph.NodeId { return n }
+	if len(mt.turns) != 0 {
+		tg := graph.NewTurnGraph(mt.data, mt.turns)
+		g, base = tg, tg.Base
+		log.Println("Condensing turns for", len(mt.turns),
+			"turn restrictions")
+	}
+	edges := graph.Condense(g, func (n graph.NodeId) bool {
+		return keep[base(n)]
 	})
 	condensed := graph.EdgelistToGraph(edges)
 	cedges := graph.GraphToEdgelist(condensed)
@@ -475,3 +1184,474 @@ func (mt *mapTool) writeDdsg() (string, error) {
 	}
 	return name, nil
 }
+
+// The graph file holds mapData2's arrays, the turn restrictions and the
+// kd-tree root, then the OSM ids of nodes, edges and turns, and the
+// settings it was built with, in this order.  Edge penalties and
+// restrictions depend on those settings.
+const (
+	graphMagic   = "convoy-graph"
+	graphVersion = 3
+)
+
+const (
+	graphNodes = iota
+	graphFirstOut
+	graphEdges
+	graphAttrs
+	graphFirstIn
+	graphPreds
+	graphTurns
+	graphRoot
+	graphIds
+	graphWays
+	graphTurnRels
+	graphSettings
+	graphArrays
+)
+
+// graphBuild describes the flags that shape the graph built from --input.
+func graphBuild() string {
+	return fmt.Sprintf("vehicle=%v turn_restrictions=%v destination_penalty=%v",
+		truckTag(), *turn_restrictions, *destination_penalty)
+}
+
+// bytesOf views the memory of a slice's n elements of size bytes.
+func bytesOf(p unsafe.Pointer, n int, size uintptr) []byte {
+	if n == 0 {
+		return nil
+	}
+	length := n * int(size)
+	return (*[1 << 40]byte)(p)[:length:length]
+}
+
+// Each of these views a mapped array as its elements.
+func nodesIn(b []byte) []node {
+	n := len(b) / int(unsafe.Sizeof(node{}))
+	if n == 0 {
+		return nil
+	}
+	return (*[1 << 32]node)(unsafe.Pointer(&b[0]))[:n:n]
+}
+
+func uint32sIn(b []byte) []uint32 {
+	n := len(b) / 4
+	if n == 0 {
+		return nil
+	}
+	return (*[1 << 36]uint32)(unsafe.Pointer(&b[0]))[:n:n]
+}
+
+func nodeIdsIn(b []byte) []graph.NodeId {
+	n := len(b) / 4
+	if n == 0 {
+		return nil
+	}
+	return (*[1 << 36]graph.NodeId)(unsafe.Pointer(&b[0]))[:n:n]
+}
+
+func attrsIn(b []byte) []edgeAttr {
+	n := len(b) / int(unsafe.Sizeof(edgeAttr{}))
+	if n == 0 {
+		return nil
+	}
+	return (*[1 << 36]edgeAttr)(unsafe.Pointer(&b[0]))[:n:n]
+}
+
+func int64sIn(b []byte) []int64 {
+	n := len(b) / 8
+	if n == 0 {
+		return nil
+	}
+	return (*[1 << 36]int64)(unsafe.Pointer(&b[0]))[:n:n]
+}
+
+func turnsIn(b []byte) []graph.Restriction {
+	n := len(b) / int(unsafe.Sizeof(graph.Restriction{}))
+	if n == 0 {
+		return nil
+	}
+	return (*[1 << 32]graph.Restriction)(unsafe.Pointer(&b[0]))[:n:n]
+}
+
+// graphArrays returns the arrays of a graph file.
+func (mt *mapTool) graphArrays() [][]byte {
+	md := mt.data
+	root := []uint32{uint32(mt.tree.Root().(*node).id)}
+	arrays := make([][]byte, graphArrays)
+	arrays[graphNodes] = bytesOf(unsafe.Pointer(&md.nodes[0]),
+		len(md.nodes), unsafe.Sizeof(node{}))
+	arrays[graphFirstOut] = bytesOf(unsafe.Pointer(&md.firstOut[0]),
+		len(md.firstOut), 4)
+	arrays[graphFirstIn] = bytesOf(unsafe.Pointer(&md.firstIn[0]),
+		len(md.firstIn), 4)
+	arrays[graphRoot] = bytesOf(unsafe.Pointer(&root[0]), 1, 4)
+	arrays[graphIds] = bytesOf(unsafe.Pointer(&md.osmIds[0]),
+		len(md.osmIds), 8)
+	if len(md.edges) != 0 {
+		arrays[graphEdges] = bytesOf(unsafe.Pointer(&md.edges[0]),
+			len(md.edges), 4)
+		arrays[graphAttrs] = bytesOf(unsafe.Pointer(&md.attrs[0]),
+			len(md.attrs), unsafe.Sizeof(edgeAttr{}))
+		arrays[graphPreds] = bytesOf(unsafe.Pointer(&md.preds[0]),
+			len(md.preds), 4)
+		arrays[graphWays] = bytesOf(unsafe.Pointer(&md.ways[0]),
+			len(md.ways), 8)
+	}
+	if len(mt.turns) != 0 {
+		arrays[graphTurns] = bytesOf(unsafe.Pointer(&mt.turns[0]),
+			len(mt.turns), unsafe.Sizeof(graph.Restriction{}))
+		arrays[graphTurnRels] = bytesOf(unsafe.Pointer(&mt.turnRels[0]),
+			len(mt.turnRels), 8)
+	}
+	arrays[graphSettings] = []byte(graphBuild())
+	return arrays
+}
+
+// fingerprint is a checksum of the graph, identifying the map that
+// hierarchies and landmarks were computed for.  Changes renumber the
+// nodes, so the node count alone does not.  A mapped graph file has
+// the checksum in its header.
+func (mt *mapTool) fingerprint() uint32 {
+	if mt.graphFile != nil {
+		return mt.graphFile.Checksum()
+	}
+	crc := crc32.NewIEEE()
+	for _, a := range mt.graphArrays() {
+		crc.Write(a)
+	}
+	return crc.Sum32()
+}
+
+// routingTag identifies the weights of the routing graph: the map, the
+// metric and the vehicle.
+func (mt *mapTool) routingTag() string {
+	return fmt.Sprintf("graph=%08x metric=%v vehicle=%v",
+		mt.fingerprint(), mt.metric, truckTag())
+}
+
+// writeGraph saves the graph built from --input to name.
+func (mt *mapTool) writeGraph(name string) error {
+	arrays := mt.graphArrays()
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := common.WriteArrays(f, graphMagic, graphVersion, arrays); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readGraph maps a graph file written by writeGraph.  The map stays
+// mapped, read-only, for the life of the program.  A file built with
+// other settings is left unread, to be rebuilt from --input.
+func (mt *mapTool) readGraph(name string) error {
+	af, err := common.OpenArrays(name, graphMagic, graphVersion)
+	if err != nil {
+		return err
+	}
+	if af.Count() != graphArrays {
+		af.Close()
+		return errors.New("Incorrect graph file: " + name)
+	}
+	if built := string(af.Array(graphSettings)); built != graphBuild() {
+		af.Close()
+		if *input == "" {
+			return errors.New(fmt.Sprint("Graph file built with ", built,
+				", not ", graphBuild(), ": ", name))
+		}
+		log.Printf("Rebuilding graph file built with %v, not %v: %v",
+			built, graphBuild(), name)
+		return nil
+	}
+	md := &mapData2{
+		nodesIn(af.Array(graphNodes)),
+		uint32sIn(af.Array(graphFirstOut)),
+		nodeIdsIn(af.Array(graphEdges)),
+		attrsIn(af.Array(graphAttrs)),
+		uint32sIn(af.Array(graphFirstIn)),
+		nodeIdsIn(af.Array(graphPreds)),
+		int64sIn(af.Array(graphIds)),
+		int64sIn(af.Array(graphWays)),
+	}
+	root := uint32sIn(af.Array(graphRoot))
+	turns := turnsIn(af.Array(graphTurns))
+	turnRels := int64sIn(af.Array(graphTurnRels))
+	if len(md.nodes) < 2 || len(md.firstOut) != len(md.nodes)+1 ||
+		len(md.firstIn) != len(md.nodes)+1 || len(root) != 1 ||
+		int(md.firstOut[len(md.nodes)]) != len(md.edges) ||
+		int(md.firstIn[len(md.nodes)]) != len(md.preds) ||
+		len(md.attrs) != len(md.edges) || int(root[0]) >= len(md.nodes) ||
+		len(md.osmIds) != len(md.nodes) || len(md.ways) != len(md.edges) ||
+		len(turnRels) != len(turns) {
+		af.Close()
+		return errors.New("Inconsistent graph file: " + name)
+	}
+	mt.graphFile = af
+	mt.data = md
+	mt.turns = turns
+	mt.turnRels = turnRels
+	mt.tree = geo.NewBuiltTree(md, &md.nodes[root[0]])
+	log.Println("Mapped graph file:", name, "with", md.Count(), "nodes,",
+		len(md.edges), "edges,", len(mt.turns), "turn restrictions")
+	return nil
+}
+
+// mapArc is an edge of the graph while changes are applied to it.
+type mapArc struct {
+	from, to graph.NodeId
+	attr edgeAttr
+	way int64
+}
+
+// newMapDataArcs builds the graph of nodes, numbered from 1, and arcs.
+func newMapDataArcs(nodes []node, osmIds []int64, arcs []mapArc) *mapData2 {
+	md := &mapData2{
+		nodes,
+		make([]uint32, len(nodes)+1),
+		make([]graph.NodeId, len(arcs)),
+		make([]edgeAttr, len(arcs)),
+		make([]uint32, len(nodes)+1),
+		make([]graph.NodeId, len(arcs)),
+		osmIds,
+		make([]int64, len(arcs)),
+	}
+	for _, a := range arcs {
+		md.firstOut[a.from+1]++
+		md.firstIn[a.to+1]++
+	}
+	for i := 1; i < len(md.firstOut); i++ {
+		md.firstOut[i] += md.firstOut[i-1]
+		md.firstIn[i] += md.firstIn[i-1]
+	}
+	for _, a := range arcs {
+		md.addArc(a.from, a.to, a.attr, a.way)
+	}
+	return md
+}
+
+// hasArc reports whether there is an edge from one node to another,
+// on the given way unless way is zero.
+func (md *mapData2) hasArc(from, to graph.NodeId, way int64) bool {
+	for ei := md.firstOut[from]; ei < md.firstOut[from+1]; ei++ {
+		if md.edges[ei] == to && (way == 0 || md.ways[ei] == way) {
+			return true
+		}
+	}
+	return false
+}
+
+// wayTurn resolves a restriction relation into a turn between nodes,
+// like mapData1.turns.
+func (md *mapData2) wayTurn(tr maps.TurnRestriction,
+	ids map[mapId]graph.NodeId) (graph.Turn, bool) {
+	via, has := ids[mapId(tr.Via)]
+	if !has {
+		return graph.Turn{}, false
+	}
+	var from, to []graph.NodeId
+	for _, p := range md.Predecessors(via) {
+		if md.hasArc(p, via, tr.From) {
+			from = append(from, p)
+		}
+	}
+	for _, n := range md.Neighbors(via) {
+		if md.hasArc(via, n, tr.To) {
+			to = append(to, n)
+		}
+	}
+	// Via must be at an end of both ways.
+	if len(from) != 1 || len(to) != 1 {
+		return graph.Turn{}, false
+	}
+	return graph.Turn{from[0], via, to[0]}, true
+}
+
+func readChange(name string) (*maps.Change, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	}
+	return maps.ReadChange(r)
+}
+
+// applyChange updates the graph with an osmChange file.  Changed ways
+// are replaced whole, skipping segments to nodes that are neither in
+// the graph nor in the change, and nodes left without edges are
+// dropped.
+func (mt *mapTool) applyChange(name string) error {
+	c, err := readChange(name)
+	if err != nil {
+		return err
+	}
+	md := mt.data
+	ids := make(map[mapId]graph.NodeId, len(md.osmIds))
+	for n := graph.FirstNodeId; int(n) < len(md.nodes); n++ {
+		ids[mapId(md.osmIds[n])] = n
+	}
+	nodes := make([]node, len(md.nodes))
+	copy(nodes, md.nodes)
+	osmIds := make([]int64, len(md.osmIds))
+	copy(osmIds, md.osmIds)
+
+	// Move nodes of the graph, and place those it may gain.
+	moved := 0
+	placed := make(map[mapId]maps.Node)
+	for _, bd := range []*maps.BlockData{&c.Create, &c.Modify} {
+		for _, n := range bd.Nodes {
+			id, has := ids[mapId(n.Id)]
+			if !has {
+				placed[mapId(n.Id)] = n
+				continue
+			}
+			geo.SphereCoords{Lat: n.Lat, Long: n.Long}.
+				ToCoords(nodes[id].position[:])
+			moved++
+		}
+	}
+	nodeFor := func(ref int64) (graph.NodeId, bool) {
+		if id, has := ids[mapId(ref)]; has {
+			return id, true
+		}
+		n, has := placed[mapId(ref)]
+		if !has {
+			return graph.ZeroNodeId, false
+		}
+		id := graph.NodeId(len(nodes))
+		nodes = append(nodes, node{id: id})
+		geo.SphereCoords{Lat: n.Lat, Long: n.Long}.
+			ToCoords(nodes[id].position[:])
+		osmIds = append(osmIds, ref)
+		ids[mapId(ref)] = id
+		return id, true
+	}
+
+	// Keep the edges of unchanged ways, then add the changed ways.
+	replaced := make(map[int64]bool)
+	for _, ways := range [][]maps.Way{c.Modify.Ways, c.Delete.Ways} {
+		for _, way := range ways {
+			replaced[way.Id] = true
+		}
+	}
+	var arcs []mapArc
+	for from := graph.FirstNodeId; int(from) < len(md.nodes); from++ {
+		for ei := md.firstOut[from]; ei < md.firstOut[from+1]; ei++ {
+			if !replaced[md.ways[ei]] {
+				arcs = append(arcs, mapArc{from, md.edges[ei],
+					md.attrs[ei], md.ways[ei]})
+			}
+		}
+	}
+	added, skipped := 0, 0
+	for _, ways := range [][]maps.Way{c.Create.Ways, c.Modify.Ways} {
+		for w := range ways {
+			way := &ways[w]
+			if !keepWay(way) || len(way.Refs) < 2 {
+				continue
+			}
+			added++
+			oneway := way.Oneway()
+			attr := wayAttr(way)
+			for e := 1; e < len(way.Refs); e++ {
+				n0, has0 := nodeFor(way.Refs[e-1])
+				n1, has1 := nodeFor(way.Refs[e])
+				if !has0 || !has1 {
+					skipped++
+					continue
+				}
+				if oneway >= 0 {
+					arcs = append(arcs, mapArc{n0, n1, attr, way.Id})
+				}
+				if oneway <= 0 {
+					arcs = append(arcs, mapArc{n1, n0, attr, way.Id})
+				}
+			}
+		}
+	}
+
+	// Renumber the nodes that still have edges.
+	renumber := make([]graph.NodeId, len(nodes))
+	for _, a := range arcs {
+		renumber[a.from], renumber[a.to] = 1, 1
+	}
+	kept := []node{node{}}
+	keptIds := []int64{0}
+	for n := graph.FirstNodeId; int(n) < len(nodes); n++ {
+		if renumber[n] == graph.ZeroNodeId {
+			continue
+		}
+		renumber[n] = graph.NodeId(len(kept))
+		kept = append(kept, node{id: renumber[n], position: nodes[n].position})
+		keptIds = append(keptIds, osmIds[n])
+	}
+	for i := range arcs {
+		arcs[i].from, arcs[i].to = renumber[arcs[i].from], renumber[arcs[i].to]
+	}
+	for ref, id := range ids {
+		if renumber[id] == graph.ZeroNodeId {
+			delete(ids, ref)
+		} else {
+			ids[ref] = renumber[id]
+		}
+	}
+	md2 := newMapDataArcs(kept, keptIds, arcs)
+
+	// Keep the turns of unchanged relations whose edges remain, then
+	// add the changed relations.
+	replaced = make(map[int64]bool)
+	for _, rels := range [][]maps.Relation{c.Modify.Rels, c.Delete.Rels} {
+		for _, rel := range rels {
+			replaced[rel.Id] = true
+		}
+	}
+	var turns []graph.Restriction
+	var turnRels []int64
+	for i, r := range mt.turns {
+		t := graph.Turn{renumber[r.From], renumber[r.Via], renumber[r.To]}
+		if replaced[mt.turnRels[i]] || t.From == graph.ZeroNodeId ||
+			t.Via == graph.ZeroNodeId || t.To == graph.ZeroNodeId ||
+			!md2.hasArc(t.From, t.Via, 0) || !md2.hasArc(t.Via, t.To, 0) {
+			continue
+		}
+		turns = append(turns, graph.Restriction{t, r.Only})
+		turnRels = append(turnRels, mt.turnRels[i])
+	}
+	for _, rels := range [][]maps.Relation{c.Create.Rels, c.Modify.Rels} {
+		for r := range rels {
+			tr, ok := rels[r].TurnRestriction(truck != nil)
+			if !ok || !*turn_restrictions {
+				continue
+			}
+			if t, ok := md2.wayTurn(tr, ids); ok {
+				turns = append(turns, graph.Restriction{t, tr.Only})
+				turnRels = append(turnRels, rels[r].Id)
+			}
+		}
+	}
+
+	mt.data = md2
+	mt.turns = turns
+	mt.turnRels = turnRels
+	mt.tree = geo.NewTree(md2)
+	mt.tree.Build()
+	mt.changed = true
+	if mt.graphFile != nil {
+		mt.graphFile.Close()
+		mt.graphFile = nil
+	}
+	log.Println("Applied", name, "moving", moved, "nodes, adding",
+		added, "ways, skipping", skipped, "segments; now", md2.Count(),
+		"nodes,", len(md2.edges), "edges,", len(turns), "turn restrictions")
+	return nil
+}
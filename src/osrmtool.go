@@ -1,26 +1,18 @@
 package main
 
-import "bufio"
-import "bytes"
-import "compress/zlib"
-import "database/sql"
 import "encoding/json"
-import "encoding/binary"
 import "errors"
 import "flag"
 import "fmt"
 import "hash/crc32"
-import "io"
-import "io/ioutil"
 import "log"
-import "path"
-import "os"
 import "sort"
 import "time"
 
 import "common"
 import "data"
 import "geo"
+import "disttable"
 
 var osrmDir = flag.String("osrm_dir", "/home/jmacd/src/Project-OSRM",
 	"A directory that contains osrm-routed and server.ini")
@@ -33,28 +25,18 @@ func main() {
 	data.Main(programBody)
 }
 
-type IdPair struct {
-	From, To int64
-}
-
 type LocId struct {
 	Id int64
 	geo.CityStateLoc
 }
 
-type PairStat struct {
-	meters, seconds int32
-}
-
 type LocPair struct {
 	from, to *LocId
 	ch chan<- *LocPair
-	PairStat
+	disttable.PairStat
 	err error
 }
 
-type PairMap map[IdPair]PairStat
-
 type OsrmTool struct {
 	*data.ConvoyData
 }
@@ -97,10 +79,6 @@ func (ds DestSort) Swap(i, j int) {
 	ds.Destinations[i], ds.Destinations[j] = ds.Destinations[j], ds.Destinations[i] 
 }
 
-func dtFile(c *LocId) string {
-	return path.Join(*distanceDir, c.CityState.String())
-}
-
 func isDestinationFrom(from, to string) bool {
 	if from == to {
 		return false
@@ -129,37 +107,8 @@ func (osrm *OsrmTool) fillTableFor(cslocs []*LocId, ch chan<- *LocPair) {
 	}
 }
 
-func (osrm *OsrmTool) readDistanceTable(from *LocId) (PairMap, error) {
-	m := make(PairMap)
-	p := dtFile(from)
-	table, err := ioutil.ReadFile(p)
-	if err != nil {
-		return nil, err
-	}
-	b := bytes.NewBuffer(table)
-	r, err := zlib.NewReader(b)
-	if err != nil {
-		return nil, err
-	}
-	rb := bufio.NewReader(r)
-	for {
-		i1, err1 := binary.ReadVarint(rb)
-		if err1 == io.EOF {
-			return m, nil
-		}
-		if err1 != nil {
-			return nil, err1
-		}
-		i2, err2 := binary.ReadVarint(rb)
-		if err2 != nil {
-			return nil, err2
-		}
-		i3, err3 := binary.ReadVarint(rb)
-		if err3 != nil {
-			return nil, err3
-		}
-		m[IdPair{from.Id,i1}] = PairStat{int32(i2), int32(i3)}
-	}
+func (osrm *OsrmTool) readDistanceTable(from *LocId) (disttable.PairMap, error) {
+	return disttable.ReadTable(*distanceDir, from.CityState, from.Id)
 }
 
 func (osrm *OsrmTool) fillDistanceTable(from *LocId, dests []*LocId, ch chan<- *LocPair) error {
@@ -174,7 +123,7 @@ func (osrm *OsrmTool) fillDistanceTable(from *LocId, dests []*LocId, ch chan<- *
 	for _, d := range dests {
 		lp := &LocPair{from: from, to: d, ch: rch}
 		lps = append(lps, lp)
-		if exist, has := existPairs[IdPair{from.Id, d.Id}]; has {
+		if exist, has := existPairs[disttable.IdPair{from.Id, d.Id}]; has {
 			lp.PairStat = exist
 		} else {
 			ch <- lp
@@ -187,8 +136,7 @@ func (osrm *OsrmTool) fillDistanceTable(from *LocId, dests []*LocId, ch chan<- *
 	sort.Sort(DestSort{lps})
 	noroute := 0
 	
-	msbuf := make([]byte, 3 * 5 * len(dests))  // 5 = max int32 -> varint
-	mspos := 0
+	var entries []disttable.TableEntry
 	for _, lp := range lps {
 		if lp.err != nil {
 			if _, ok := lp.err.(*NoRouteError); ok {
@@ -196,24 +144,15 @@ func (osrm *OsrmTool) fillDistanceTable(from *LocId, dests []*LocId, ch chan<- *
 			} else {
 				log.Printf("[%v] %s error %v", lp.to.Id, lp.to.CityStateLoc, lp.err)
 			}
-			mspos += binary.PutVarint(msbuf[mspos:mspos+5], 0)
-			mspos += binary.PutVarint(msbuf[mspos:mspos+5], 0)
-			mspos += binary.PutVarint(msbuf[mspos:mspos+5], 0)
+			entries = append(entries, disttable.TableEntry{})
 		} else {
-			mspos += binary.PutVarint(msbuf[mspos:mspos+5], int64(lp.to.Id))
-			mspos += binary.PutVarint(msbuf[mspos:mspos+5], int64(lp.meters))
-			mspos += binary.PutVarint(msbuf[mspos:mspos+5], int64(lp.seconds))
+			entries = append(entries, disttable.TableEntry{lp.to.Id, lp.PairStat})
 		}
 	}
 	if noroute != 0 {
 		log.Printf("%d no-route errors", noroute)
 	}
-	var b bytes.Buffer
-	w := zlib.NewWriter(&b)
-	w.Write(msbuf[0:mspos])
-	w.Close()
-	p := dtFile(from)
-	return ioutil.WriteFile(p, b.Bytes(), os.ModePerm)
+	return disttable.WriteTable(*distanceDir, from.CityState, entries)
 }		
 
 func (osrm *OsrmTool) Viaroute(lp *LocPair) ([]byte, error) {
@@ -241,8 +180,8 @@ func (osrm *OsrmTool) computeDistance(lp *LocPair) error {
 	} else if route.RouteSummary == nil {
 		return errors.New("No route summary")
 	}
-	lp.meters = route.RouteSummary.TotalDistance
-	lp.seconds = route.RouteSummary.TotalTime
+	lp.Meters = route.RouteSummary.TotalDistance
+	lp.Seconds = route.RouteSummary.TotalTime
 	// log.Printf("%v -> %v: %.0f m %.0f s", 
 	// 	lp.from,
 	// 	lp.to,
@@ -256,7 +195,7 @@ func (osrm *OsrmTool) computeDistanceAndReturn(lp *LocPair) {
 	lp.ch <- lp
 }
 
-func programBody(db *sql.DB) error {
+func programBody(db data.Storage) error {
 	routed := *osrmDir + "/osrm-routed"
 	servini := *osrmDir + "/server.ini"
 	osrm, err := common.StartProcess(routed, []string{"NOENV=yes"}, servini)
@@ -0,0 +1,117 @@
+package trips
+
+import "encoding/csv"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "strconv"
+import "time"
+
+const timeFormat = "2006-01-02 15:04"
+
+func dollars(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func WriteText(w io.Writer, itineraries []*Itinerary) error {
+	for i, it := range itineraries {
+		if _, err := fmt.Fprintf(w, "%d. $%s profit, %d legs\n",
+			i+1, dollars(it.Profit), len(it.Legs)); err != nil {
+			return err
+		}
+		for _, l := range it.Legs {
+			if _, err := fmt.Fprintf(w,
+				"  %.0f km empty, pick up %s %v -> %v %s (%s), %.0f km, deliver %s: $%s - $%s\n",
+				l.DeadheadKm, l.Pickup.Format(timeFormat),
+				l.Load.Origin, l.Load.Dest, l.Load.Equipment,
+				l.Load.Rate, l.LoadedKm, l.Deliver.Format(timeFormat),
+				dollars(l.Revenue), dollars(l.DeadheadCost)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func WriteCSV(w io.Writer, itineraries []*Itinerary) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Itinerary", "Profit", "Leg", "DeadheadKm",
+		"Pickup", "Origin", "Dest", "Equipment", "Rate", "LoadedKm",
+		"Deliver", "Revenue", "DeadheadCost", "Phone"})
+	for i, it := range itineraries {
+		for j, l := range it.Legs {
+			cw.Write([]string{strconv.Itoa(i + 1), dollars(it.Profit),
+				strconv.Itoa(j + 1),
+				strconv.FormatFloat(l.DeadheadKm, 'f', 0, 64),
+				l.Pickup.Format(timeFormat),
+				l.Load.Origin.String(), l.Load.Dest.String(),
+				l.Load.Equipment, l.Load.Rate.String(),
+				strconv.FormatFloat(l.LoadedKm, 'f', 0, 64),
+				l.Deliver.Format(timeFormat),
+				dollars(l.Revenue), dollars(l.DeadheadCost),
+				l.Load.Phone})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type jsonLeg struct {
+	DeadheadKm   float64
+	LoadedKm     float64
+	Arrive       time.Time
+	Pickup       time.Time
+	Deliver      time.Time
+	Origin       string
+	Dest         string
+	Equipment    string
+	Rate         string
+	Revenue      float64
+	DeadheadCost float64
+	Phone        string
+}
+
+type jsonItinerary struct {
+	Profit float64
+	Legs   []jsonLeg
+}
+
+func WriteJSON(w io.Writer, itineraries []*Itinerary) error {
+	out := []jsonItinerary{}
+	for _, it := range itineraries {
+		ji := jsonItinerary{float64(it.Profit) / 100, nil}
+		for _, l := range it.Legs {
+			ji.Legs = append(ji.Legs, jsonLeg{l.DeadheadKm, l.LoadedKm,
+				l.Arrive, l.Pickup, l.Deliver,
+				l.Load.Origin.String(), l.Load.Dest.String(),
+				l.Load.Equipment, l.Load.Rate.String(),
+				float64(l.Revenue) / 100, float64(l.DeadheadCost) / 100,
+				l.Load.Phone})
+		}
+		out = append(out, ji)
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Write writes itineraries as "text", "csv" or "json".
+func Write(w io.Writer, format string, itineraries []*Itinerary) error {
+	switch format {
+	case "text":
+		return WriteText(w, itineraries)
+	case "csv":
+		return WriteCSV(w, itineraries)
+	case "json":
+		return WriteJSON(w, itineraries)
+	}
+	return errors.New("Unknown report format: " + format)
+}
@@ -0,0 +1,175 @@
+// Package trips chains loads into multi-stop itineraries for one
+// truck.
+
+package trips
+
+import "sort"
+import "time"
+
+import "boards"
+import "common"
+
+// TravelFunc returns the driving distance and time between places.
+type TravelFunc func(src, dest common.CityState) (meters, seconds int, ok bool, err error)
+
+type Options struct {
+	Start     common.CityState
+	StartTime time.Time
+	Horizon   time.Duration
+	// Cost in cents of each empty kilometer.
+	DeadheadCost float64
+	// Hours a day that the truck can drive; travel times are
+	// stretched to include rest.
+	DrivingHours float64
+	// Time at each pickup and delivery.
+	Handling time.Duration
+	MaxLegs  int
+	// Number of partial itineraries kept at each leg.
+	BeamWidth int
+}
+
+// Leg is one load, preceded by an empty drive to its origin.
+type Leg struct {
+	Load         boards.Load
+	DeadheadKm   float64
+	LoadedKm     float64
+	Arrive       time.Time // At the origin
+	Pickup       time.Time
+	Deliver      time.Time
+	Revenue      int // Cents
+	DeadheadCost int // Cents
+}
+
+type Itinerary struct {
+	Legs   []*Leg
+	Profit int // Cents
+}
+
+type state struct {
+	itinerary *Itinerary
+	at        common.CityState
+	free      time.Time
+	used      map[*boards.Load]bool
+}
+
+type Planner struct {
+	opts   Options
+	travel TravelFunc
+	loads  []*boards.Load
+}
+
+func NewPlanner(opts Options, travel TravelFunc, loads []*boards.Load) *Planner {
+	return &Planner{opts, travel, loads}
+}
+
+// elapsed stretches driving seconds to include rest time.
+func (p *Planner) elapsed(seconds int) time.Duration {
+	d := time.Duration(seconds) * time.Second
+	if p.opts.DrivingHours > 0 && p.opts.DrivingHours < 24 {
+		d = time.Duration(float64(d) * 24 / p.opts.DrivingHours)
+	}
+	return d
+}
+
+// extend returns the state after hauling load, or nil if the load
+// cannot be picked up on its date or delivered within the horizon.
+func (p *Planner) extend(s *state, load *boards.Load) (*state, error) {
+	dm, ds, ok, err := p.travel(s.at, load.Origin)
+	if err != nil || !ok {
+		return nil, err
+	}
+	lm, ls, ok, err := p.travel(load.Origin, load.Dest)
+	if err != nil || !ok || lm == 0 {
+		return nil, err
+	}
+	revenue, ok := load.Rate.Normalize(float64(lm) / 1000)
+	if !ok {
+		return nil, nil
+	}
+	y, m, d := load.PickupDate.Date()
+	open := time.Date(y, m, d, 0, 0, 0, 0, load.PickupDate.Location())
+	arrive := s.free.Add(p.elapsed(ds))
+	if !arrive.Before(open.AddDate(0, 0, 1)) {
+		return nil, nil
+	}
+	pickup := arrive
+	if pickup.Before(open) {
+		pickup = open
+	}
+	deliver := pickup.Add(p.opts.Handling).Add(p.elapsed(ls))
+	end := p.opts.StartTime.Add(p.opts.Horizon)
+	if deliver.After(end) {
+		return nil, nil
+	}
+	leg := &Leg{*load, float64(dm) / 1000, float64(lm) / 1000,
+		arrive, pickup, deliver, revenue.TotalCents,
+		int(p.opts.DeadheadCost*float64(dm)/1000 + 0.5)}
+	legs := make([]*Leg, len(s.itinerary.Legs), len(s.itinerary.Legs)+1)
+	copy(legs, s.itinerary.Legs)
+	used := make(map[*boards.Load]bool, len(s.used)+1)
+	for l, _ := range s.used {
+		used[l] = true
+	}
+	used[load] = true
+	return &state{&Itinerary{append(legs, leg),
+		s.itinerary.Profit + leg.Revenue - leg.DeadheadCost},
+		load.Dest, deliver.Add(p.opts.Handling), used}, nil
+}
+
+// Plan searches chains of loads leg by leg, keeping the BeamWidth
+// most profitable chains at each leg, and returns the k most
+// profitable itineraries found.
+func (p *Planner) Plan(k int) ([]*Itinerary, error) {
+	beam := []*state{{&Itinerary{}, p.opts.Start, p.opts.StartTime,
+		make(map[*boards.Load]bool)}}
+	var found []*Itinerary
+	for leg := 0; leg < p.opts.MaxLegs && len(beam) != 0; leg++ {
+		var next []*state
+		for _, s := range beam {
+			for _, load := range p.loads {
+				if s.used[load] {
+					continue
+				}
+				ns, err := p.extend(s, load)
+				if err != nil {
+					return nil, err
+				}
+				if ns != nil {
+					next = append(next, ns)
+				}
+			}
+		}
+		sort.Stable(byStateProfit(next))
+		if len(next) > p.opts.BeamWidth {
+			next = next[:p.opts.BeamWidth]
+		}
+		for _, s := range next {
+			found = append(found, s.itinerary)
+		}
+		beam = next
+	}
+	sort.Stable(byProfit(found))
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found, nil
+}
+
+type byProfit []*Itinerary
+
+func (b byProfit) Len() int      { return len(b) }
+func (b byProfit) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byProfit) Less(i, j int) bool {
+	if b[i].Profit != b[j].Profit {
+		return b[i].Profit > b[j].Profit
+	}
+	return len(b[i].Legs) < len(b[j].Legs)
+}
+
+type byStateProfit []*state
+
+func (b byStateProfit) Len() int      { return len(b) }
+func (b byStateProfit) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byStateProfit) Less(i, j int) bool {
+	return byProfit{b[i].itinerary, b[j].itinerary}.Less(0, 1)
+}
@@ -0,0 +1,82 @@
+package trips
+
+import "bytes"
+import "testing"
+import "time"
+
+import "boards"
+import "common"
+
+var (
+	boise = common.CityState{"Boise", "ID"}
+	nampa = common.CityState{"Nampa", "ID"}
+	salem = common.CityState{"Salem", "OR"}
+	reno  = common.CityState{"Reno", "NV"}
+)
+
+var day0 = time.Date(2013, time.April, 8, 0, 0, 0, 0, time.UTC)
+
+func testTravel(src, dest common.CityState) (int, int, bool, error) {
+	if src == dest {
+		return 0, 0, true, nil
+	}
+	if (src == boise && dest == nampa) || (src == nampa && dest == boise) {
+		return 30000, 1800, true, nil
+	}
+	return 700000, 7 * 3600, true, nil
+}
+
+func testLoad(from, to common.CityState, day int, rate string) *boards.Load {
+	return &boards.Load{0, "test", day0.AddDate(0, 0, day), from, to,
+		"Full", 48, 40000, "Van", boards.ParseRate(rate), 0, ""}
+}
+
+func TestPlan(t *testing.T) {
+	a := testLoad(boise, salem, 0, "$1400")
+	b := testLoad(salem, reno, 1, "$1000")
+	c := testLoad(nampa, reno, 0, "$1500")
+	d := testLoad(reno, boise, 5, "$3000")
+	e := testLoad(salem, boise, -1, "$3000")
+	p := NewPlanner(Options{boise, day0.Add(8 * time.Hour),
+		3 * 24 * time.Hour, 100, 24, time.Hour, 3, 10},
+		testTravel, []*boards.Load{a, b, c, d, e})
+	its, err := p.Plan(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(its) != 3 {
+		t.Fatalf("Expected 3 itineraries: %v", its)
+	}
+	best := its[0]
+	if best.Profit != 240000 || len(best.Legs) != 2 ||
+		best.Legs[0].Load != *a || best.Legs[1].Load != *b {
+		t.Errorf("Incorrect best itinerary: %+v", best)
+	}
+	if l := best.Legs[1]; !l.Pickup.Equal(day0.AddDate(0, 0, 1)) ||
+		!l.Deliver.Equal(day0.AddDate(0, 0, 1).Add(8*time.Hour)) {
+		t.Errorf("Incorrect times: %+v", l)
+	}
+	if its[1].Profit != 177000 || its[1].Legs[0].DeadheadKm != 30 {
+		t.Errorf("Incorrect second itinerary: %+v", its[1])
+	}
+	for _, it := range its {
+		for _, l := range it.Legs {
+			if l.Load == *d || l.Load == *e {
+				t.Errorf("Infeasible load planned: %v", l.Load)
+			}
+		}
+	}
+	for _, format := range []string{"text", "csv", "json"} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, its); err != nil || buf.Len() == 0 {
+			t.Errorf("Write %s: %v", format, err)
+		}
+	}
+}
+
+func TestElapsed(t *testing.T) {
+	p := NewPlanner(Options{DrivingHours: 11}, testTravel, nil)
+	if e := p.elapsed(11 * 3600); e != 24*time.Hour {
+		t.Errorf("Incorrect elapsed time: %v", e)
+	}
+}
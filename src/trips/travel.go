@@ -0,0 +1,62 @@
+package trips
+
+import "math"
+import "os"
+
+import "common"
+import "disttable"
+import "lanes"
+
+// Travel looks up driving times in the osrmtool distance tables,
+// falling back to road distances at a fixed speed.
+type Travel struct {
+	dir       string
+	ids       map[common.CityState]int64
+	distances *lanes.RoadDistances
+	speedKph  float64
+	tables    map[common.CityState]disttable.PairMap
+}
+
+// NewTravel reads tables from dir, which may be empty.  ids maps
+// cities to their Locations Ids.
+func NewTravel(dir string, ids map[common.CityState]int64,
+	distances *lanes.RoadDistances, speedKph float64) *Travel {
+	return &Travel{dir, ids, distances, speedKph,
+		make(map[common.CityState]disttable.PairMap)}
+}
+
+func (t *Travel) table(cs common.CityState) (disttable.PairMap, error) {
+	if m, has := t.tables[cs]; has {
+		return m, nil
+	}
+	var m disttable.PairMap
+	if id, has := t.ids[cs]; has && len(t.dir) != 0 {
+		var err error
+		m, err = disttable.ReadTable(t.dir, cs, id)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	t.tables[cs] = m
+	return m, nil
+}
+
+// Lookup is a TravelFunc.
+func (t *Travel) Lookup(src, dest common.CityState) (int, int, bool, error) {
+	if src == dest {
+		return 0, 0, true, nil
+	}
+	m, err := t.table(src)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if ps, has := m[disttable.IdPair{t.ids[src], t.ids[dest]}]; has {
+		return int(ps.Meters), int(ps.Seconds), true, nil
+	}
+	km, _, has, err := t.distances.Distance(src, dest)
+	if err != nil || !has {
+		return 0, 0, false, err
+	}
+	seconds := float64(km) / t.speedKph * 3600
+	return km * 1000, int(math.Floor(seconds + 0.5)), true, nil
+}